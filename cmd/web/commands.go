@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"github.com/sirupsen/logrus"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/services"
+	"os"
+	"time"
+)
+
+// runCommand executes the command given after the flags instead of starting the server,
+// reporting whether there was one
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "reconcile":
+		reconcileCommand(args[1:])
+	case "interest":
+		interestCommand()
+	case "replay":
+		replayCommand()
+	case "backfill-journal":
+		backfillJournalCommand()
+	default:
+		logrus.Fatalf("unknown command %s", args[0])
+	}
+	return true
+}
+
+// reconcileCommand runs a reconciliation, prints its report and exits with status 1 when discrepancies were found
+func reconcileCommand(args []string) {
+	flags := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	freeze := flags.Bool("freeze", false, "Freeze the wallets whose balance disagrees with the ledger")
+	flags.Parse(args)
+
+	var options models.ReconciliationOptions
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "freeze" {
+			options.Freeze = freeze
+		}
+	})
+
+	report, err := services.NewReconciliationService().Reconcile(options)
+	if err != nil {
+		logrus.Fatalf("couldn't reconcile wallets: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(report)
+
+	if len(report.Discrepancies) > 0 || len(report.FailedWallets) > 0 {
+		os.Exit(1)
+	}
+}
+
+// interestCommand accrues and pays out the pending interest of savings wallets, printing the run and
+// exiting with status 1 when some wallet failed
+func interestCommand() {
+	run, err := services.NewInterestService().Run(time.Now())
+	if err != nil {
+		logrus.Fatalf("couldn't run the interest job: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(run)
+
+	if len(run.FailedWallets) > 0 {
+		os.Exit(1)
+	}
+}
+
+// replayCommand rebuilds the wallets from their event streams, printing the run and exiting with
+// status 1 when some wallet failed
+func replayCommand() {
+	run, err := services.NewWalletEventService().Replay()
+	if err != nil {
+		logrus.Fatalf("couldn't replay the wallet events: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(run)
+
+	if len(run.FailedWallets) > 0 {
+		os.Exit(1)
+	}
+}
+
+// backfillJournalCommand journals the ledger entries written before the journal existed, printing the
+// run and exiting with status 1 when some operation failed. It is safe to run again
+func backfillJournalCommand() {
+	run, err := services.NewJournalService().Backfill()
+	if err != nil {
+		logrus.Fatalf("couldn't backfill the journal: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(run)
+
+	if len(run.FailedOperations) > 0 {
+		os.Exit(1)
+	}
+}
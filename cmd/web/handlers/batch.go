@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/services"
+	"net/http"
+	"strconv"
+)
+
+type IBatchHandler interface {
+	Submit(c *gin.Context)
+	Get(c *gin.Context)
+}
+
+type BatchHandler struct {
+	batchService services.IBatchService
+}
+
+const batchStatusURL string = "/api/v1/batches/%d"
+
+func (handler *BatchHandler) Submit(c *gin.Context) {
+	var batchRequest models.BatchRequest
+	if err := c.Bind(&batchRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	batch, err := handler.batchService.Submit(batchRequest)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	if batch.Status == models.BatchStatusPending {
+		statusURL := fmt.Sprintf(batchStatusURL, batch.ID)
+		c.Header("Location", statusURL)
+		c.JSON(http.StatusAccepted, gin.H{
+			"id":          batch.ID,
+			"status":      batch.Status,
+			"total_items": batch.TotalItems,
+			"status_url":  statusURL,
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, batch)
+}
+
+func (handler *BatchHandler) Get(c *gin.Context) {
+	batchIdParam := c.Params.ByName("batch_id")
+	batchId, err := strconv.Atoi(batchIdParam)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	batch, err := handler.batchService.Get(batchId)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, batch)
+}
+
+func NewBatchHandler() IBatchHandler {
+	return &BatchHandler{
+		batchService: services.NewBatchService(),
+	}
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/services"
+	"net/http"
+)
+
+type IFeeHandler interface {
+	Quote(c *gin.Context)
+}
+
+type FeeHandler struct {
+	feeService services.IFeeService
+}
+
+func (handler *FeeHandler) Quote(c *gin.Context) {
+	var quoteRequest models.FeeQuoteRequest
+	if err := c.Bind(&quoteRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	quote, err := handler.feeService.Quote(quoteRequest)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, quote)
+}
+
+func NewFeeHandler() IFeeHandler {
+	return &FeeHandler{
+		feeService: services.NewFeeService(),
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/services"
+	"net/http"
+)
+
+type IFXHandler interface {
+	Quote(c *gin.Context)
+	Convert(c *gin.Context)
+}
+
+type FXHandler struct {
+	fxService services.IFXService
+}
+
+func (handler *FXHandler) Quote(c *gin.Context) {
+	var quoteRequest models.FXQuoteRequest
+	if err := c.Bind(&quoteRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	quote, err := handler.fxService.Quote(quoteRequest)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, quote)
+}
+
+func (handler *FXHandler) Convert(c *gin.Context) {
+	var conversionRequest models.FXConversionRequest
+	if err := c.Bind(&conversionRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	reference, err := handler.fxService.Convert(conversionRequest)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"reference": reference})
+}
+
+func NewFXHandler() IFXHandler {
+	return &FXHandler{
+		fxService: services.NewFXService(),
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/services"
+	"net/http"
+	"strconv"
+)
+
+type IHoldHandler interface {
+	Place(c *gin.Context)
+	Get(c *gin.Context)
+	Capture(c *gin.Context)
+	Void(c *gin.Context)
+}
+
+type HoldHandler struct {
+	holdService services.IHoldService
+}
+
+func (handler *HoldHandler) Place(c *gin.Context) {
+	walletIdParam := c.Params.ByName("wallet_id")
+	walletId, err := strconv.Atoi(walletIdParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	var holdRequest models.HoldRequest
+	if err = c.Bind(&holdRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	hold, err := handler.holdService.Place(walletId, holdRequest)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, hold)
+}
+
+func (handler *HoldHandler) Get(c *gin.Context) {
+	walletId, holdId, ok := holdParams(c)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	hold, err := handler.holdService.Get(walletId, holdId)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hold)
+}
+
+func (handler *HoldHandler) Capture(c *gin.Context) {
+	walletId, holdId, ok := holdParams(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	// the body is optional, an empty one captures the full hold
+	var captureRequest models.CaptureRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.Bind(&captureRequest); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+			return
+		}
+	}
+
+	hold, err := handler.holdService.Capture(walletId, holdId, captureRequest)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hold)
+}
+
+func (handler *HoldHandler) Void(c *gin.Context) {
+	walletId, holdId, ok := holdParams(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	hold, err := handler.holdService.Void(walletId, holdId)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hold)
+}
+
+func holdParams(c *gin.Context) (int, int, bool) {
+	walletId, err := strconv.Atoi(c.Params.ByName("wallet_id"))
+	if err != nil {
+		return 0, 0, false
+	}
+	holdId, err := strconv.Atoi(c.Params.ByName("hold_id"))
+	if err != nil {
+		return 0, 0, false
+	}
+	return walletId, holdId, true
+}
+
+func NewHoldHandler() IHoldHandler {
+	return &HoldHandler{
+		holdService: services.NewHoldService(),
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"io/ioutil"
+	"net/http"
+)
+
+const (
+	IdempotencyKeyHeader      string = "Idempotency-Key"
+	IdempotencyReplayedHeader string = "Idempotent-Replayed"
+)
+
+const (
+	ErrorCodeIdempotencyKeyReused     string = "the idempotency key was already used with a different request"
+	ErrorCodeIdempotencyKeyInProgress string = "a request with the same idempotency key is still being processed"
+)
+
+// responseRecorder keeps a copy of the body written by the handler
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (recorder *responseRecorder) Write(data []byte) (int, error) {
+	recorder.body.Write(data)
+	return recorder.ResponseWriter.Write(data)
+}
+
+func (recorder *responseRecorder) WriteString(s string) (int, error) {
+	recorder.body.WriteString(s)
+	return recorder.ResponseWriter.WriteString(s)
+}
+
+// Idempotency deduplicates requests carrying an Idempotency-Key header: the first outcome is
+// stored and replayed for retries with the same payload, while reusing the key for a different
+// payload is rejected with 422. Requests without the header are not affected.
+func Idempotency(repository repositories.IIdempotencyRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+			return
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		requestHash := hashRequest(c.Request.Method, c.Request.URL.Path, body)
+
+		record, err := repository.Get(key)
+		if err != nil {
+			handlerException(c, err)
+			c.Abort()
+			return
+		}
+		if record == nil {
+			reserved, err := repository.Reserve(models.IdempotencyRecord{Key: key, RequestHash: requestHash})
+			if err != nil {
+				handlerException(c, err)
+				c.Abort()
+				return
+			}
+			if reserved {
+				processRequest(c, repository, key, requestHash)
+				return
+			}
+			// another request reserved the key in between
+			if record, err = repository.Get(key); err != nil || record == nil {
+				c.AbortWithStatusJSON(http.StatusConflict, gin.H{"code": ErrorCodeIdempotencyKeyInProgress})
+				return
+			}
+		}
+
+		switch {
+		case record.RequestHash != requestHash:
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"code": ErrorCodeIdempotencyKeyReused})
+		case !record.Completed:
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"code": ErrorCodeIdempotencyKeyInProgress})
+		default:
+			c.Header(IdempotencyReplayedHeader, "true")
+			c.Data(record.StatusCode, gin.MIMEJSON, []byte(record.Body))
+			c.Abort()
+		}
+	}
+}
+
+// processRequest runs the handler and stores its response. A conflict is only reported when the
+// operation was rolled back, so it releases the key and the client can retry. Any other outcome may
+// have committed the operation: a server error can come after the commit and a response that couldn't
+// be stored leaves nothing to replay, so the key stays in progress until it expires rather than
+// letting a retry move the money again
+func processRequest(c *gin.Context, repository repositories.IIdempotencyRepository, key string, requestHash string) {
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+
+	c.Next()
+
+	status := recorder.Status()
+	if status == http.StatusConflict {
+		if err := repository.Delete(key); err != nil {
+			logrus.Errorf("couldn't release idempotency key %s: %v", key, err)
+		}
+		return
+	}
+	if status >= http.StatusInternalServerError {
+		logrus.Warnf("idempotency key %s kept in progress after a %d response", key, status)
+		return
+	}
+
+	err := repository.Save(models.IdempotencyRecord{
+		Key:         key,
+		RequestHash: requestHash,
+		Completed:   true,
+		StatusCode:  status,
+		Body:        recorder.body.String(),
+	})
+	if err != nil {
+		logrus.Errorf("couldn't store idempotency key %s, it stays in progress until it expires: %v", key, err)
+	}
+}
+
+func hashRequest(method string, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func NewIdempotencyMiddleware() gin.HandlerFunc {
+	return Idempotency(repositories.NewIdempotencyRepository())
+}
@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wallet-api/cmd/web/models"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	body := `{"amount": 10}`
+	requestHash := hashRequest(http.MethodPost, "/debit", []byte(body))
+
+	tests := []struct {
+		name        string
+		initMocks   func(*mocks.IdempotencyRepositoryMock)
+		key         string
+		body        string
+		status      int
+		handlerRuns bool
+		assertFunc  func(*testing.T, *httptest.ResponseRecorder)
+	}{
+		{
+			name:        "Success - request without key is not deduplicated",
+			initMocks:   func(repositoryMock *mocks.IdempotencyRepositoryMock) {},
+			body:        body,
+			handlerRuns: true,
+			assertFunc: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusCreated, w.Code)
+			},
+		},
+		{
+			name: "Success - first request stores its response",
+			initMocks: func(repositoryMock *mocks.IdempotencyRepositoryMock) {
+				repositoryMock.On("Get", "key-1").Return(nil, nil).Once()
+				repositoryMock.On("Reserve", models.IdempotencyRecord{Key: "key-1", RequestHash: requestHash}).
+					Return(true, nil).Once()
+				repositoryMock.On("Save", mock.MatchedBy(func(record models.IdempotencyRecord) bool {
+					return record.Completed && record.StatusCode == http.StatusCreated && record.Body == `{"id":1}`
+				})).Return(nil).Once()
+			},
+			key:         "key-1",
+			body:        body,
+			handlerRuns: true,
+			assertFunc: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusCreated, w.Code)
+			},
+		},
+		{
+			name: "Success - conflicts release the key",
+			initMocks: func(repositoryMock *mocks.IdempotencyRepositoryMock) {
+				repositoryMock.On("Get", "key-1").Return(nil, nil).Once()
+				repositoryMock.On("Reserve", models.IdempotencyRecord{Key: "key-1", RequestHash: requestHash}).
+					Return(true, nil).Once()
+				repositoryMock.On("Delete", "key-1").Return(nil).Once()
+			},
+			key:         "key-1",
+			body:        body,
+			status:      http.StatusConflict,
+			handlerRuns: true,
+			assertFunc: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusConflict, w.Code)
+			},
+		},
+		{
+			name: "Success - server errors keep the key in progress, the operation may have committed",
+			initMocks: func(repositoryMock *mocks.IdempotencyRepositoryMock) {
+				repositoryMock.On("Get", "key-1").Return(nil, nil).Once()
+				repositoryMock.On("Reserve", models.IdempotencyRecord{Key: "key-1", RequestHash: requestHash}).
+					Return(true, nil).Once()
+			},
+			key:         "key-1",
+			body:        body,
+			status:      http.StatusInternalServerError,
+			handlerRuns: true,
+			assertFunc: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusInternalServerError, w.Code)
+			},
+		},
+		{
+			name: "Success - retry replays the stored response",
+			initMocks: func(repositoryMock *mocks.IdempotencyRepositoryMock) {
+				repositoryMock.On("Get", "key-1").Return(&models.IdempotencyRecord{
+					Key:         "key-1",
+					RequestHash: requestHash,
+					Completed:   true,
+					StatusCode:  http.StatusCreated,
+					Body:        `{"id":1}`,
+				}, nil).Once()
+			},
+			key:         "key-1",
+			body:        body,
+			handlerRuns: false,
+			assertFunc: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusCreated, w.Code)
+				assert.Equal(t, `{"id":1}`, w.Body.String())
+				assert.Equal(t, "true", w.Header().Get(IdempotencyReplayedHeader))
+			},
+		},
+		{
+			name: "Error - key reused with a different payload",
+			initMocks: func(repositoryMock *mocks.IdempotencyRepositoryMock) {
+				repositoryMock.On("Get", "key-1").Return(&models.IdempotencyRecord{
+					Key:         "key-1",
+					RequestHash: requestHash,
+					Completed:   true,
+					StatusCode:  http.StatusCreated,
+				}, nil).Once()
+			},
+			key:         "key-1",
+			body:        `{"amount": 11}`,
+			handlerRuns: false,
+			assertFunc: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+			},
+		},
+		{
+			name: "Error - first request still in progress",
+			initMocks: func(repositoryMock *mocks.IdempotencyRepositoryMock) {
+				repositoryMock.On("Get", "key-1").Return(&models.IdempotencyRecord{
+					Key:         "key-1",
+					RequestHash: requestHash,
+				}, nil).Once()
+			},
+			key:         "key-1",
+			body:        body,
+			handlerRuns: false,
+			assertFunc: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusConflict, w.Code)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repositoryMock := &mocks.IdempotencyRepositoryMock{}
+			tt.initMocks(repositoryMock)
+
+			status := tt.status
+			if status == 0 {
+				status = http.StatusCreated
+			}
+			handlerRuns := false
+			r := gin.New()
+			r.POST("/debit", Idempotency(repositoryMock), func(c *gin.Context) {
+				handlerRuns = true
+				c.JSON(status, gin.H{"id": 1})
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/debit", bytes.NewBufferString(tt.body))
+			if tt.key != "" {
+				req.Header.Set(IdempotencyKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			repositoryMock.AssertExpectations(t)
+			assert.Equal(t, tt.handlerRuns, handlerRuns)
+			tt.assertFunc(t, w)
+		})
+	}
+}
+
+// idempotencyStore keeps the records in memory, Save fails while saveErr is set
+type idempotencyStore struct {
+	records map[string]models.IdempotencyRecord
+	saveErr error
+}
+
+func (store *idempotencyStore) Get(key string) (*models.IdempotencyRecord, error) {
+	record, ok := store.records[key]
+	if !ok {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+func (store *idempotencyStore) Reserve(record models.IdempotencyRecord) (bool, error) {
+	if _, ok := store.records[record.Key]; ok {
+		return false, nil
+	}
+	store.records[record.Key] = record
+	return true, nil
+}
+
+func (store *idempotencyStore) Save(record models.IdempotencyRecord) error {
+	if store.saveErr != nil {
+		return store.saveErr
+	}
+	store.records[record.Key] = record
+	return nil
+}
+
+func (store *idempotencyStore) Delete(key string) error {
+	delete(store.records, key)
+	return nil
+}
+
+func TestIdempotency_SaveFailsAfterCommit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := &idempotencyStore{records: map[string]models.IdempotencyRecord{}, saveErr: errors.New("connection lost")}
+	executions := 0
+	r := gin.New()
+	r.POST("/debit", Idempotency(store), func(c *gin.Context) {
+		executions++
+		c.JSON(http.StatusCreated, gin.H{"id": executions})
+	})
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/debit", bytes.NewBufferString(`{"amount": 10}`))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusCreated, send().Code)
+
+	// the debit committed but its response wasn't stored, the retry must not debit again
+	store.saveErr = nil
+	retry := send()
+	assert.Equal(t, http.StatusConflict, retry.Code)
+	assert.Equal(t, 1, executions)
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wallet-api/cmd/web/services"
+	"net/http"
+)
+
+type IJournalHandler interface {
+	TrialBalance(c *gin.Context)
+}
+
+type JournalHandler struct {
+	journalService services.IJournalService
+}
+
+func (handler *JournalHandler) TrialBalance(c *gin.Context) {
+	trialBalance, err := handler.journalService.TrialBalance()
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, trialBalance)
+}
+
+func NewJournalHandler() IJournalHandler {
+	return &JournalHandler{
+		journalService: services.NewJournalService(),
+	}
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/services"
+	"net/http"
+	"strconv"
+)
+
+type IOwnerHandler interface {
+	Create(c *gin.Context)
+	Get(c *gin.Context)
+	GetWallets(c *gin.Context)
+	GetBalance(c *gin.Context)
+}
+
+type OwnerHandler struct {
+	ownerService services.IOwnerService
+}
+
+func (handler *OwnerHandler) Create(c *gin.Context) {
+	var createOwnerRequest models.CreateOwnerRequest
+	if err := c.Bind(&createOwnerRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	owner, err := handler.ownerService.Create(createOwnerRequest)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, owner)
+}
+
+func (handler *OwnerHandler) Get(c *gin.Context) {
+	ownerIdParam := c.Params.ByName("owner_id")
+	ownerId, err := strconv.Atoi(ownerIdParam)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	owner, err := handler.ownerService.Get(ownerId)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, owner)
+}
+
+func (handler *OwnerHandler) GetWallets(c *gin.Context) {
+	ownerIdParam := c.Params.ByName("owner_id")
+	ownerId, err := strconv.Atoi(ownerIdParam)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	wallets, err := handler.ownerService.GetWallets(ownerId)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"wallets": wallets})
+}
+
+func (handler *OwnerHandler) GetBalance(c *gin.Context) {
+	ownerIdParam := c.Params.ByName("owner_id")
+	ownerId, err := strconv.Atoi(ownerIdParam)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	balance, err := handler.ownerService.GetBalance(ownerId)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, balance)
+}
+
+func NewOwnerHandler() IOwnerHandler {
+	return &OwnerHandler{
+		ownerService: services.NewOwnerService(),
+	}
+}
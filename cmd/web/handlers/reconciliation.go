@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/services"
+	"net/http"
+)
+
+type IReconciliationHandler interface {
+	Reconcile(c *gin.Context)
+}
+
+type ReconciliationHandler struct {
+	reconciliationService services.IReconciliationService
+}
+
+func (handler *ReconciliationHandler) Reconcile(c *gin.Context) {
+	var options models.ReconciliationOptions
+	if err := c.ShouldBindQuery(&options); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	// a run can take long on many wallets, it goes on in the background and writes its report
+	started, err := handler.reconciliationService.Start(options)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, started)
+}
+
+func NewReconciliationHandler() IReconciliationHandler {
+	return &ReconciliationHandler{
+		reconciliationService: services.NewReconciliationService(),
+	}
+}
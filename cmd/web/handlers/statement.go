@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/services"
+	"net/http"
+	"strconv"
+)
+
+type IStatementHandler interface {
+	Export(c *gin.Context)
+}
+
+type StatementHandler struct {
+	statementService services.IStatementService
+}
+
+var statementContentTypes = map[string]string{
+	models.StatementFormatJSON: "application/json",
+	models.StatementFormatCSV:  "text/csv",
+	models.StatementFormatOFX:  "application/x-ofx",
+}
+
+func (handler *StatementHandler) Export(c *gin.Context) {
+	walletIdParam := c.Params.ByName("wallet_id")
+	walletId, err := strconv.Atoi(walletIdParam)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	var statementRequest models.StatementRequest
+	if err := c.ShouldBindQuery(&statementRequest); err != nil || !statementRequest.To.After(statementRequest.From) {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+	if statementRequest.Format == "" {
+		statementRequest.Format = models.StatementFormatJSON
+	}
+
+	out := &statementResponse{
+		context:     c,
+		contentType: statementContentTypes[statementRequest.Format],
+		filename:    fmt.Sprintf("statement-%d.%s", walletId, statementRequest.Format),
+	}
+	err = handler.statementService.Export(walletId, statementRequest, out)
+	if err == nil {
+		return
+	}
+	if !out.started {
+		handlerException(c, err)
+		return
+	}
+	// the status was already sent, the client gets a truncated statement
+	logrus.Errorf("couldn't finish statement of wallet %d: %v", walletId, err)
+}
+
+// statementResponse sends the download headers on the first write, so errors raised before
+// anything is written can still be answered with a regular error response
+type statementResponse struct {
+	context     *gin.Context
+	contentType string
+	filename    string
+	started     bool
+}
+
+func (response *statementResponse) Write(p []byte) (int, error) {
+	if !response.started {
+		response.started = true
+		header := response.context.Writer.Header()
+		header.Set("Content-Type", response.contentType)
+		header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", response.filename))
+		response.context.Status(http.StatusOK)
+	}
+	n, err := response.context.Writer.Write(p)
+	response.context.Writer.Flush()
+	return n, err
+}
+
+func NewStatementHandler() IStatementHandler {
+	return &StatementHandler{
+		statementService: services.NewStatementService(),
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wallet-api/cmd/web/services"
+	"net/http"
+)
+
+type ISystemAccountHandler interface {
+	GetBalances(c *gin.Context)
+}
+
+type SystemAccountHandler struct {
+	systemAccountService services.ISystemAccountService
+}
+
+func (handler *SystemAccountHandler) GetBalances(c *gin.Context) {
+	balances, err := handler.systemAccountService.GetBalances()
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"accounts": balances})
+}
+
+func NewSystemAccountHandler() ISystemAccountHandler {
+	return &SystemAccountHandler{
+		systemAccountService: services.NewSystemAccountService(),
+	}
+}
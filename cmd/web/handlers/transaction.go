@@ -2,18 +2,22 @@ package handlers
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 	"github.com/wallet-api/cmd/web/models"
 	"github.com/wallet-api/cmd/web/services"
 	"github.com/wallet-api/exceptions"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type ITransactionHandler interface {
 	GetBalance(c *gin.Context)
 	Debit(c *gin.Context)
 	Credit(c *gin.Context)
+	GetTransactions(c *gin.Context)
+	Reverse(c *gin.Context)
 }
 
 type TransactionHandler struct {
@@ -25,6 +29,11 @@ const (
 	ErrorCodeInvalidParamsPositive string = "the amount must be positive"
 )
 
+const (
+	defaultTransactionsLimit int = 50
+	maxTransactionsLimit     int = 500
+)
+
 func (handler *TransactionHandler) GetBalance(c *gin.Context) {
 	walletIdParam := c.Params.ByName("wallet_id")
 	walletId, err := strconv.Atoi(walletIdParam)
@@ -32,13 +41,29 @@ func (handler *TransactionHandler) GetBalance(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"code": ErrorCodeInvalidParams})
 		return
 	}
+
+	if atParam, ok := c.GetQuery("at"); ok {
+		at, err := time.Parse(time.RFC3339, atParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+			return
+		}
+		balance, err := handler.transactionService.GetBalanceAt(walletId, at)
+		if err != nil {
+			handlerException(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, balance)
+		return
+	}
+
 	balance, err := handler.transactionService.GetBalance(walletId)
 	if err != nil {
 		handlerException(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"balance": balance})
+	c.JSON(http.StatusOK, balance)
 }
 
 func (handler *TransactionHandler) Debit(c *gin.Context) {
@@ -55,7 +80,7 @@ func (handler *TransactionHandler) Debit(c *gin.Context) {
 		return
 	}
 
-	if err := handler.transactionService.Debit(walletId, walletRequest.Amount); err != nil {
+	if err := handler.transactionService.Debit(walletId, walletRequest); err != nil {
 		handlerException(c, err)
 		return
 	}
@@ -77,7 +102,7 @@ func (handler *TransactionHandler) Credit(c *gin.Context) {
 		return
 	}
 
-	if err := handler.transactionService.Credit(walletId, walletRequest.Amount); err != nil {
+	if err := handler.transactionService.Credit(walletId, walletRequest); err != nil {
 		handlerException(c, err)
 		return
 	}
@@ -85,13 +110,112 @@ func (handler *TransactionHandler) Credit(c *gin.Context) {
 	c.JSON(http.StatusNoContent, nil)
 }
 
+func (handler *TransactionHandler) GetTransactions(c *gin.Context) {
+	walletIdParam := c.Params.ByName("wallet_id")
+	walletId, err := strconv.Atoi(walletIdParam)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	filter, ok := transactionFilter(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	transactions, err := handler.transactionService.GetTransactions(walletId, filter)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"transactions": transactions})
+}
+
+// transactionFilter reads the search of the transaction list from the query string, metadata pairs
+// are sent as metadata[key]=value
+func transactionFilter(c *gin.Context) (models.TransactionFilter, bool) {
+	filter := models.TransactionFilter{
+		ExternalReference: c.Query("reference"),
+		Metadata:          c.QueryMap("metadata"),
+	}
+
+	var err error
+	filter.Limit, err = strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultTransactionsLimit)))
+	if err != nil || filter.Limit <= 0 || filter.Limit > maxTransactionsLimit {
+		return filter, false
+	}
+	filter.Offset, err = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || filter.Offset < 0 {
+		return filter, false
+	}
+
+	for param, amount := range map[string]**decimal.Decimal{"min_amount": &filter.MinAmount, "max_amount": &filter.MaxAmount} {
+		if value, ok := c.GetQuery(param); ok {
+			parsed, err := decimal.NewFromString(value)
+			if err != nil {
+				return filter, false
+			}
+			*amount = &parsed
+		}
+	}
+	for param, date := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		if value, ok := c.GetQuery(param); ok {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, false
+			}
+			*date = &parsed
+		}
+	}
+	return filter, true
+}
+
+func (handler *TransactionHandler) Reverse(c *gin.Context) {
+	transactionIdParam := c.Params.ByName("transaction_id")
+	transactionId, err := strconv.Atoi(transactionIdParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	// the body is optional, an empty one reverses the remaining amount
+	var reversalRequest models.ReversalRequest
+	if c.Request.ContentLength > 0 {
+		if err = c.Bind(&reversalRequest); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+			return
+		}
+	}
+
+	reversal, err := handler.transactionService.Reverse(transactionId, reversalRequest)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, reversal)
+}
+
 func handlerException(c *gin.Context, err error) {
 	switch err.(type) {
 	case *exceptions.NotFoundException:
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	case *exceptions.ForbiddenException:
-		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		forbidden := err.(*exceptions.ForbiddenException)
+		response := gin.H{"error": err.Error()}
+		if forbidden.Code != "" {
+			response["code"] = forbidden.Code
+		}
+		if forbidden.Details != nil {
+			response["details"] = forbidden.Details
+		}
+		c.JSON(http.StatusForbidden, response)
+		return
+	case *exceptions.ConflictException:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		return
 	default:
 		logrus.Error(err)
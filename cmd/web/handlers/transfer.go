@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/services"
+	"net/http"
+)
+
+type ITransferHandler interface {
+	Transfer(c *gin.Context)
+}
+
+type TransferHandler struct {
+	transferService services.ITransferService
+}
+
+func (handler *TransferHandler) Transfer(c *gin.Context) {
+	var transferRequest models.TransferRequest
+	if err := c.Bind(&transferRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	reference, err := handler.transferService.Transfer(transferRequest)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"reference": reference})
+}
+
+func NewTransferHandler() ITransferHandler {
+	return &TransferHandler{
+		transferService: services.NewTransferService(),
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/services"
+	"net/http"
+	"strconv"
+)
+
+type IWalletHandler interface {
+	Create(c *gin.Context)
+	Get(c *gin.Context)
+	Freeze(c *gin.Context)
+	Unfreeze(c *gin.Context)
+	Close(c *gin.Context)
+	SetOverdraftLimit(c *gin.Context)
+	GetOverdraftLimitChanges(c *gin.Context)
+	SetVelocityLimits(c *gin.Context)
+	GetVelocityLimits(c *gin.Context)
+}
+
+// AuthenticatedUserHeader carries the caller authenticated by the API gateway, which must drop any
+// value sent by the client. It is the actor recorded in the audit trails
+const AuthenticatedUserHeader string = "X-Authenticated-User"
+
+const ErrorCodeUnauthenticated string = "the caller is not authenticated"
+
+type WalletHandler struct {
+	walletService services.IWalletService
+}
+
+func (handler *WalletHandler) Create(c *gin.Context) {
+	var createWalletRequest models.CreateWalletRequest
+	if err := c.Bind(&createWalletRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	wallet, err := handler.walletService.Create(createWalletRequest)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, wallet)
+}
+
+func (handler *WalletHandler) Get(c *gin.Context) {
+	walletIdParam := c.Params.ByName("wallet_id")
+	walletId, err := strconv.Atoi(walletIdParam)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	wallet, err := handler.walletService.Get(walletId)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, wallet)
+}
+
+func (handler *WalletHandler) Freeze(c *gin.Context) {
+	handler.changeStatus(c, handler.walletService.Freeze)
+}
+
+func (handler *WalletHandler) Unfreeze(c *gin.Context) {
+	handler.changeStatus(c, handler.walletService.Unfreeze)
+}
+
+func (handler *WalletHandler) Close(c *gin.Context) {
+	handler.changeStatus(c, handler.walletService.Close)
+}
+
+func (handler *WalletHandler) SetOverdraftLimit(c *gin.Context) {
+	walletIdParam := c.Params.ByName("wallet_id")
+	walletId, err := strconv.Atoi(walletIdParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	actor := c.GetHeader(AuthenticatedUserHeader)
+	if actor == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"code": ErrorCodeUnauthenticated})
+		return
+	}
+
+	var overdraftLimitRequest models.OverdraftLimitRequest
+	if err = c.Bind(&overdraftLimitRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+	overdraftLimitRequest.ChangedBy = actor
+
+	wallet, err := handler.walletService.SetOverdraftLimit(walletId, overdraftLimitRequest)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, wallet)
+}
+
+func (handler *WalletHandler) GetOverdraftLimitChanges(c *gin.Context) {
+	walletIdParam := c.Params.ByName("wallet_id")
+	walletId, err := strconv.Atoi(walletIdParam)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	changes, err := handler.walletService.GetOverdraftLimitChanges(walletId)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"changes": changes})
+}
+
+func (handler *WalletHandler) SetVelocityLimits(c *gin.Context) {
+	walletIdParam := c.Params.ByName("wallet_id")
+	walletId, err := strconv.Atoi(walletIdParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	var velocityLimitsRequest models.VelocityLimitsRequest
+	if err = c.Bind(&velocityLimitsRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	limits, err := handler.walletService.SetVelocityLimits(walletId, velocityLimitsRequest)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"limits": limits})
+}
+
+func (handler *WalletHandler) GetVelocityLimits(c *gin.Context) {
+	walletIdParam := c.Params.ByName("wallet_id")
+	walletId, err := strconv.Atoi(walletIdParam)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	limits, err := handler.walletService.GetVelocityLimits(walletId)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"limits": limits})
+}
+
+func (handler *WalletHandler) changeStatus(c *gin.Context, transition func(walletId int) (models.Wallet, error)) {
+	walletIdParam := c.Params.ByName("wallet_id")
+	walletId, err := strconv.Atoi(walletIdParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	wallet, err := transition(walletId)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, wallet)
+}
+
+func NewWalletHandler() IWalletHandler {
+	return &WalletHandler{
+		walletService: services.NewWalletService(),
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// overdraftWalletService records the overdraft limit requests reaching the service
+type overdraftWalletService struct {
+	services.IWalletService
+	requests []models.OverdraftLimitRequest
+}
+
+func (service *overdraftWalletService) SetOverdraftLimit(walletId int, request models.OverdraftLimitRequest) (models.Wallet, error) {
+	service.requests = append(service.requests, request)
+	return models.Wallet{OverdraftLimit: request.Limit}, nil
+}
+
+func TestWalletHandler_SetOverdraftLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		actor      string
+		body       string
+		status     int
+		assertFunc func(*testing.T, []models.OverdraftLimitRequest)
+	}{
+		{
+			name:   "Success - the actor comes from the gateway header, not the body",
+			actor:  "risk-team",
+			body:   `{"limit": 100, "changed_by": "someone-else"}`,
+			status: http.StatusOK,
+			assertFunc: func(t *testing.T, requests []models.OverdraftLimitRequest) {
+				assert.Len(t, requests, 1)
+				assert.Equal(t, "risk-team", requests[0].ChangedBy)
+			},
+		},
+		{
+			name:   "Error - unauthenticated caller",
+			body:   `{"limit": 100, "changed_by": "risk-team"}`,
+			status: http.StatusUnauthorized,
+			assertFunc: func(t *testing.T, requests []models.OverdraftLimitRequest) {
+				assert.Empty(t, requests)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := &overdraftWalletService{}
+			handler := WalletHandler{walletService: service}
+			r := gin.New()
+			r.PUT("/api/v1/admin/wallets/:wallet_id/overdraft-limit", handler.SetOverdraftLimit)
+
+			request := httptest.NewRequest(http.MethodPut, "/api/v1/admin/wallets/1/overdraft-limit", bytes.NewBufferString(tt.body))
+			request.Header.Set("Content-Type", "application/json")
+			if tt.actor != "" {
+				request.Header.Set(AuthenticatedUserHeader, tt.actor)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, request)
+
+			assert.Equal(t, tt.status, w.Code)
+			tt.assertFunc(t, service.requests)
+		})
+	}
+}
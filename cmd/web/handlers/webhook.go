@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/services"
+	"net/http"
+	"strconv"
+)
+
+type IWebhookHandler interface {
+	Create(c *gin.Context)
+	GetDeadLetters(c *gin.Context)
+	GetAttempts(c *gin.Context)
+	Replay(c *gin.Context)
+}
+
+type WebhookHandler struct {
+	webhookService services.IWebhookService
+}
+
+// Create serves POST /api/v1/webhooks with the wallet_id in the body, and its alias
+// POST /api/v1/wallets/:wallet_id/webhooks with the wallet in the path
+func (handler *WebhookHandler) Create(c *gin.Context) {
+	var createWebhookRequest models.CreateWebhookRequest
+	if err := c.Bind(&createWebhookRequest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	walletId := createWebhookRequest.WalletID
+	if walletIdParam := c.Params.ByName("wallet_id"); walletIdParam != "" {
+		var err error
+		if walletId, err = strconv.Atoi(walletIdParam); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+			return
+		}
+	}
+	if walletId < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	subscription, err := handler.webhookService.Subscribe(walletId, createWebhookRequest)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, subscription)
+}
+
+func (handler *WebhookHandler) GetDeadLetters(c *gin.Context) {
+	deliveries, err := handler.webhookService.GetDeadLetters()
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+func (handler *WebhookHandler) GetAttempts(c *gin.Context) {
+	deliveryIdParam := c.Params.ByName("delivery_id")
+	deliveryId, err := strconv.Atoi(deliveryIdParam)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	attempts, err := handler.webhookService.GetAttempts(deliveryId)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"attempts": attempts})
+}
+
+func (handler *WebhookHandler) Replay(c *gin.Context) {
+	deliveryIdParam := c.Params.ByName("delivery_id")
+	deliveryId, err := strconv.Atoi(deliveryIdParam)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"code": ErrorCodeInvalidParams})
+		return
+	}
+
+	delivery, err := handler.webhookService.Replay(deliveryId)
+	if err != nil {
+		handlerException(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+func NewWebhookHandler() IWebhookHandler {
+	return &WebhookHandler{
+		webhookService: services.NewWebhookService(),
+	}
+}
@@ -16,6 +16,10 @@ func main() {
 func StartApp() {
 	initLog()
 	readConfiguration()
+	if runCommand(flag.Args()) {
+		return
+	}
+	startWorkers()
 	startWebServer()
 }
 
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+const (
+	BatchModeAtomic     string = "atomic"
+	BatchModeBestEffort string = "best_effort"
+)
+
+const (
+	BatchStatusPending    string = "pending"
+	BatchStatusProcessing string = "processing"
+	BatchStatusCompleted  string = "completed"
+	BatchStatusFailed     string = "failed"
+)
+
+const (
+	BatchItemTypeDebit    string = "debit"
+	BatchItemTypeCredit   string = "credit"
+	BatchItemTypeTransfer string = "transfer"
+)
+
+const (
+	BatchItemStatusPending    string = "pending"
+	BatchItemStatusSucceeded  string = "succeeded"
+	BatchItemStatusFailed     string = "failed"
+	BatchItemStatusRolledBack string = "rolled_back"
+)
+
+type BatchItemRequest struct {
+	Type string `json:"type" binding:"required,oneof=debit credit transfer"`
+	// WalletID is used by debits and credits, SourceWalletID and DestinationWalletID by transfers
+	WalletID            int             `json:"wallet_id"`
+	SourceWalletID      int             `json:"source_wallet_id"`
+	DestinationWalletID int             `json:"destination_wallet_id"`
+	Amount              decimal.Decimal `json:"amount" binding:"required"`
+	Currency            string          `json:"currency"`
+}
+
+type BatchRequest struct {
+	Mode  string             `json:"mode" binding:"required,oneof=atomic best_effort"`
+	Items []BatchItemRequest `json:"items" binding:"required,min=1,dive"`
+}
+
+// Batch groups debit, credit and transfer instructions. Atomic batches post every item in a single
+// database transaction, best effort batches post each item on its own and report it separately.
+type Batch struct {
+	ID             uint        `json:"id" gorm:"primary_key"`
+	Mode           string      `json:"mode" gorm:"type:varchar(20);not null"`
+	Status         string      `json:"status" gorm:"type:varchar(20);index:idx_batches_status_updated_at;not null"`
+	TotalItems     int         `json:"total_items"`
+	SucceededItems int         `json:"succeeded_items"`
+	FailedItems    int         `json:"failed_items"`
+	Items          []BatchItem `json:"items,omitempty"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at" gorm:"index:idx_batches_status_updated_at"`
+}
+
+type BatchItem struct {
+	ID                  uint            `json:"-" gorm:"primary_key"`
+	BatchID             uint            `json:"-" gorm:"index;not null"`
+	Position            int             `json:"position"`
+	Type                string          `json:"type" gorm:"type:varchar(10);not null"`
+	WalletID            int             `json:"wallet_id,omitempty"`
+	SourceWalletID      int             `json:"source_wallet_id,omitempty"`
+	DestinationWalletID int             `json:"destination_wallet_id,omitempty"`
+	Amount              decimal.Decimal `json:"amount" sql:"type:decimal(20,8)"`
+	Currency            string          `json:"currency,omitempty" gorm:"type:char(3)"`
+	Status              string          `json:"status" gorm:"type:varchar(20);not null"`
+	OperationReference  string          `json:"operation_reference,omitempty" gorm:"type:varchar(64)"`
+	ErrorCode           string          `json:"error_code,omitempty" gorm:"type:varchar(64)"`
+	Error               string          `json:"error,omitempty" gorm:"type:varchar(255)"`
+}
@@ -0,0 +1,19 @@
+package models
+
+// currencyMinorUnits maps the supported ISO 4217 currency codes to their number of decimal places
+var currencyMinorUnits = map[string]int32{
+	"ARS": 2,
+	"BRL": 2,
+	"CLP": 0,
+	"EUR": 2,
+	"GBP": 2,
+	"JPY": 0,
+	"USD": 2,
+	"UYU": 2,
+}
+
+// MinorUnits returns the decimal places allowed by the currency, reporting false if it is not supported
+func MinorUnits(currency string) (int32, bool) {
+	units, ok := currencyMinorUnits[currency]
+	return units, ok
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"encoding/json"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// wallet event types, in event sourced mode they are the source of truth the wallet rows are projected from
+const (
+	WalletEventOpened   string = "WalletOpened"
+	WalletEventCredited string = "Credited"
+	WalletEventDebited  string = "Debited"
+	WalletEventFrozen   string = "Frozen"
+	WalletEventUnfrozen string = "Unfrozen"
+	WalletEventClosed   string = "Closed"
+)
+
+// WalletEvent is an entry of the wallet event stream, Sequence numbers the events of each wallet
+// from 1 without gaps
+type WalletEvent struct {
+	ID        uint   `json:"id" gorm:"primary_key"`
+	WalletID  uint   `json:"wallet_id" gorm:"unique_index:idx_wallet_events_wallet_sequence;not null"`
+	Sequence  uint   `json:"sequence" gorm:"unique_index:idx_wallet_events_wallet_sequence;not null"`
+	Type      string `json:"type" gorm:"type:varchar(20);not null"`
+	Payload   string `json:"payload" gorm:"type:text;not null"`
+	CreatedAt time.Time
+}
+
+// WalletEventData is the payload of the wallet events, each type only sets the fields it needs
+type WalletEventData struct {
+	// WalletOpened carries the state the wallet had when its stream started, wallets created before
+	// the event sourced mode open with their balance at that moment
+	Currency      string           `json:"currency,omitempty"`
+	Product       string           `json:"product,omitempty"`
+	OwnerID       *uint            `json:"owner_id,omitempty"`
+	SystemAccount *string          `json:"system_account,omitempty"`
+	Status        string           `json:"status,omitempty"`
+	Balance       *decimal.Decimal `json:"balance,omitempty"`
+	// Credited and Debited carry the amount and the ledger entry that moved it
+	Amount        *decimal.Decimal `json:"amount,omitempty"`
+	TransactionID *uint            `json:"transaction_id,omitempty"`
+}
+
+func NewWalletEvent(eventType string, data WalletEventData) (WalletEvent, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return WalletEvent{}, err
+	}
+	return WalletEvent{Type: eventType, Payload: string(payload)}, nil
+}
+
+func (event WalletEvent) Data() (WalletEventData, error) {
+	var data WalletEventData
+	err := json.Unmarshal([]byte(event.Payload), &data)
+	return data, err
+}
+
+type ReplayRun struct {
+	Wallets int `json:"wallets"`
+	Events  int `json:"events"`
+	// Rebuilt counts the wallets whose row disagreed with their events and was overwritten
+	Rebuilt       int    `json:"rebuilt"`
+	FailedWallets []uint `json:"failed_wallets"`
+}
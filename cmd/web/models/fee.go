@@ -0,0 +1,86 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+const (
+	FeeOperationDebit    string = "debit"
+	FeeOperationCredit   string = "credit"
+	FeeOperationTransfer string = "transfer"
+)
+
+const (
+	FeeTypeFixed      string = "fixed"
+	FeeTypePercentage string = "percentage"
+	FeeTypeTiered     string = "tiered"
+)
+
+type FeeQuoteRequest struct {
+	Operation string `json:"operation" binding:"required,oneof=debit credit transfer"`
+	// WalletID is the wallet paying the fee, the source wallet of a transfer
+	WalletID int             `json:"wallet_id" binding:"required"`
+	Amount   decimal.Decimal `json:"amount" binding:"required"`
+}
+
+type FeeQuote struct {
+	Operation string          `json:"operation"`
+	WalletID  uint            `json:"wallet_id"`
+	Amount    decimal.Decimal `json:"amount"`
+	Fee       decimal.Decimal `json:"fee"`
+	Currency  string          `json:"currency"`
+}
+
+// FeeRule prices an operation for the wallets of a product and currency, empty ones match any.
+// Fixed rules charge Amount, percentage rules charge Rate times the operation amount and tiered rules
+// apply the first tier covering the operation amount. Min and Max bound the fee of any rule type.
+type FeeRule struct {
+	Operation string
+	Product   string
+	Currency  string
+	Type      string
+	Amount    decimal.Decimal
+	Rate      decimal.Decimal
+	Min       decimal.NullDecimal
+	Max       decimal.NullDecimal
+	Tiers     []FeeTier
+}
+
+// FeeTier charges Amount plus Rate times the operation amount for operations up to UpTo, the last
+// tier usually leaves UpTo unset to cover any amount
+type FeeTier struct {
+	UpTo   decimal.NullDecimal
+	Amount decimal.Decimal
+	Rate   decimal.Decimal
+}
+
+func (rule FeeRule) Matches(operation string, wallet Wallet) bool {
+	return rule.Operation == operation &&
+		(rule.Product == "" || rule.Product == wallet.Product) &&
+		(rule.Currency == "" || rule.Currency == wallet.Currency)
+}
+
+func (rule FeeRule) Fee(amount decimal.Decimal) decimal.Decimal {
+	var fee decimal.Decimal
+	switch rule.Type {
+	case FeeTypeFixed:
+		fee = rule.Amount
+	case FeeTypePercentage:
+		fee = amount.Mul(rule.Rate)
+	case FeeTypeTiered:
+		for _, tier := range rule.Tiers {
+			if !tier.UpTo.Valid || amount.LessThanOrEqual(tier.UpTo.Decimal) {
+				fee = tier.Amount.Add(amount.Mul(tier.Rate))
+				break
+			}
+		}
+	}
+
+	if rule.Min.Valid && fee.LessThan(rule.Min.Decimal) {
+		fee = rule.Min.Decimal
+	}
+	if rule.Max.Valid && fee.GreaterThan(rule.Max.Decimal) {
+		fee = rule.Max.Decimal
+	}
+	return fee
+}
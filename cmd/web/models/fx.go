@@ -0,0 +1,37 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+type FXQuoteRequest struct {
+	SourceCurrency string          `json:"source_currency" binding:"required"`
+	TargetCurrency string          `json:"target_currency" binding:"required"`
+	Amount         decimal.Decimal `json:"amount" binding:"required"`
+}
+
+type FXConversionRequest struct {
+	QuoteID             string `json:"quote_id" binding:"required"`
+	SourceWalletID      int    `json:"source_wallet_id" binding:"required"`
+	DestinationWalletID int    `json:"destination_wallet_id" binding:"required"`
+}
+
+// FXRateScale is the scale of the FXQuote.Rate column. Rates are rounded to it before the target amount
+// is computed, so the stored quote reproduces the amount it posts
+const FXRateScale int32 = 8
+
+// FXQuote fixes the rate at which SourceAmount converts into TargetAmount until it expires.
+// A quote can be redeemed by a single conversion.
+type FXQuote struct {
+	ID             uint            `json:"-" gorm:"primary_key"`
+	Reference      string          `json:"id" gorm:"type:varchar(64);unique_index;not null"`
+	SourceCurrency string          `json:"source_currency" gorm:"type:char(3);not null"`
+	TargetCurrency string          `json:"target_currency" gorm:"type:char(3);not null"`
+	Rate           decimal.Decimal `json:"rate" sql:"type:decimal(20,8)"`
+	SourceAmount   decimal.Decimal `json:"source_amount" sql:"type:decimal(20,8)"`
+	TargetAmount   decimal.Decimal `json:"target_amount" sql:"type:decimal(20,8)"`
+	ExpiresAt      time.Time       `json:"expires_at"`
+	RedeemedAt     *time.Time      `json:"redeemed_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
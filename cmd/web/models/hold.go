@@ -0,0 +1,39 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+const (
+	HoldStatusActive   string = "active"
+	HoldStatusCaptured string = "captured"
+	HoldStatusVoided   string = "voided"
+	HoldStatusExpired  string = "expired"
+)
+
+type HoldRequest struct {
+	Amount decimal.Decimal `json:"amount" binding:"required"`
+	// Currency is optional, when sent it must match the wallet currency
+	Currency string `json:"currency"`
+	// ExpiresIn is the number of seconds the hold lives if not captured, defaults to holds.default_ttl
+	ExpiresIn int `json:"expires_in"`
+}
+
+type CaptureRequest struct {
+	// Amount is optional, the full hold is captured when it is not sent
+	Amount *decimal.Decimal `json:"amount"`
+}
+
+// Hold reserves part of a wallet balance until it is captured as a debit, voided or it expires
+type Hold struct {
+	ID             uint            `json:"id" gorm:"primary_key"`
+	WalletID       uint            `json:"wallet_id" gorm:"index;not null"`
+	Amount         decimal.Decimal `json:"amount" sql:"type:decimal(20,8)"`
+	CapturedAmount decimal.Decimal `json:"captured_amount" sql:"type:decimal(20,8);not null;default:0"`
+	Currency       string          `json:"currency" gorm:"type:char(3);not null"`
+	Status         string          `json:"status" gorm:"type:varchar(10);index:idx_holds_status_expires_at;not null"`
+	ExpiresAt      time.Time       `json:"expires_at" gorm:"index:idx_holds_status_expires_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
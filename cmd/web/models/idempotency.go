@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// IdempotencyRecord stores the outcome of the first request sent with an Idempotency-Key, so
+// retries carrying the same key can be answered without executing the operation again
+type IdempotencyRecord struct {
+	Key         string    `json:"key" gorm:"type:varchar(255);primary_key"`
+	RequestHash string    `json:"request_hash" gorm:"type:varchar(64);not null"`
+	Completed   bool      `json:"completed"`
+	StatusCode  int       `json:"status_code"`
+	Body        string    `json:"body" gorm:"type:text"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// day count conventions, the number of days the annual rate is divided by
+const (
+	DayCountActual365    string = "actual/365"
+	DayCountActual360    string = "actual/360"
+	DayCountActualActual string = "actual/actual"
+)
+
+// InterestAccrual is the interest a savings wallet earned on one UTC day, kept at full precision until
+// the monthly payout. There is a single accrual per wallet and day, so the job can safely run again.
+type InterestAccrual struct {
+	ID       uint      `json:"id" gorm:"primary_key"`
+	WalletID uint      `json:"wallet_id" gorm:"unique_index:idx_interest_accruals_wallet_day;not null"`
+	Day      time.Time `json:"day" gorm:"type:date;unique_index:idx_interest_accruals_wallet_day;not null"`
+	// Residual accruals carry the part of a payout below the currency minor unit to the next payout,
+	// they are dated on the first day of the month they are paid with and earn nothing themselves
+	Residual bool `json:"residual" gorm:"unique_index:idx_interest_accruals_wallet_day;not null;default:false"`
+	// Balance is the end of day balance the interest was computed on
+	Balance    decimal.Decimal `json:"balance" sql:"type:decimal(20,8)"`
+	AnnualRate decimal.Decimal `json:"annual_rate" sql:"type:decimal(12,8)"`
+	DayCount   string          `json:"day_count" gorm:"type:varchar(20);not null"`
+	Amount     decimal.Decimal `json:"amount" sql:"type:decimal(36,18)"`
+	// PayoutTransactionID is the interest credit that paid the accrual, null until it is paid
+	PayoutTransactionID *uint     `json:"payout_transaction_id" gorm:"index"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+type InterestRun struct {
+	// Through is the last day accrued
+	Through       time.Time `json:"through"`
+	Accruals      int       `json:"accruals"`
+	Payouts       int       `json:"payouts"`
+	FailedWallets []uint    `json:"failed_wallets"`
+}
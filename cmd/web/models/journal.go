@@ -0,0 +1,125 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+	"sort"
+	"time"
+)
+
+// accounts of the chart the journal posts to. Customer wallet postings use AccountWallets and system
+// account postings their type, both together with the wallet id. The other accounts have no wallet.
+const (
+	AccountWallets         string = "wallets"
+	AccountFXPosition      string = "fx_position"
+	AccountInterestExpense string = "interest_expense"
+)
+
+// JournalEntry is the double-entry record of one operation. Its postings move money between wallets
+// and system accounts and add up to zero in every currency, so money is never created nor destroyed.
+type JournalEntry struct {
+	ID                 uint      `json:"id" gorm:"primary_key"`
+	OperationReference string    `json:"operation_reference" gorm:"type:varchar(64);index;not null"`
+	Type               string    `json:"type" gorm:"type:varchar(20);not null"`
+	Postings           []Posting `json:"postings"`
+	CreatedAt          time.Time `json:"created_at"`
+}
+
+// Posting is one line of a journal entry, credits are positive and debits negative. Wallet postings
+// point to the ledger entry that changed the wallet balance.
+type Posting struct {
+	ID             uint            `json:"id" gorm:"primary_key"`
+	JournalEntryID uint            `json:"journal_entry_id" gorm:"index;not null"`
+	Account        string          `json:"account" gorm:"type:varchar(40);index:idx_postings_account_currency;not null"`
+	Currency       string          `json:"currency" gorm:"type:char(3);index:idx_postings_account_currency;not null"`
+	WalletID       *uint           `json:"wallet_id,omitempty" gorm:"index"`
+	TransactionID  *uint           `json:"transaction_id,omitempty" gorm:"index"`
+	Amount         decimal.Decimal `json:"amount" sql:"type:decimal(20,8)"`
+}
+
+// Imbalances returns what the postings add up to in each currency that doesn't sum to zero
+func (entry JournalEntry) Imbalances() map[string]decimal.Decimal {
+	totals := map[string]decimal.Decimal{}
+	for _, posting := range entry.Postings {
+		totals[posting.Currency] = totals[posting.Currency].Add(posting.Amount)
+	}
+	for currency, total := range totals {
+		if total.IsZero() {
+			delete(totals, currency)
+		}
+	}
+	return totals
+}
+
+// TrialBalanceLine totals the postings of an account in one currency
+type TrialBalanceLine struct {
+	Account  string          `json:"account"`
+	Currency string          `json:"currency"`
+	Debits   decimal.Decimal `json:"debits"`
+	Credits  decimal.Decimal `json:"credits"`
+	Balance  decimal.Decimal `json:"balance"`
+}
+
+// TrialBalanceTotal totals every account in one currency. Balance must be zero and the wallets account
+// must agree with the sum of the customer wallet balances for the books to balance.
+type TrialBalanceTotal struct {
+	Currency        string          `json:"currency"`
+	Debits          decimal.Decimal `json:"debits"`
+	Credits         decimal.Decimal `json:"credits"`
+	Balance         decimal.Decimal `json:"balance"`
+	WalletsAccount  decimal.Decimal `json:"wallets_account"`
+	WalletsBalances decimal.Decimal `json:"wallets_balances"`
+}
+
+// JournalBackfillRun reports a run of the journal backfill, an operation that failed is left without
+// postings and taken again by the next run
+type JournalBackfillRun struct {
+	Operations       int      `json:"operations"`
+	Entries          int      `json:"entries"`
+	FailedOperations []string `json:"failed_operations"`
+}
+
+type TrialBalance struct {
+	GeneratedAt time.Time           `json:"generated_at"`
+	Balanced    bool                `json:"balanced"`
+	Lines       []TrialBalanceLine  `json:"lines"`
+	Totals      []TrialBalanceTotal `json:"totals"`
+}
+
+// NewTrialBalance adds up the account lines and the wallet balances, both per currency
+func NewTrialBalance(lines []TrialBalanceLine, walletBalances map[string]decimal.Decimal, at time.Time) TrialBalance {
+	totals := map[string]*TrialBalanceTotal{}
+	total := func(currency string) *TrialBalanceTotal {
+		if _, ok := totals[currency]; !ok {
+			totals[currency] = &TrialBalanceTotal{Currency: currency}
+		}
+		return totals[currency]
+	}
+
+	for _, line := range lines {
+		currencyTotal := total(line.Currency)
+		currencyTotal.Debits = currencyTotal.Debits.Add(line.Debits)
+		currencyTotal.Credits = currencyTotal.Credits.Add(line.Credits)
+		currencyTotal.Balance = currencyTotal.Balance.Add(line.Balance)
+		if line.Account == AccountWallets {
+			currencyTotal.WalletsAccount = currencyTotal.WalletsAccount.Add(line.Balance)
+		}
+	}
+	for currency, balance := range walletBalances {
+		total(currency).WalletsBalances = balance
+	}
+
+	trialBalance := TrialBalance{GeneratedAt: at, Balanced: true, Lines: lines, Totals: []TrialBalanceTotal{}}
+	if trialBalance.Lines == nil {
+		trialBalance.Lines = []TrialBalanceLine{}
+	}
+	for _, currencyTotal := range totals {
+		if !currencyTotal.Balance.IsZero() || !currencyTotal.WalletsAccount.Equal(currencyTotal.WalletsBalances) {
+			trialBalance.Balanced = false
+		}
+		trialBalance.Totals = append(trialBalance.Totals, *currencyTotal)
+	}
+	sort.Slice(trialBalance.Totals, func(i, j int) bool {
+		return trialBalance.Totals[i].Currency < trialBalance.Totals[j].Currency
+	})
+	return trialBalance
+}
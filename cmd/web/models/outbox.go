@@ -0,0 +1,48 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// outbox event types, one per direction of the balance change
+const (
+	OutboxEventWalletCredited string = "wallet.credited"
+	OutboxEventWalletDebited  string = "wallet.debited"
+)
+
+// OutboxMessage is a balance change waiting to be published downstream. It is written in the same
+// database transaction as the change, so no change is lost when the process dies before publishing
+type OutboxMessage struct {
+	ID        uint   `json:"id" gorm:"primary_key"`
+	EventType string `json:"event_type" gorm:"type:varchar(50);not null"`
+	WalletID  uint   `json:"wallet_id" gorm:"not null"`
+	// Payload is the JSON of the ledger entry that changed the balance
+	Payload   string    `json:"payload" gorm:"type:text;not null"`
+	CreatedAt time.Time `json:"created_at"`
+	// DeliveredAt is null until the relay publishes the message
+	DeliveredAt *time.Time `json:"delivered_at" gorm:"index"`
+	// WebhooksQueuedAt is set once the webhook deliveries of the message are created, so retrying the
+	// publication doesn't queue them again
+	WebhooksQueuedAt *time.Time `json:"webhooks_queued_at"`
+	// NextAttemptAt is when the relay tries a failed message again, null until it fails
+	NextAttemptAt *time.Time `json:"next_attempt_at"`
+	// ParkedAt is set when the message failed too many times, the relay no longer publishes it
+	ParkedAt  *time.Time `json:"parked_at" gorm:"index"`
+	Attempts  int        `json:"attempts" gorm:"not null;default:0"`
+	LastError string     `json:"last_error,omitempty" gorm:"type:varchar(255)"`
+}
+
+// NewBalanceChangedMessage builds the outbox message announcing the ledger entry
+func NewBalanceChangedMessage(transaction Transaction) (OutboxMessage, error) {
+	payload, err := json.Marshal(transaction)
+	if err != nil {
+		return OutboxMessage{}, err
+	}
+
+	eventType := OutboxEventWalletCredited
+	if transaction.Direction == DirectionDebit {
+		eventType = OutboxEventWalletDebited
+	}
+	return OutboxMessage{EventType: eventType, WalletID: transaction.WalletID, Payload: string(payload)}, nil
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+type OverdraftLimitRequest struct {
+	Limit decimal.Decimal `json:"limit" binding:"required"`
+	// ChangedBy is the authenticated caller, set by the handler and never read from the body
+	ChangedBy string `json:"-"`
+	Reason    string `json:"reason"`
+}
+
+// OverdraftLimitChange is the audit trail entry written every time a wallet overdraft limit changes
+type OverdraftLimitChange struct {
+	ID            uint            `json:"id" gorm:"primary_key"`
+	WalletID      uint            `json:"wallet_id" gorm:"index;not null"`
+	PreviousLimit decimal.Decimal `json:"previous_limit" sql:"type:decimal(20,8)"`
+	NewLimit      decimal.Decimal `json:"new_limit" sql:"type:decimal(20,8)"`
+	ChangedBy     string          `json:"changed_by" gorm:"type:varchar(255);not null"`
+	Reason        string          `json:"reason" gorm:"type:varchar(255)"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	OwnerStatusActive    string = "active"
+	OwnerStatusSuspended string = "suspended"
+)
+
+type CreateOwnerRequest struct {
+	ExternalUserID string `json:"external_user_id" binding:"required,max=64"`
+	Name           string `json:"name" binding:"required,max=255"`
+}
+
+// Owner is the user wallets belong to, ExternalUserID identifies it in the system that manages users
+type Owner struct {
+	ID             uint      `json:"id" gorm:"primary_key"`
+	ExternalUserID string    `json:"external_user_id" gorm:"type:varchar(64);unique_index;not null"`
+	Name           string    `json:"name" gorm:"type:varchar(255);not null"`
+	Status         string    `json:"status" gorm:"type:varchar(10);not null;default:'active'"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// OwnerBalance adds up the open wallets of an owner, one entry per currency
+type OwnerBalance struct {
+	OwnerID  uint                   `json:"owner_id"`
+	Balances []OwnerCurrencyBalance `json:"balances"`
+}
+
+type OwnerCurrencyBalance struct {
+	Balance
+	Wallets int `json:"wallets"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// ReconciliationOptions overrides the configured behaviour of a reconciliation run, a nil Freeze
+// keeps the reconciliation.freeze_mismatched setting
+type ReconciliationOptions struct {
+	Freeze *bool `form:"freeze" json:"freeze"`
+}
+
+// WalletDiscrepancy describes a wallet whose stored balance disagrees with its ledger or its cached copy
+type WalletDiscrepancy struct {
+	WalletID       uint             `json:"wallet_id"`
+	Balance        decimal.Decimal  `json:"balance"`
+	LedgerBalance  decimal.Decimal  `json:"ledger_balance"`
+	CachedBalance  *decimal.Decimal `json:"cached_balance,omitempty"`
+	LedgerMismatch bool             `json:"ledger_mismatch"`
+	CacheMismatch  bool             `json:"cache_mismatch"`
+	Frozen         bool             `json:"frozen"`
+}
+
+// ReconciliationStarted answers the admin trigger, the report is written when the run finishes
+type ReconciliationStarted struct {
+	StartedAt time.Time `json:"started_at"`
+}
+
+type ReconciliationReport struct {
+	StartedAt      time.Time           `json:"started_at"`
+	FinishedAt     time.Time           `json:"finished_at"`
+	WalletsChecked int                 `json:"wallets_checked"`
+	Discrepancies  []WalletDiscrepancy `json:"discrepancies"`
+	FailedWallets  []uint              `json:"failed_wallets,omitempty"`
+	File           string              `json:"file,omitempty"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// BalanceSnapshot stores the balance of a wallet computed from its ledger up to TransactionID, so
+// historical balances only need to add the entries posted after it
+type BalanceSnapshot struct {
+	ID            uint            `json:"id" gorm:"primary_key"`
+	WalletID      uint            `json:"wallet_id" gorm:"unique_index:idx_snapshots_wallet_transaction;not null"`
+	TransactionID uint            `json:"transaction_id" gorm:"unique_index:idx_snapshots_wallet_transaction;not null"`
+	Balance       decimal.Decimal `json:"balance" sql:"type:decimal(20,8)"`
+	TakenAt       time.Time       `json:"taken_at" gorm:"index"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// LedgerSum is the signed total of a range of ledger entries, credits adding and debits subtracting
+type LedgerSum struct {
+	Total             decimal.Decimal
+	LastTransactionID uint
+	LastCreatedAt     time.Time
+}
+
+type HistoricalBalance struct {
+	Balance  decimal.Decimal `json:"balance"`
+	Currency string          `json:"currency"`
+	At       time.Time       `json:"at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+const (
+	StatementFormatJSON string = "json"
+	StatementFormatCSV  string = "csv"
+	StatementFormatOFX  string = "ofx"
+)
+
+// StatementRequest selects the period of a statement, which covers the entries created after From and up to To
+type StatementRequest struct {
+	From   time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00" binding:"required"`
+	To     time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00" binding:"required"`
+	Format string    `form:"format" binding:"omitempty,oneof=json csv ofx"`
+}
+
+type StatementHeader struct {
+	WalletID       uint            `json:"wallet_id"`
+	Currency       string          `json:"currency"`
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	OpeningBalance decimal.Decimal `json:"opening_balance"`
+}
+
+type StatementLine struct {
+	TransactionID      uint            `json:"transaction_id"`
+	Date               time.Time       `json:"date"`
+	Type               string          `json:"type"`
+	Direction          string          `json:"direction"`
+	Amount             decimal.Decimal `json:"amount"`
+	RunningBalance     decimal.Decimal `json:"running_balance"`
+	Reference          string          `json:"reference"`
+	OperationReference string          `json:"operation_reference"`
+}
+
+type StatementSummary struct {
+	ClosingBalance decimal.Decimal `json:"closing_balance"`
+	TotalCredits   decimal.Decimal `json:"total_credits"`
+	TotalDebits    decimal.Decimal `json:"total_debits"`
+	Entries        int             `json:"entries"`
+}
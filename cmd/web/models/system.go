@@ -0,0 +1,51 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+	"sort"
+	"time"
+)
+
+// system account types, the house wallets money enters, leaves or is parked through
+const (
+	SystemAccountFunding   string = "funding"
+	SystemAccountFeeIncome string = "fee_income"
+	SystemAccountSuspense  string = "suspense"
+	SystemAccountWriteOff  string = "write_off"
+)
+
+// SystemAccountTypes lists the system accounts every supported currency has
+var SystemAccountTypes = []string{
+	SystemAccountFunding,
+	SystemAccountFeeIncome,
+	SystemAccountSuspense,
+	SystemAccountWriteOff,
+}
+
+func IsSystemAccount(account string) bool {
+	for _, accountType := range SystemAccountTypes {
+		if account == accountType {
+			return true
+		}
+	}
+	return false
+}
+
+type SystemAccountBalance struct {
+	WalletID uint            `json:"wallet_id"`
+	Type     string          `json:"type"`
+	Currency string          `json:"currency"`
+	Balance  decimal.Decimal `json:"balance"`
+	// At is when the balance was computed, it is the sum of the account ledger up to then
+	At time.Time `json:"at"`
+}
+
+// Currencies returns the supported currency codes in alphabetical order
+func Currencies() []string {
+	currencies := make([]string, 0, len(currencyMinorUnits))
+	for currency := range currencyMinorUnits {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
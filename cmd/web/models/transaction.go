@@ -0,0 +1,81 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+const (
+	DirectionDebit  string = "debit"
+	DirectionCredit string = "credit"
+)
+
+const (
+	TransactionTypeDebit    string = "debit"
+	TransactionTypeCredit   string = "credit"
+	TransactionTypeTransfer string = "transfer"
+	TransactionTypeFX       string = "fx_conversion"
+	TransactionTypeCapture  string = "capture"
+	TransactionTypeReversal string = "reversal"
+	TransactionTypeFee      string = "fee"
+	TransactionTypeInterest string = "interest"
+)
+
+type ReversalRequest struct {
+	// Amount is optional, the remaining amount of the transaction is reversed when it is not sent
+	Amount *decimal.Decimal `json:"amount"`
+}
+
+// Transaction is an append-only ledger entry recording a balance change on a wallet.
+// Entries posted by the same operation, e.g. both legs of a transfer, share the OperationReference,
+// and reversals point to the entry they compensate through ReversedTransactionID.
+type Transaction struct {
+	ID                    uint              `json:"id" gorm:"primary_key"`
+	WalletID              uint              `json:"wallet_id" gorm:"index;not null"`
+	Type                  string            `json:"type" gorm:"type:varchar(20);not null"`
+	Direction             string            `json:"direction" gorm:"type:varchar(10);not null"`
+	Amount                decimal.Decimal   `json:"amount" sql:"type:decimal(20,8)"`
+	Balance               decimal.Decimal   `json:"balance" sql:"type:decimal(20,8)"`
+	Currency              string            `json:"currency" gorm:"type:char(3);not null"`
+	Reference             string            `json:"reference" gorm:"type:varchar(64);unique_index;not null"`
+	OperationReference    string            `json:"operation_reference" gorm:"type:varchar(64);index;not null"`
+	ReversedTransactionID *uint             `json:"reversed_transaction_id,omitempty" gorm:"index"`
+	Description           string            `json:"description,omitempty" gorm:"type:varchar(255)"`
+	ExternalReference     string            `json:"external_reference,omitempty" gorm:"type:varchar(64);index"`
+	Metadata              map[string]string `json:"metadata,omitempty" gorm:"-"`
+	CreatedAt             time.Time         `json:"created_at"`
+}
+
+// Signed returns the amount as it changed the wallet balance, negative for debits
+func (transaction Transaction) Signed() decimal.Decimal {
+	if transaction.Direction == DirectionDebit {
+		return transaction.Amount.Neg()
+	}
+	return transaction.Amount
+}
+
+// TransactionMetadata stores one key/value pair attached to a ledger entry, one row per pair so
+// entries can be searched by any of them
+type TransactionMetadata struct {
+	ID            uint   `gorm:"primary_key"`
+	TransactionID uint   `gorm:"index;not null"`
+	Name          string `gorm:"type:varchar(64);index:idx_transaction_metadata_name_value;not null"`
+	Value         string `gorm:"type:varchar(255);index:idx_transaction_metadata_name_value;not null"`
+}
+
+func (TransactionMetadata) TableName() string {
+	return "transaction_metadata"
+}
+
+// TransactionFilter narrows the ledger entries listed for a wallet, the zero value of each field
+// leaves it out of the search
+type TransactionFilter struct {
+	ExternalReference string
+	Metadata          map[string]string
+	MinAmount         *decimal.Decimal
+	MaxAmount         *decimal.Decimal
+	From              *time.Time
+	To                *time.Time
+	Limit             int
+	Offset            int
+}
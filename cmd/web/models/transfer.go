@@ -0,0 +1,11 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+type TransferRequest struct {
+	SourceWalletID      int             `json:"source_wallet_id" binding:"required"`
+	DestinationWalletID int             `json:"destination_wallet_id" binding:"required"`
+	Amount              decimal.Decimal `json:"amount" binding:"required"`
+	// Currency is optional, when sent it must match the currency of both wallets
+	Currency string `json:"currency"`
+}
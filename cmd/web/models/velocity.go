@@ -0,0 +1,68 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// Velocity limits count over calendar windows in UTC
+const (
+	VelocityPeriodHour  string = "hour"
+	VelocityPeriodDay   string = "day"
+	VelocityPeriodMonth string = "month"
+)
+
+// VelocityTransactionTypes are the ledger entries counted by the velocity limits
+var VelocityTransactionTypes = []string{TransactionTypeDebit, TransactionTypeTransfer, TransactionTypeCapture, TransactionTypeFX}
+
+type VelocityLimitRequest struct {
+	Period    string           `json:"period" binding:"required,oneof=hour day month"`
+	MaxAmount *decimal.Decimal `json:"max_amount"`
+	MaxCount  *int             `json:"max_count"`
+}
+
+type VelocityLimitsRequest struct {
+	// Limits replaces every override of the wallet, an override without caps disables the default of its period
+	Limits []VelocityLimitRequest `json:"limits" binding:"dive"`
+}
+
+// VelocityLimit caps the amount and number of debits of a wallet per period. Limits with a zero
+// WalletID are the configured defaults, the rest override the default of their period for one wallet.
+type VelocityLimit struct {
+	ID        uint                `json:"-" gorm:"primary_key"`
+	WalletID  uint                `json:"wallet_id" gorm:"unique_index:idx_velocity_limits_wallet_period;not null"`
+	Period    string              `json:"period" gorm:"type:varchar(10);unique_index:idx_velocity_limits_wallet_period;not null"`
+	MaxAmount decimal.NullDecimal `json:"max_amount" sql:"type:decimal(20,8)"`
+	MaxCount  *int                `json:"max_count"`
+	CreatedAt time.Time           `json:"created_at"`
+}
+
+// VelocityWindow is the running calendar window of a period
+type VelocityWindow struct {
+	Period string
+	Start  time.Time
+	End    time.Time
+}
+
+// VelocityUsage is what a wallet already debited within a window
+type VelocityUsage struct {
+	Amount decimal.Decimal
+	Count  int
+}
+
+func NewVelocityWindow(period string, now time.Time) VelocityWindow {
+	now = now.UTC()
+	window := VelocityWindow{Period: period}
+	switch period {
+	case VelocityPeriodHour:
+		window.Start = now.Truncate(time.Hour)
+		window.End = window.Start.Add(time.Hour)
+	case VelocityPeriodMonth:
+		window.Start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		window.End = window.Start.AddDate(0, 1, 0)
+	default:
+		window.Start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		window.End = window.Start.AddDate(0, 0, 1)
+	}
+	return window
+}
@@ -5,11 +5,91 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+const (
+	WalletStatusActive string = "active"
+	WalletStatusFrozen string = "frozen"
+	WalletStatusClosed string = "closed"
+)
+
+const (
+	WalletProductStandard string = "standard"
+	WalletProductSavings  string = "savings"
+)
+
 type WalletRequest struct {
 	Amount decimal.Decimal `json:"amount" binding:"required"`
+	// Currency is optional, when sent it must match the wallet currency
+	Currency string `json:"currency"`
+	// Description, ExternalReference and Metadata are stored with the ledger entry so callers can
+	// correlate it with their own records
+	Description       string            `json:"description" binding:"max=255"`
+	ExternalReference string            `json:"external_reference" binding:"max=64"`
+	Metadata          map[string]string `json:"metadata" binding:"max=20,dive,keys,required,max=64,endkeys,max=255"`
+}
+
+type CreateWalletRequest struct {
+	Currency string `json:"currency" binding:"required"`
+	// Product is optional and defaults to standard
+	Product string `json:"product" binding:"omitempty,oneof=standard savings"`
+	// OwnerID is optional, wallets without owner keep working by their id
+	OwnerID *uint `json:"owner_id"`
+}
+
+type Balance struct {
+	Balance          decimal.Decimal `json:"balance"`
+	AvailableBalance decimal.Decimal `json:"available_balance"`
+	// OverdraftHeadroom is the part of the overdraft limit not used yet, which can be spent on top
+	// of the available balance
+	OverdraftHeadroom decimal.Decimal `json:"overdraft_headroom"`
+	Currency          string          `json:"currency"`
 }
 
 type Wallet struct {
 	gorm.Model
 	Balance decimal.Decimal `json:"balance" sql:"type:decimal(20,8)"`
+	// Currency is the ISO 4217 code of the wallet balance
+	Currency string `json:"currency" gorm:"type:char(3);unique_index:idx_wallets_system_account_currency;not null"`
+	// Version is bumped on every update and used to detect concurrent modifications
+	Version uint   `json:"version" gorm:"not null;default:0"`
+	Status  string `json:"status" gorm:"type:varchar(10);not null;default:'active'"`
+	// HeldBalance is the part of the balance reserved by active holds
+	HeldBalance decimal.Decimal `json:"held_balance" sql:"type:decimal(20,8);not null;default:0"`
+	// OverdraftLimit is how far below zero the balance is allowed to go
+	OverdraftLimit decimal.Decimal `json:"overdraft_limit" sql:"type:decimal(20,8);not null;default:0"`
+	// Product selects the fee rules and features that apply to the wallet
+	Product string `json:"product" gorm:"type:varchar(20);not null;default:'standard'"`
+	OwnerID *uint  `json:"owner_id" gorm:"index"`
+	// SystemAccount is the type of the house account the wallet holds, null for customer wallets. The
+	// balance of system account rows is not maintained, it is the sum of their ledger
+	SystemAccount *string `json:"system_account,omitempty" gorm:"type:varchar(20);unique_index:idx_wallets_system_account_currency"`
+}
+
+func (wallet Wallet) IsSystem() bool {
+	return wallet.SystemAccount != nil
+}
+
+// Account returns the journal account the wallet postings belong to
+func (wallet Wallet) Account() string {
+	if wallet.IsSystem() {
+		return *wallet.SystemAccount
+	}
+	return AccountWallets
+}
+
+// Available returns the balance that can still be spent, which excludes held funds
+func (wallet Wallet) Available() decimal.Decimal {
+	return wallet.Balance.Sub(wallet.HeldBalance)
+}
+
+// OverdraftHeadroom returns how much of the overdraft limit is still unused
+func (wallet Wallet) OverdraftHeadroom() decimal.Decimal {
+	if available := wallet.Available(); available.IsNegative() {
+		return decimal.Max(wallet.OverdraftLimit.Add(available), decimal.Zero)
+	}
+	return wallet.OverdraftLimit
+}
+
+// Spendable returns the amount a debit can take: the available balance plus the overdraft limit
+func (wallet Wallet) Spendable() decimal.Decimal {
+	return wallet.Available().Add(wallet.OverdraftLimit)
 }
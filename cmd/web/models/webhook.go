@@ -0,0 +1,88 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	WebhookDeliveryPending   string = "pending"
+	WebhookDeliveryDelivered string = "delivered"
+	// WebhookDeliveryDead marks the deliveries that ran out of attempts, they wait in the dead-letter
+	// list until replayed
+	WebhookDeliveryDead string = "dead"
+)
+
+type CreateWebhookRequest struct {
+	// WalletID is the subscribed wallet on POST /api/v1/webhooks, the wallet routes take it from the path
+	WalletID   int      `json:"wallet_id" binding:"omitempty,min=1"`
+	URL        string   `json:"url" binding:"required,url,max=2048"`
+	EventTypes []string `json:"event_types" binding:"required,min=1,dive,oneof=wallet.credited wallet.debited"`
+	// Secret signs the deliveries, receivers verify the X-Webhook-Signature header with it
+	Secret string `json:"secret" binding:"required,min=16,max=255"`
+}
+
+// WebhookSubscription is a partner endpoint receiving the balance changes of one wallet for the
+// subscribed event types
+type WebhookSubscription struct {
+	ID         uint     `json:"id" gorm:"primary_key"`
+	WalletID   uint     `json:"wallet_id" gorm:"index;not null"`
+	URL        string   `json:"url" gorm:"type:varchar(2048);not null"`
+	EventTypes []string `json:"event_types" gorm:"-"`
+	// Events stores EventTypes comma separated, the repository keeps both in sync
+	Events    string    `json:"-" gorm:"column:event_types;type:varchar(255);not null"`
+	Secret    string    `json:"-" gorm:"type:varchar(255);not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (subscription WebhookSubscription) Subscribes(eventType string) bool {
+	for _, subscribed := range subscription.EventTypes {
+		if subscribed == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event to be delivered to one subscription, EventID is the outbox message id
+// and stays the same across redeliveries so receivers can skip the events they already handled
+type WebhookDelivery struct {
+	ID             uint   `json:"id" gorm:"primary_key"`
+	SubscriptionID uint   `json:"subscription_id" gorm:"unique_index:idx_webhook_deliveries_subscription_event;not null"`
+	EventID        string `json:"event_id" gorm:"type:varchar(64);unique_index:idx_webhook_deliveries_subscription_event;not null"`
+	EventType      string `json:"event_type" gorm:"type:varchar(50);not null"`
+	Payload        string `json:"payload" gorm:"type:text;not null"`
+	Status         string `json:"status" gorm:"type:varchar(10);index:idx_webhook_deliveries_status_next_attempt;not null"`
+	Attempts       int    `json:"attempts" gorm:"not null;default:0"`
+	// NextAttemptAt is when the worker tries a pending delivery again
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"index:idx_webhook_deliveries_status_next_attempt"`
+	LastError     string     `json:"last_error,omitempty" gorm:"type:varchar(255)"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// WebhookAttempt records one POST of a delivery, StatusCode is 0 when no response was received
+type WebhookAttempt struct {
+	ID         uint      `json:"id" gorm:"primary_key"`
+	DeliveryID uint      `json:"delivery_id" gorm:"index;not null"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error,omitempty" gorm:"type:varchar(255)"`
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// WebhookEvent is the JSON body POSTed to the subscriptions
+type WebhookEvent struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	// Data is the ledger entry that changed the balance
+	Data json.RawMessage `json:"data"`
+}
+
+type WebhookRun struct {
+	Delivered int `json:"delivered"`
+	Retried   int `json:"retried"`
+	Dead      int `json:"dead"`
+}
@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	"strconv"
+	"time"
+)
+
+// IBatchRepository stores the batches and the outcome of their items. It is part of ITransactionRepository
+// so an item is marked as processed in the same database transaction that posts it.
+type IBatchRepository interface {
+	CreateBatch(batch *models.Batch) error
+	GetBatch(batchId int) (models.Batch, error)
+	UpdateBatch(batch *models.Batch) error
+	// ClaimBatch marks the batch as processing when it is pending or its processing stalled before
+	// staleBefore, reporting whether this caller got it
+	ClaimBatch(batchId uint, staleBefore time.Time) (bool, error)
+	GetClaimableBatchIDs(staleBefore time.Time, limit int) ([]uint, error)
+	// CompleteBatchItem saves the outcome of a pending item, reporting false when it was already processed
+	CompleteBatchItem(item *models.BatchItem) (bool, error)
+}
+
+const batchNotFound string = "batch with id=%s not found"
+
+func (repository *TransactionRepository) CreateBatch(batch *models.Batch) error {
+	return repository.dbProvider.Create(batch).Error
+}
+
+func (repository *TransactionRepository) GetBatch(batchId int) (models.Batch, error) {
+	var batch models.Batch
+	status := repository.dbProvider.
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position")
+		}).
+		First(&batch, batchId)
+
+	if gorm.IsRecordNotFoundError(status.Error) {
+		return batch, exceptions.NewNotFoundException(batchNotFound, strconv.Itoa(batchId))
+	}
+	return batch, status.Error
+}
+
+func (repository *TransactionRepository) UpdateBatch(batch *models.Batch) error {
+	return repository.dbProvider.Model(&models.Batch{}).
+		Where("id = ?", batch.ID).
+		Updates(map[string]interface{}{
+			"status":          batch.Status,
+			"succeeded_items": batch.SucceededItems,
+			"failed_items":    batch.FailedItems,
+			"updated_at":      time.Now(),
+		}).Error
+}
+
+func (repository *TransactionRepository) ClaimBatch(batchId uint, staleBefore time.Time) (bool, error) {
+	status := repository.dbProvider.Model(&models.Batch{}).
+		Where("id = ? AND (status = ? OR (status = ? AND updated_at < ?))",
+			batchId, models.BatchStatusPending, models.BatchStatusProcessing, staleBefore).
+		Updates(map[string]interface{}{
+			"status":     models.BatchStatusProcessing,
+			"updated_at": time.Now(),
+		})
+
+	return status.RowsAffected == 1, status.Error
+}
+
+func (repository *TransactionRepository) GetClaimableBatchIDs(staleBefore time.Time, limit int) ([]uint, error) {
+	var ids []uint
+	status := repository.dbProvider.Model(&models.Batch{}).
+		Where("status = ? OR (status = ? AND updated_at < ?)",
+			models.BatchStatusPending, models.BatchStatusProcessing, staleBefore).
+		Order("id").
+		Limit(limit).
+		Pluck("id", &ids)
+
+	return ids, status.Error
+}
+
+func (repository *TransactionRepository) CompleteBatchItem(item *models.BatchItem) (bool, error) {
+	status := repository.dbProvider.Model(&models.BatchItem{}).
+		Where("id = ? AND status = ?", item.ID, models.BatchItemStatusPending).
+		Updates(map[string]interface{}{
+			"status":              item.Status,
+			"operation_reference": item.OperationReference,
+			"error_code":          item.ErrorCode,
+			"error":               item.Error,
+		})
+
+	return status.RowsAffected == 1, status.Error
+}
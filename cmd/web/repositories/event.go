@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"github.com/go-sql-driver/mysql"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	"strconv"
+)
+
+// IWalletEventRepository stores the wallet event streams of the event sourced mode
+type IWalletEventRepository interface {
+	// GetWalletEventSequence returns the sequence of the last event of the wallet, 0 when it has none
+	GetWalletEventSequence(walletId int) (uint, error)
+	// AppendWalletEvents numbers the events after expectedSequence and stores them, failing with a
+	// ConflictException when another writer appended to the wallet since that sequence was read
+	AppendWalletEvents(walletId int, expectedSequence uint, events []models.WalletEvent) error
+	// GetWalletEvents returns up to limit events of the wallet following afterSequence, in order
+	GetWalletEvents(walletId int, afterSequence uint, limit int) ([]models.WalletEvent, error)
+	GetEventSourcedWalletIDs(afterId uint, limit int) ([]uint, error)
+}
+
+const walletEventsConflict string = "events of wallet with id=%s were appended concurrently"
+
+func (repository *TransactionRepository) GetWalletEventSequence(walletId int) (uint, error) {
+	var sequences []uint
+	status := repository.dbProvider.Model(&models.WalletEvent{}).
+		Where("wallet_id = ?", walletId).
+		Order("sequence desc").
+		Limit(1).
+		Pluck("sequence", &sequences)
+
+	if status.Error != nil || len(sequences) == 0 {
+		return 0, status.Error
+	}
+	return sequences[0], nil
+}
+
+func (repository *TransactionRepository) AppendWalletEvents(walletId int, expectedSequence uint, events []models.WalletEvent) error {
+	for i := range events {
+		events[i].WalletID = uint(walletId)
+		events[i].Sequence = expectedSequence + uint(i) + 1
+
+		// the unique wallet and sequence index lets only one of two concurrent appends take a sequence
+		err := repository.dbProvider.Create(&events[i]).Error
+		if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlDuplicateEntry {
+			return exceptions.NewConflictException(walletEventsConflict, strconv.Itoa(walletId))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repository *TransactionRepository) GetWalletEvents(walletId int, afterSequence uint, limit int) ([]models.WalletEvent, error) {
+	var events []models.WalletEvent
+	status := repository.dbProvider.
+		Where("wallet_id = ? AND sequence > ?", walletId, afterSequence).
+		Order("sequence").
+		Limit(limit).
+		Find(&events)
+
+	return events, status.Error
+}
+
+func (repository *TransactionRepository) GetEventSourcedWalletIDs(afterId uint, limit int) ([]uint, error) {
+	var ids []uint
+	status := repository.dbProvider.Model(&models.WalletEvent{}).
+		Where("wallet_id > ?", afterId).
+		Group("wallet_id").
+		Order("wallet_id").
+		Limit(limit).
+		Pluck("wallet_id", &ids)
+
+	return ids, status.Error
+}
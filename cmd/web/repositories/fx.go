@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	"time"
+)
+
+// IFXQuoteRepository stores exchange rate quotes. It is part of ITransactionRepository so quotes
+// are redeemed in the same database transaction that moves the funds.
+type IFXQuoteRepository interface {
+	CreateQuote(quote *models.FXQuote) error
+	GetQuote(reference string) (models.FXQuote, error)
+	// RedeemQuote marks the quote as used, reporting false when it was already redeemed or expired
+	RedeemQuote(reference string, now time.Time) (bool, error)
+}
+
+const quoteNotFound string = "quote with id=%s not found"
+
+func (repository *TransactionRepository) CreateQuote(quote *models.FXQuote) error {
+	return repository.dbProvider.Create(quote).Error
+}
+
+func (repository *TransactionRepository) GetQuote(reference string) (models.FXQuote, error) {
+	var quote models.FXQuote
+	status := repository.dbProvider.Where("reference = ?", reference).First(&quote)
+
+	if gorm.IsRecordNotFoundError(status.Error) {
+		return quote, exceptions.NewNotFoundException(quoteNotFound, reference)
+	}
+	return quote, status.Error
+}
+
+func (repository *TransactionRepository) RedeemQuote(reference string, now time.Time) (bool, error) {
+	status := repository.dbProvider.Model(&models.FXQuote{}).
+		Where("reference = ? AND redeemed_at IS NULL AND expires_at > ?", reference, now).
+		Update("redeemed_at", now)
+
+	return status.RowsAffected == 1, status.Error
+}
@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	"strconv"
+	"time"
+)
+
+// IHoldRepository stores the funds reserved on wallets. It is part of ITransactionRepository so
+// holds change in the same database transaction as the wallet held balance.
+type IHoldRepository interface {
+	CreateHold(hold *models.Hold) error
+	GetHold(holdId int) (models.Hold, error)
+	GetHoldForUpdate(holdId int) (models.Hold, error)
+	UpdateHold(hold *models.Hold) error
+	GetExpiredHolds(now time.Time, limit int) ([]models.Hold, error)
+}
+
+const holdNotFound string = "hold with id=%s not found"
+
+func (repository *TransactionRepository) CreateHold(hold *models.Hold) error {
+	return repository.dbProvider.Create(hold).Error
+}
+
+func (repository *TransactionRepository) GetHold(holdId int) (models.Hold, error) {
+	return repository.findHold(repository.dbProvider, holdId)
+}
+
+func (repository *TransactionRepository) GetHoldForUpdate(holdId int) (models.Hold, error) {
+	return repository.findHold(repository.dbProvider.Set("gorm:query_option", "FOR UPDATE"), holdId)
+}
+
+func (repository *TransactionRepository) UpdateHold(hold *models.Hold) error {
+	return repository.dbProvider.Save(hold).Error
+}
+
+func (repository *TransactionRepository) GetExpiredHolds(now time.Time, limit int) ([]models.Hold, error) {
+	var holds []models.Hold
+	status := repository.dbProvider.
+		Where("status = ? AND expires_at <= ?", models.HoldStatusActive, now).
+		Order("expires_at").
+		Limit(limit).
+		Find(&holds)
+
+	return holds, status.Error
+}
+
+func (repository *TransactionRepository) findHold(db *gorm.DB, holdId int) (models.Hold, error) {
+	var hold models.Hold
+	status := db.First(&hold, holdId)
+
+	if gorm.IsRecordNotFoundError(status.Error) {
+		return hold, exceptions.NewNotFoundException(holdNotFound, strconv.Itoa(holdId))
+	}
+	return hold, status.Error
+}
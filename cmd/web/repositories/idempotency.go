@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	"github.com/spf13/viper"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/infrastructure"
+	"time"
+)
+
+// IIdempotencyRepository stores the outcome of requests sent with an Idempotency-Key
+type IIdempotencyRepository interface {
+	// Get returns nil when there is no record for the key
+	Get(key string) (*models.IdempotencyRecord, error)
+	// Reserve creates an uncompleted record for the key, reporting false if the key already exists
+	Reserve(record models.IdempotencyRecord) (bool, error)
+	Save(record models.IdempotencyRecord) error
+	Delete(key string) error
+}
+
+const idempotencyKey string = "idempotency_%s"
+const idempotencyStorageRedis string = "redis"
+
+// mysqlDuplicateEntry is the MySQL error number for a duplicate primary or unique key
+const mysqlDuplicateEntry uint16 = 1062
+
+type IdempotencyMySQLRepository struct {
+	dbProvider *gorm.DB
+	ttl        time.Duration
+}
+
+func (repository *IdempotencyMySQLRepository) Get(key string) (*models.IdempotencyRecord, error) {
+	var record models.IdempotencyRecord
+	status := repository.dbProvider.Where("`key` = ?", key).First(&record)
+	if gorm.IsRecordNotFoundError(status.Error) {
+		return nil, nil
+	}
+	if status.Error != nil {
+		return nil, status.Error
+	}
+
+	// expired keys are dropped so they can be reserved again
+	if repository.ttl > 0 && time.Since(record.CreatedAt) > repository.ttl {
+		return nil, repository.Delete(key)
+	}
+	return &record, nil
+}
+
+func (repository *IdempotencyMySQLRepository) Reserve(record models.IdempotencyRecord) (bool, error) {
+	err := repository.dbProvider.Create(&record).Error
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlDuplicateEntry {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (repository *IdempotencyMySQLRepository) Save(record models.IdempotencyRecord) error {
+	return repository.dbProvider.Model(&models.IdempotencyRecord{}).
+		Where("`key` = ?", record.Key).
+		Updates(map[string]interface{}{
+			"completed":   record.Completed,
+			"status_code": record.StatusCode,
+			"body":        record.Body,
+		}).Error
+}
+
+func (repository *IdempotencyMySQLRepository) Delete(key string) error {
+	return repository.dbProvider.Where("`key` = ?", key).Delete(&models.IdempotencyRecord{}).Error
+}
+
+type IdempotencyRedisRepository struct {
+	cacheProvider infrastructure.ICacheProvider
+	ttl           time.Duration
+}
+
+func (repository *IdempotencyRedisRepository) Get(key string) (*models.IdempotencyRecord, error) {
+	result, err := repository.cacheProvider.Get(fmt.Sprintf(idempotencyKey, key))
+	if err != nil || result == "" {
+		return nil, err
+	}
+
+	var record models.IdempotencyRecord
+	if err := json.Unmarshal([]byte(result), &record); err != nil {
+		return nil, fmt.Errorf("couldn't unmarshal idempotency record from cache: %v", err)
+	}
+	return &record, nil
+}
+
+func (repository *IdempotencyRedisRepository) Reserve(record models.IdempotencyRecord) (bool, error) {
+	j, err := json.Marshal(record)
+	if err != nil {
+		return false, err
+	}
+	return repository.cacheProvider.SetNX(fmt.Sprintf(idempotencyKey, record.Key), j, repository.ttl)
+}
+
+func (repository *IdempotencyRedisRepository) Save(record models.IdempotencyRecord) error {
+	j, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = repository.cacheProvider.Set(fmt.Sprintf(idempotencyKey, record.Key), j, repository.ttl)
+	return err
+}
+
+func (repository *IdempotencyRedisRepository) Delete(key string) error {
+	return repository.cacheProvider.Del(fmt.Sprintf(idempotencyKey, key))
+}
+
+// NewIdempotencyRepository returns the storage selected by the idempotency.storage setting,
+// MySQL by default
+func NewIdempotencyRepository() IIdempotencyRepository {
+	if viper.GetString("idempotency.storage") == idempotencyStorageRedis {
+		return &IdempotencyRedisRepository{
+			cacheProvider: infrastructure.NewCacheClient(),
+			ttl:           viper.GetDuration("idempotency.ttl"),
+		}
+	}
+
+	return &IdempotencyMySQLRepository{
+		dbProvider: infrastructure.ConnectDatabase(),
+		ttl:        viper.GetDuration("idempotency.ttl"),
+	}
+}
@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	"github.com/wallet-api/cmd/web/models"
+	"time"
+)
+
+// IInterestRepository stores the daily interest accruals of savings wallets and their payouts
+type IInterestRepository interface {
+	GetWalletIDsByProduct(product string, afterId uint, limit int) ([]uint, error)
+	// GetLastInterestAccrual returns the accrual of the last day accrued, nil when the wallet has no accruals yet
+	GetLastInterestAccrual(walletId int) (*models.InterestAccrual, error)
+	// CreateInterestAccrual reports false when the wallet already has an accrual for that day
+	CreateInterestAccrual(accrual *models.InterestAccrual) (bool, error)
+	// GetUnpaidInterestAccruals returns the accruals of days before the given one not paid out yet
+	GetUnpaidInterestAccruals(walletId int, before time.Time) ([]models.InterestAccrual, error)
+	MarkInterestAccrualsPaid(accrualIds []uint, transactionId uint) error
+}
+
+func (repository *TransactionRepository) GetWalletIDsByProduct(product string, afterId uint, limit int) ([]uint, error) {
+	var ids []uint
+	status := repository.dbProvider.Model(&models.Wallet{}).
+		Where("product = ? AND id > ?", product, afterId).
+		Order("id").
+		Limit(limit).
+		Pluck("id", &ids)
+
+	return ids, status.Error
+}
+
+func (repository *TransactionRepository) GetLastInterestAccrual(walletId int) (*models.InterestAccrual, error) {
+	var accrual models.InterestAccrual
+	status := repository.dbProvider.
+		Where("wallet_id = ? AND residual = ?", walletId, false).
+		Order("day desc").
+		First(&accrual)
+
+	if gorm.IsRecordNotFoundError(status.Error) {
+		return nil, nil
+	}
+	if status.Error != nil {
+		return nil, status.Error
+	}
+	return &accrual, nil
+}
+
+func (repository *TransactionRepository) CreateInterestAccrual(accrual *models.InterestAccrual) (bool, error) {
+	err := repository.dbProvider.Create(accrual).Error
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlDuplicateEntry {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (repository *TransactionRepository) GetUnpaidInterestAccruals(walletId int, before time.Time) ([]models.InterestAccrual, error) {
+	var accruals []models.InterestAccrual
+	status := repository.dbProvider.
+		Where("wallet_id = ? AND day < ? AND payout_transaction_id IS NULL", walletId, before).
+		Order("day").
+		Find(&accruals)
+
+	return accruals, status.Error
+}
+
+func (repository *TransactionRepository) MarkInterestAccrualsPaid(accrualIds []uint, transactionId uint) error {
+	return repository.dbProvider.Model(&models.InterestAccrual{}).
+		Where("id IN (?) AND payout_transaction_id IS NULL", accrualIds).
+		Update("payout_transaction_id", transactionId).Error
+}
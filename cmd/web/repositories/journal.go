@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"github.com/wallet-api/cmd/web/models"
+)
+
+// IJournalRepository stores the double-entry journal and adds up its postings
+type IJournalRepository interface {
+	// CreateJournalEntry stores the entry together with its postings
+	CreateJournalEntry(entry *models.JournalEntry) error
+	GetTrialBalanceLines() ([]models.TrialBalanceLine, error)
+	// GetWalletBalancesByCurrency adds up the balances of the customer wallets per currency
+	GetWalletBalancesByCurrency() (map[string]decimal.Decimal, error)
+	// GetUnjournaledTransactions returns the ledger entries after afterId that have no posting, oldest first
+	GetUnjournaledTransactions(afterId uint, limit int) ([]models.Transaction, error)
+	// LockUnjournaledOperation returns the ledger entries of the operation of the transaction that have no
+	// posting, locking them until the running transaction finishes
+	LockUnjournaledOperation(transaction models.Transaction) ([]models.Transaction, error)
+}
+
+func (repository *TransactionRepository) CreateJournalEntry(entry *models.JournalEntry) error {
+	return repository.dbProvider.Create(entry).Error
+}
+
+func (repository *TransactionRepository) GetTrialBalanceLines() ([]models.TrialBalanceLine, error) {
+	lines := []models.TrialBalanceLine{}
+	status := repository.dbProvider.Model(&models.Posting{}).
+		Select("account, currency, " +
+			"SUM(CASE WHEN amount < 0 THEN -amount ELSE 0 END) AS debits, " +
+			"SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END) AS credits, " +
+			"SUM(amount) AS balance").
+		Group("account, currency").
+		Order("account, currency").
+		Scan(&lines)
+
+	return lines, status.Error
+}
+
+func (repository *TransactionRepository) GetUnjournaledTransactions(afterId uint, limit int) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	status := repository.unjournaled().
+		Where("transactions.id > ?", afterId).
+		Order("transactions.id").
+		Limit(limit).
+		Find(&transactions)
+
+	return transactions, status.Error
+}
+
+func (repository *TransactionRepository) LockUnjournaledOperation(transaction models.Transaction) ([]models.Transaction, error) {
+	query := repository.unjournaled().Set("gorm:query_option", "FOR UPDATE")
+	if transaction.OperationReference == "" {
+		query = query.Where("transactions.id = ?", transaction.ID)
+	} else {
+		query = query.Where("transactions.operation_reference = ?", transaction.OperationReference)
+	}
+
+	var transactions []models.Transaction
+	status := query.Order("transactions.id").Find(&transactions)
+	return transactions, status.Error
+}
+
+// unjournaled selects the ledger entries without posting
+func (repository *TransactionRepository) unjournaled() *gorm.DB {
+	return repository.dbProvider.
+		Select("transactions.*").
+		Joins("LEFT JOIN postings ON postings.transaction_id = transactions.id").
+		Where("postings.id IS NULL")
+}
+
+func (repository *TransactionRepository) GetWalletBalancesByCurrency() (map[string]decimal.Decimal, error) {
+	rows, err := repository.dbProvider.Model(&models.Wallet{}).
+		Select("currency, SUM(balance)").
+		Where("system_account IS NULL").
+		Group("currency").
+		Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := map[string]decimal.Decimal{}
+	for rows.Next() {
+		var currency string
+		var balance decimal.Decimal
+		if err := rows.Scan(&currency, &balance); err != nil {
+			return nil, err
+		}
+		balances[currency] = balance
+	}
+	return balances, rows.Err()
+}
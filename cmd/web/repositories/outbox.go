@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/wallet-api/cmd/web/models"
+	"time"
+)
+
+// IOutboxRepository stores the balance changes waiting to be published and their delivery
+type IOutboxRepository interface {
+	// GetPendingOutboxMessages returns the messages after afterId that are neither delivered nor parked,
+	// oldest first, including the ones waiting for their next attempt
+	GetPendingOutboxMessages(afterId uint, limit int) ([]models.OutboxMessage, error)
+	MarkOutboxWebhooksQueued(messageId uint, at time.Time) error
+	MarkOutboxMessageDelivered(messageId uint, at time.Time) error
+	// MarkOutboxMessageFailed records the failed attempt and when the message is tried again
+	MarkOutboxMessageFailed(messageId uint, reason string, retryAt time.Time) error
+	// ParkOutboxMessage records the failed attempt and stops publishing the message
+	ParkOutboxMessage(messageId uint, reason string, at time.Time) error
+}
+
+// outboxErrorLength is the size of the last_error column
+const outboxErrorLength int = 255
+
+// createOutboxMessage announces the ledger entry, it must run in the transaction that creates it
+func (repository *TransactionRepository) createOutboxMessage(transaction models.Transaction) error {
+	message, err := models.NewBalanceChangedMessage(transaction)
+	if err != nil {
+		return err
+	}
+	return repository.dbProvider.Create(&message).Error
+}
+
+func (repository *TransactionRepository) GetPendingOutboxMessages(afterId uint, limit int) ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+	status := repository.dbProvider.
+		Where("delivered_at IS NULL AND parked_at IS NULL AND id > ?", afterId).
+		Order("id").
+		Limit(limit).
+		Find(&messages)
+
+	return messages, status.Error
+}
+
+func (repository *TransactionRepository) MarkOutboxWebhooksQueued(messageId uint, at time.Time) error {
+	return repository.dbProvider.Model(&models.OutboxMessage{}).
+		Where("id = ?", messageId).
+		UpdateColumn("webhooks_queued_at", at).Error
+}
+
+func (repository *TransactionRepository) MarkOutboxMessageDelivered(messageId uint, at time.Time) error {
+	return repository.dbProvider.Model(&models.OutboxMessage{}).
+		Where("id = ?", messageId).
+		Updates(map[string]interface{}{
+			"delivered_at": at,
+			"attempts":     gorm.Expr("attempts + 1"),
+		}).Error
+}
+
+func (repository *TransactionRepository) MarkOutboxMessageFailed(messageId uint, reason string, retryAt time.Time) error {
+	return repository.failOutboxMessage(messageId, reason, "next_attempt_at", retryAt)
+}
+
+func (repository *TransactionRepository) ParkOutboxMessage(messageId uint, reason string, at time.Time) error {
+	return repository.failOutboxMessage(messageId, reason, "parked_at", at)
+}
+
+func (repository *TransactionRepository) failOutboxMessage(messageId uint, reason string, column string, at time.Time) error {
+	if len(reason) > outboxErrorLength {
+		reason = reason[:outboxErrorLength]
+	}
+	return repository.dbProvider.Model(&models.OutboxMessage{}).
+		Where("id = ?", messageId).
+		Updates(map[string]interface{}{
+			"last_error": reason,
+			"attempts":   gorm.Expr("attempts + 1"),
+			column:       at,
+		}).Error
+}
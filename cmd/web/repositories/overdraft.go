@@ -0,0 +1,24 @@
+package repositories
+
+import "github.com/wallet-api/cmd/web/models"
+
+// IOverdraftRepository stores the audit trail of overdraft limit changes. It is part of
+// ITransactionRepository so the trail is written in the same database transaction as the wallet.
+type IOverdraftRepository interface {
+	CreateOverdraftLimitChange(change *models.OverdraftLimitChange) error
+	GetOverdraftLimitChanges(walletId int) ([]models.OverdraftLimitChange, error)
+}
+
+func (repository *TransactionRepository) CreateOverdraftLimitChange(change *models.OverdraftLimitChange) error {
+	return repository.dbProvider.Create(change).Error
+}
+
+func (repository *TransactionRepository) GetOverdraftLimitChanges(walletId int) ([]models.OverdraftLimitChange, error) {
+	var changes []models.OverdraftLimitChange
+	status := repository.dbProvider.
+		Where("wallet_id = ?", walletId).
+		Order("id desc").
+		Find(&changes)
+
+	return changes, status.Error
+}
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	"strconv"
+)
+
+// IOwnerRepository stores the owners and finds the wallets that belong to them
+type IOwnerRepository interface {
+	// CreateOwner fails with a ConflictException when the external user already has an owner
+	CreateOwner(owner *models.Owner) error
+	GetOwner(ownerId int) (models.Owner, error)
+	GetOwnerWallets(ownerId int) ([]models.Wallet, error)
+}
+
+const ownerNotFound string = "owner with id=%s not found"
+const ownerConflict string = "owner for external user %s already exists"
+
+func (repository *TransactionRepository) CreateOwner(owner *models.Owner) error {
+	err := repository.dbProvider.Create(owner).Error
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlDuplicateEntry {
+		return exceptions.NewConflictException(ownerConflict, owner.ExternalUserID)
+	}
+	return err
+}
+
+func (repository *TransactionRepository) GetOwner(ownerId int) (models.Owner, error) {
+	var owner models.Owner
+	status := repository.dbProvider.First(&owner, ownerId)
+
+	if gorm.IsRecordNotFoundError(status.Error) {
+		return owner, exceptions.NewNotFoundException(ownerNotFound, strconv.Itoa(ownerId))
+	}
+	return owner, status.Error
+}
+
+func (repository *TransactionRepository) GetOwnerWallets(ownerId int) ([]models.Wallet, error) {
+	wallets := []models.Wallet{}
+	status := repository.dbProvider.
+		Where("owner_id = ?", ownerId).
+		Order("id").
+		Find(&wallets)
+
+	return wallets, status.Error
+}
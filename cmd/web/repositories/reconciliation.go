@@ -0,0 +1,27 @@
+package repositories
+
+import (
+	"github.com/wallet-api/cmd/web/models"
+)
+
+// IReconciliationRepository exposes the cached copy of the wallets so it can be checked against the database
+type IReconciliationRepository interface {
+	// GetCachedWallet returns nil when the wallet is not cached
+	GetCachedWallet(walletId int) (*models.Wallet, error)
+	EvictCachedWallet(walletId uint)
+}
+
+func (repository *TransactionRepository) GetCachedWallet(walletId int) (*models.Wallet, error) {
+	wallet, err := repository.getWalletFromCache(walletId)
+	if err == errWalletNotCached {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (repository *TransactionRepository) EvictCachedWallet(walletId uint) {
+	repository.invalidateWallet(walletId)
+}
@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"github.com/wallet-api/cmd/web/models"
+	"time"
+)
+
+// IBalanceSnapshotRepository computes balances from the ledger and stores periodic snapshots of them
+type IBalanceSnapshotRepository interface {
+	// GetLatestSnapshot returns nil when the wallet has no snapshot taken at or before the given time
+	GetLatestSnapshot(walletId int, at time.Time) (*models.BalanceSnapshot, error)
+	CreateSnapshot(snapshot *models.BalanceSnapshot) error
+	// SumLedger adds up the wallet entries after the given transaction id and created at or before the given time
+	SumLedger(walletId int, afterTransactionId uint, at time.Time) (models.LedgerSum, error)
+	// SumLedgerRange adds up the wallet entries with ids after afterTransactionId up to lastTransactionId
+	SumLedgerRange(walletId int, afterTransactionId uint, lastTransactionId uint) (models.LedgerSum, error)
+	GetWalletIDs(afterId uint, limit int) ([]uint, error)
+}
+
+func (repository *TransactionRepository) GetLatestSnapshot(walletId int, at time.Time) (*models.BalanceSnapshot, error) {
+	var snapshot models.BalanceSnapshot
+	status := repository.dbProvider.
+		Where("wallet_id = ? AND taken_at <= ?", walletId, at).
+		Order("transaction_id desc").
+		First(&snapshot)
+
+	if gorm.IsRecordNotFoundError(status.Error) {
+		return nil, nil
+	}
+	if status.Error != nil {
+		return nil, status.Error
+	}
+	return &snapshot, nil
+}
+
+func (repository *TransactionRepository) CreateSnapshot(snapshot *models.BalanceSnapshot) error {
+	return repository.dbProvider.Create(snapshot).Error
+}
+
+func (repository *TransactionRepository) SumLedger(walletId int, afterTransactionId uint, at time.Time) (models.LedgerSum, error) {
+	return repository.sumLedger(afterTransactionId, "wallet_id = ? AND id > ? AND created_at <= ?", walletId, afterTransactionId, at)
+}
+
+func (repository *TransactionRepository) SumLedgerRange(walletId int, afterTransactionId uint, lastTransactionId uint) (models.LedgerSum, error) {
+	return repository.sumLedger(afterTransactionId, "wallet_id = ? AND id > ? AND id <= ?", walletId, afterTransactionId, lastTransactionId)
+}
+
+func (repository *TransactionRepository) sumLedger(afterTransactionId uint, query string, args ...interface{}) (models.LedgerSum, error) {
+	var result struct {
+		Total             decimal.NullDecimal
+		LastTransactionID *uint
+		LastCreatedAt     *time.Time
+	}
+	status := repository.dbProvider.Model(&models.Transaction{}).
+		Select("SUM(CASE WHEN direction = ? THEN amount ELSE -amount END) AS total, "+
+			"MAX(id) AS last_transaction_id, MAX(created_at) AS last_created_at", models.DirectionCredit).
+		Where(query, args...).
+		Scan(&result)
+	if status.Error != nil {
+		return models.LedgerSum{}, status.Error
+	}
+
+	sum := models.LedgerSum{Total: result.Total.Decimal, LastTransactionID: afterTransactionId}
+	if result.LastTransactionID != nil {
+		sum.LastTransactionID = *result.LastTransactionID
+		sum.LastCreatedAt = *result.LastCreatedAt
+	}
+	return sum, nil
+}
+
+func (repository *TransactionRepository) GetWalletIDs(afterId uint, limit int) ([]uint, error) {
+	var ids []uint
+	status := repository.dbProvider.Model(&models.Wallet{}).
+		Where("id > ?", afterId).
+		Order("id").
+		Limit(limit).
+		Pluck("id", &ids)
+
+	return ids, status.Error
+}
@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+)
+
+// ISystemAccountRepository finds the house wallets created by the migrations
+type ISystemAccountRepository interface {
+	GetSystemAccount(accountType string, currency string) (models.Wallet, error)
+	GetSystemAccounts() ([]models.Wallet, error)
+}
+
+const systemAccountNotFound string = "there is no %s system account for %s"
+
+func (repository *TransactionRepository) GetSystemAccount(accountType string, currency string) (models.Wallet, error) {
+	var wallet models.Wallet
+	status := repository.dbProvider.
+		Where("system_account = ? AND currency = ?", accountType, currency).
+		First(&wallet)
+
+	if gorm.IsRecordNotFoundError(status.Error) {
+		return wallet, exceptions.NewNotFoundException(systemAccountNotFound, accountType, currency)
+	}
+	return wallet, status.Error
+}
+
+func (repository *TransactionRepository) GetSystemAccounts() ([]models.Wallet, error) {
+	wallets := []models.Wallet{}
+	status := repository.dbProvider.
+		Where("system_account IS NOT NULL").
+		Order("system_account, currency").
+		Find(&wallets)
+
+	return wallets, status.Error
+}
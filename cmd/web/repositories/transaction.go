@@ -1,29 +1,77 @@
 package repositories
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 	"github.com/wallet-api/cmd/web/models"
 	"github.com/wallet-api/exceptions"
 	"github.com/wallet-api/infrastructure"
+	"sort"
 	"strconv"
+	"time"
 )
 
 type ITransactionRepository interface {
 	GetWallet(walletId int) (models.Wallet, error)
-	UpdateWallet(wallet models.Wallet) error
+	GetWalletForUpdate(walletId int) (models.Wallet, error)
+	GetWalletFromDatabase(walletId int) (models.Wallet, error)
+	CreateWallet(wallet *models.Wallet) error
+	UpdateWallet(wallet *models.Wallet) error
+	CreateTransaction(transaction *models.Transaction) error
+	GetTransactionForUpdate(transactionId int) (models.Transaction, error)
+	GetReversedAmount(transactionId int) (decimal.Decimal, error)
+	// GetOperationFees returns the fees the wallet was charged for the operation
+	GetOperationFees(walletId int, operationReference string) ([]models.Transaction, error)
+	GetTransactions(walletId int, filter models.TransactionFilter) ([]models.Transaction, error)
+	// StreamTransactions calls fn for each entry created after from and up to to, oldest first,
+	// without loading the whole range in memory
+	StreamTransactions(walletId int, from time.Time, to time.Time, fn func(transaction models.Transaction) error) error
+	WithinTransaction(fn func(repository ITransactionRepository) error) error
+	// WithinSnapshot runs fn with a repository whose reads all see the database as of the same moment
+	WithinSnapshot(fn func(repository ITransactionRepository) error) error
+	IFXQuoteRepository
+	IHoldRepository
+	IOverdraftRepository
+	IBalanceSnapshotRepository
+	IReconciliationRepository
+	IBatchRepository
+	IVelocityRepository
+	IInterestRepository
+	IOwnerRepository
+	IJournalRepository
+	ISystemAccountRepository
+	IWalletEventRepository
+	IOutboxRepository
+	IWebhookRepository
 }
 
 type TransactionRepository struct {
 	dbProvider    *gorm.DB
 	cacheProvider infrastructure.ICacheProvider
+	// wallets whose cache entry must be dropped once the running transaction commits
+	staleWallets []uint
+	// work that must only happen once the running transaction commits
+	afterCommit   []func()
+	inTransaction bool
 }
 
 const walletKey string = "wallet_%d"
-const walletNotFound string = "wallet with id=%d not found"
+const walletNotFound string = "wallet with id=%s not found"
+const transactionNotFound string = "transaction with id=%s not found"
+const walletConflict string = "wallet with id=%s was modified concurrently"
+const transactionDeadlock string = "the operation deadlocked with a concurrent one"
+
+// mysqlDeadlock is the MySQL error number of a transaction rolled back to break a deadlock
+const mysqlDeadlock uint16 = 1213
+
+var errWalletNotCached = errors.New("not found")
 
 func (repository *TransactionRepository) GetWallet(walletId int) (models.Wallet, error) {
 	// find in cache
@@ -33,14 +81,9 @@ func (repository *TransactionRepository) GetWallet(walletId int) (models.Wallet,
 	}
 
 	//find in database
-	status := repository.dbProvider.First(&wallet, walletId)
-
-	if status.Error != nil {
-		return wallet, status.Error
-	}
-
-	if wallet.ID == 0 {
-		return wallet, exceptions.NewNotFoundException(walletNotFound, strconv.Itoa(walletId))
+	wallet, err = repository.findWallet(repository.dbProvider, walletId)
+	if err != nil {
+		return wallet, err
 	}
 
 	// save in cache
@@ -53,12 +96,270 @@ func (repository *TransactionRepository) GetWallet(walletId int) (models.Wallet,
 	return wallet, nil
 }
 
-func (repository *TransactionRepository) UpdateWallet(wallet models.Wallet) error {
-	status := repository.dbProvider.Save(&wallet)
+// GetWalletForUpdate reads the wallet from the database, skipping the cache, and locks its row
+// until the running transaction finishes
+func (repository *TransactionRepository) GetWalletForUpdate(walletId int) (models.Wallet, error) {
+	return repository.findWallet(repository.dbProvider.Set("gorm:query_option", "FOR UPDATE"), walletId)
+}
+
+// GetWalletFromDatabase reads the wallet from the database without locking it, skipping the cache
+func (repository *TransactionRepository) GetWalletFromDatabase(walletId int) (models.Wallet, error) {
+	return repository.findWallet(repository.dbProvider, walletId)
+}
+
+func (repository *TransactionRepository) CreateWallet(wallet *models.Wallet) error {
+	return repository.dbProvider.Create(wallet).Error
+}
+
+// UpdateWallet saves the wallet only if nobody changed it since it was read (compare-and-swap on
+// its version), returning a ConflictException otherwise
+func (repository *TransactionRepository) UpdateWallet(wallet *models.Wallet) error {
+	status := repository.dbProvider.Model(&models.Wallet{}).
+		Where("id = ? AND version = ?", wallet.ID, wallet.Version).
+		Updates(map[string]interface{}{
+			"balance":         wallet.Balance,
+			"held_balance":    wallet.HeldBalance,
+			"overdraft_limit": wallet.OverdraftLimit,
+			"status":          wallet.Status,
+			"version":         wallet.Version + 1,
+		})
+	if status.Error != nil {
+		return status.Error
+	}
+	if status.RowsAffected == 0 {
+		return exceptions.NewConflictException(walletConflict, strconv.Itoa(int(wallet.ID)))
+	}
+	wallet.Version++
+
+	repository.invalidateWallet(wallet.ID)
+
+	return nil
+}
+
+// CreateTransaction appends the ledger entry with its metadata and the outbox message announcing it,
+// it must run within a transaction for all of them to be written atomically with the balance change
+func (repository *TransactionRepository) CreateTransaction(transaction *models.Transaction) error {
+	if err := repository.dbProvider.Create(transaction).Error; err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(transaction.Metadata))
+	for name := range transaction.Metadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		metadata := models.TransactionMetadata{TransactionID: transaction.ID, Name: name, Value: transaction.Metadata[name]}
+		if err := repository.dbProvider.Create(&metadata).Error; err != nil {
+			return err
+		}
+	}
+	return repository.createOutboxMessage(*transaction)
+}
+
+// GetTransactionForUpdate reads the ledger entry locking its row until the running transaction
+// finishes, which serializes concurrent reversals of the same entry
+func (repository *TransactionRepository) GetTransactionForUpdate(transactionId int) (models.Transaction, error) {
+	var transaction models.Transaction
+	status := repository.dbProvider.Set("gorm:query_option", "FOR UPDATE").First(&transaction, transactionId)
+
+	if gorm.IsRecordNotFoundError(status.Error) {
+		return transaction, exceptions.NewNotFoundException(transactionNotFound, strconv.Itoa(transactionId))
+	}
+	return transaction, status.Error
+}
+
+// GetReversedAmount sums the reversals already posted against the ledger entry
+func (repository *TransactionRepository) GetReversedAmount(transactionId int) (decimal.Decimal, error) {
+	var result struct {
+		Total decimal.NullDecimal
+	}
+	status := repository.dbProvider.Model(&models.Transaction{}).
+		Select("SUM(amount) AS total").
+		Where("reversed_transaction_id = ?", transactionId).
+		Scan(&result)
+
+	return result.Total.Decimal, status.Error
+}
+
+func (repository *TransactionRepository) GetOperationFees(walletId int, operationReference string) ([]models.Transaction, error) {
+	var fees []models.Transaction
+	status := repository.dbProvider.
+		Where("wallet_id = ? AND operation_reference = ? AND type = ?", walletId, operationReference, models.TransactionTypeFee).
+		Order("id").
+		Find(&fees)
 
-	go repository.cacheProvider.Set(fmt.Sprintf(walletKey, wallet.ID), nil, 0)
+	return fees, status.Error
+}
+
+func (repository *TransactionRepository) GetTransactions(walletId int, filter models.TransactionFilter) ([]models.Transaction, error) {
+	query := repository.dbProvider.Where("wallet_id = ?", walletId)
+	if filter.ExternalReference != "" {
+		query = query.Where("external_reference = ?", filter.ExternalReference)
+	}
+	if filter.MinAmount != nil {
+		query = query.Where("amount >= ?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		query = query.Where("amount <= ?", *filter.MaxAmount)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+	for name, value := range filter.Metadata {
+		query = query.Where("id IN ?", repository.dbProvider.Model(&models.TransactionMetadata{}).
+			Select("transaction_id").
+			Where("name = ? AND value = ?", name, value).
+			SubQuery())
+	}
+
+	var transactions []models.Transaction
+	status := query.
+		Order("id desc").
+		Limit(filter.Limit).
+		Offset(filter.Offset).
+		Find(&transactions)
+	if status.Error != nil {
+		return nil, status.Error
+	}
+
+	return transactions, repository.loadMetadata(transactions)
+}
+
+func (repository *TransactionRepository) loadMetadata(transactions []models.Transaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+
+	positions := make(map[uint]int, len(transactions))
+	ids := make([]uint, 0, len(transactions))
+	for i, transaction := range transactions {
+		positions[transaction.ID] = i
+		ids = append(ids, transaction.ID)
+	}
+
+	var metadata []models.TransactionMetadata
+	if err := repository.dbProvider.Where("transaction_id IN (?)", ids).Find(&metadata).Error; err != nil {
+		return err
+	}
+	for _, pair := range metadata {
+		transaction := &transactions[positions[pair.TransactionID]]
+		if transaction.Metadata == nil {
+			transaction.Metadata = map[string]string{}
+		}
+		transaction.Metadata[pair.Name] = pair.Value
+	}
+	return nil
+}
+
+func (repository *TransactionRepository) StreamTransactions(walletId int, from time.Time, to time.Time, fn func(transaction models.Transaction) error) error {
+	rows, err := repository.dbProvider.Model(&models.Transaction{}).
+		Where("wallet_id = ? AND created_at > ? AND created_at <= ?", walletId, from, to).
+		Order("id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transaction models.Transaction
+		if err := repository.dbProvider.ScanRows(rows, &transaction); err != nil {
+			return err
+		}
+		if err := fn(transaction); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// WithinTransaction runs fn with a repository bound to a database transaction,
+// committing when fn succeeds and rolling back otherwise. A deadlock fails with a
+// ConflictException, the transaction was rolled back and can be run again
+func (repository *TransactionRepository) WithinTransaction(fn func(repository ITransactionRepository) error) error {
+	tx := repository.dbProvider.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	txRepository := &TransactionRepository{
+		dbProvider:    tx,
+		cacheProvider: repository.cacheProvider,
+		inTransaction: true,
+	}
+	if err := fn(txRepository); err != nil {
+		tx.Rollback()
+		return deadlockConflict(err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return deadlockConflict(err)
+	}
+
+	// the cache is only dropped after commit, so concurrent readers cannot cache the old balance again
+	for _, walletId := range txRepository.staleWallets {
+		repository.invalidateWallet(walletId)
+	}
+	for _, fn := range txRepository.afterCommit {
+		fn()
+	}
+	return nil
+}
+
+// WithinSnapshot runs fn in a read-only repeatable read transaction, so every query of fn reads from
+// the snapshot taken by its first read regardless of the server isolation level
+func (repository *TransactionRepository) WithinSnapshot(fn func(repository ITransactionRepository) error) error {
+	tx := repository.dbProvider.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer tx.Rollback()
+
+	return fn(&TransactionRepository{
+		dbProvider:    tx,
+		cacheProvider: repository.cacheProvider,
+		inTransaction: true,
+	})
+}
+
+func deadlockConflict(err error) error {
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlDeadlock {
+		return exceptions.NewConflictException(transactionDeadlock)
+	}
+	return err
+}
+
+// onCommit runs fn once the running transaction commits, or right away outside a transaction
+func (repository *TransactionRepository) onCommit(fn func()) {
+	if repository.inTransaction {
+		repository.afterCommit = append(repository.afterCommit, fn)
+		return
+	}
+	fn()
+}
+
+func (repository *TransactionRepository) findWallet(db *gorm.DB, walletId int) (models.Wallet, error) {
+	var wallet models.Wallet
+	status := db.First(&wallet, walletId)
+
+	if gorm.IsRecordNotFoundError(status.Error) {
+		return wallet, exceptions.NewNotFoundException(walletNotFound, strconv.Itoa(walletId))
+	}
+
+	return wallet, status.Error
+}
+
+func (repository *TransactionRepository) invalidateWallet(walletId uint) {
+	if repository.inTransaction {
+		repository.staleWallets = append(repository.staleWallets, walletId)
+		return
+	}
 
-	return status.Error
+	go repository.cacheProvider.Set(fmt.Sprintf(walletKey, walletId), nil, 0)
 }
 
 func (repository *TransactionRepository) getWalletFromCache(walletId int) (models.Wallet, error) {
@@ -69,7 +370,7 @@ func (repository *TransactionRepository) getWalletFromCache(walletId int) (model
 	}
 	// if not found key
 	if result == "" {
-		return models.Wallet{}, errors.New("not found")
+		return models.Wallet{}, errWalletNotCached
 	}
 
 	var wallet *models.Wallet
@@ -4,6 +4,9 @@ import (
 	"github.com/shopspring/decimal"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	"sync"
 	"testing"
 	"time"
 )
@@ -25,7 +28,7 @@ func TestTransactionRepository_UpdateWallet(t *testing.T) {
 
 	wallet, _ := repository.GetWallet(2)
 	wallet.Balance = decimal.NewFromInt(30)
-	repository.UpdateWallet(wallet)
+	repository.UpdateWallet(&wallet)
 
 	// wait for go routing to delete cache
 	time.Sleep(1 * time.Second)
@@ -34,6 +37,305 @@ func TestTransactionRepository_UpdateWallet(t *testing.T) {
 	assert.True(t, wallet.Balance.Equal(decimal.NewFromInt(30)))
 }
 
+func TestTransactionRepository_WithinTransaction(t *testing.T) {
+	setTestEnvironment()
+
+	repository := NewTransactionRepository()
+
+	err := repository.WithinTransaction(func(tx ITransactionRepository) error {
+		return tx.CreateTransaction(&models.Transaction{
+			WalletID:  3,
+			Direction: models.DirectionCredit,
+			Amount:    decimal.NewFromInt(5),
+			Balance:   decimal.NewFromInt(5),
+			Reference: "test-reference",
+		})
+	})
+	assert.Nil(t, err)
+
+	transactions, _ := repository.GetTransactions(3, models.TransactionFilter{Limit: 1})
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, "test-reference", transactions[0].Reference)
+}
+
+func TestTransactionRepository_GetTransactionsFilter(t *testing.T) {
+	setTestEnvironment()
+
+	repository := NewTransactionRepository()
+
+	err := repository.WithinTransaction(func(tx ITransactionRepository) error {
+		return tx.CreateTransaction(&models.Transaction{
+			WalletID:          3,
+			Direction:         models.DirectionCredit,
+			Amount:            decimal.NewFromInt(7),
+			Balance:           decimal.NewFromInt(7),
+			Reference:         "filter-reference",
+			ExternalReference: "order-1",
+			Metadata:          map[string]string{"channel": "web"},
+		})
+	})
+	assert.Nil(t, err)
+
+	minAmount := decimal.NewFromInt(5)
+	transactions, _ := repository.GetTransactions(3, models.TransactionFilter{
+		ExternalReference: "order-1",
+		Metadata:          map[string]string{"channel": "web"},
+		MinAmount:         &minAmount,
+		Limit:             10,
+	})
+	assert.Len(t, transactions, 1)
+	assert.Equal(t, "web", transactions[0].Metadata["channel"])
+
+	transactions, _ = repository.GetTransactions(3, models.TransactionFilter{
+		Metadata: map[string]string{"channel": "mobile"},
+		Limit:    10,
+	})
+	assert.Len(t, transactions, 0)
+}
+
+func TestTransactionRepository_GetWalletForUpdate(t *testing.T) {
+	setTestEnvironment()
+
+	repository := NewTransactionRepository()
+	initial, _ := repository.GetWalletForUpdate(3)
+
+	// concurrent increments must serialize on the row lock, so none of them is lost
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			repository.WithinTransaction(func(tx ITransactionRepository) error {
+				wallet, err := tx.GetWalletForUpdate(3)
+				if err != nil {
+					return err
+				}
+				wallet.Balance = wallet.Balance.Add(decimal.NewFromInt(1))
+				return tx.UpdateWallet(&wallet)
+			})
+		}()
+	}
+	wg.Wait()
+
+	wallet, _ := repository.GetWalletForUpdate(3)
+	assert.True(t, wallet.Balance.Equal(initial.Balance.Add(decimal.NewFromInt(10))))
+}
+
+func TestTransactionRepository_UpdateWalletConflict(t *testing.T) {
+	setTestEnvironment()
+
+	repository := NewTransactionRepository()
+
+	wallet, _ := repository.GetWalletFromDatabase(2)
+	stale := wallet
+	assert.Nil(t, repository.UpdateWallet(&wallet))
+
+	stale.Balance = decimal.NewFromInt(1)
+	err := repository.UpdateWallet(&stale)
+	assert.IsType(t, &exceptions.ConflictException{}, err)
+}
+
+func TestTransactionRepository_GetWalletNotFound(t *testing.T) {
+	setTestEnvironment()
+
+	repository := NewTransactionRepository()
+
+	_, err := repository.GetWalletForUpdate(999)
+	assert.IsType(t, &exceptions.NotFoundException{}, err)
+}
+
+func TestTransactionRepository_OwnerWallets(t *testing.T) {
+	setTestEnvironment()
+
+	repository := NewTransactionRepository()
+
+	owner := models.Owner{ExternalUserID: "user-wallets", Name: "Test", Status: models.OwnerStatusActive}
+	assert.Nil(t, repository.CreateOwner(&owner))
+	err := repository.CreateOwner(&models.Owner{ExternalUserID: "user-wallets", Name: "Other", Status: models.OwnerStatusActive})
+	assert.IsType(t, &exceptions.ConflictException{}, err)
+
+	wallet := models.Wallet{Currency: "EUR", Status: models.WalletStatusActive, Product: models.WalletProductStandard, OwnerID: &owner.ID}
+	assert.Nil(t, repository.CreateWallet(&wallet))
+
+	wallets, _ := repository.GetOwnerWallets(int(owner.ID))
+	assert.Len(t, wallets, 1)
+	assert.Equal(t, wallet.ID, wallets[0].ID)
+}
+
+func TestTransactionRepository_TrialBalanceLines(t *testing.T) {
+	setTestEnvironment()
+
+	repository := NewTransactionRepository()
+
+	walletId := uint(3)
+	err := repository.CreateJournalEntry(&models.JournalEntry{
+		OperationReference: "journal-reference",
+		Type:               models.TransactionTypeCredit,
+		Postings: []models.Posting{
+			{Account: models.AccountWallets, Currency: "ARS", WalletID: &walletId, Amount: decimal.NewFromInt(8)},
+			{Account: models.SystemAccountFunding, Currency: "ARS", Amount: decimal.NewFromInt(-8)},
+		},
+	})
+	assert.Nil(t, err)
+
+	lines, _ := repository.GetTrialBalanceLines()
+	total := decimal.Zero
+	for _, line := range lines {
+		if line.Currency == "ARS" {
+			total = total.Add(line.Balance)
+		}
+	}
+	assert.True(t, total.IsZero())
+}
+
+func TestTransactionRepository_WithinSnapshot(t *testing.T) {
+	setTestEnvironment()
+
+	repository := NewTransactionRepository()
+
+	err := repository.WithinSnapshot(func(snapshot ITransactionRepository) error {
+		before, err := snapshot.GetTrialBalanceLines()
+		if err != nil {
+			return err
+		}
+
+		// committed outside the snapshot, so it must not be seen by its reads
+		err = repository.CreateJournalEntry(&models.JournalEntry{
+			OperationReference: "snapshot-reference",
+			Type:               models.TransactionTypeCredit,
+			Postings: []models.Posting{
+				{Account: models.AccountWallets, Currency: "EUR", Amount: decimal.NewFromInt(3)},
+				{Account: models.SystemAccountFunding, Currency: "EUR", Amount: decimal.NewFromInt(-3)},
+			},
+		})
+		assert.Nil(t, err)
+
+		after, err := snapshot.GetTrialBalanceLines()
+		assert.Equal(t, before, after)
+		return err
+	})
+	assert.Nil(t, err)
+}
+
+func TestTransactionRepository_GetSystemAccount(t *testing.T) {
+	setTestEnvironment()
+
+	repository := NewTransactionRepository()
+
+	account, err := repository.GetSystemAccount(models.SystemAccountFunding, "EUR")
+	assert.Nil(t, err)
+	assert.True(t, account.IsSystem())
+	// the row keeps no balance, money funded into the seeded wallets is in its ledger
+	sum, _ := repository.SumLedger(int(account.ID), 0, time.Now())
+	assert.True(t, sum.Total.IsNegative())
+
+	accounts, _ := repository.GetSystemAccounts()
+	assert.Len(t, accounts, len(models.SystemAccountTypes)*len(models.Currencies()))
+}
+
+func TestTransactionRepository_AppendWalletEvents(t *testing.T) {
+	setTestEnvironment()
+
+	repository := NewTransactionRepository()
+
+	opened, _ := models.NewWalletEvent(models.WalletEventOpened, models.WalletEventData{Currency: "EUR", Status: models.WalletStatusActive})
+	frozen, _ := models.NewWalletEvent(models.WalletEventFrozen, models.WalletEventData{})
+	err := repository.AppendWalletEvents(3, 0, []models.WalletEvent{opened, frozen})
+	assert.Nil(t, err)
+
+	sequence, _ := repository.GetWalletEventSequence(3)
+	assert.Equal(t, uint(2), sequence)
+
+	// a writer that read the stream before the append cannot take the same sequence
+	unfrozen, _ := models.NewWalletEvent(models.WalletEventUnfrozen, models.WalletEventData{})
+	err = repository.AppendWalletEvents(3, 1, []models.WalletEvent{unfrozen})
+	assert.IsType(t, &exceptions.ConflictException{}, err)
+
+	events, _ := repository.GetWalletEvents(3, 0, 10)
+	assert.Len(t, events, 2)
+	assert.Equal(t, models.WalletEventFrozen, events[1].Type)
+	events, _ = repository.GetWalletEvents(3, 1, 10)
+	assert.Len(t, events, 1)
+}
+
+func TestTransactionRepository_CreateTransactionOutbox(t *testing.T) {
+	setTestEnvironment()
+
+	repository := NewTransactionRepository()
+
+	err := repository.WithinTransaction(func(tx ITransactionRepository) error {
+		return tx.CreateTransaction(&models.Transaction{
+			WalletID: 1, Type: models.TransactionTypeDebit, Direction: models.DirectionDebit, Amount: decimal.NewFromInt(1),
+			Currency: "EUR", Reference: "outbox-test", OperationReference: "outbox-test",
+		})
+	})
+	assert.Nil(t, err)
+
+	messages, _ := repository.GetPendingOutboxMessages(0, 100)
+	assert.NotEmpty(t, messages)
+	message := messages[len(messages)-1]
+	assert.Equal(t, models.OutboxEventWalletDebited, message.EventType)
+
+	repository.MarkOutboxMessageDelivered(message.ID, time.Now())
+	messages, _ = repository.GetPendingOutboxMessages(0, 100)
+	for _, pending := range messages {
+		assert.NotEqual(t, message.ID, pending.ID)
+	}
+
+	// parked messages are no longer pending either
+	assert.Nil(t, repository.WithinTransaction(func(tx ITransactionRepository) error {
+		return tx.CreateTransaction(&models.Transaction{
+			WalletID: 1, Type: models.TransactionTypeCredit, Direction: models.DirectionCredit, Amount: decimal.NewFromInt(1),
+			Currency: "EUR", Reference: "outbox-parked", OperationReference: "outbox-parked",
+		})
+	}))
+	messages, _ = repository.GetPendingOutboxMessages(message.ID, 100)
+	assert.NotEmpty(t, messages)
+	parked := messages[len(messages)-1]
+	assert.Nil(t, repository.ParkOutboxMessage(parked.ID, "stream unavailable", time.Now()))
+	messages, _ = repository.GetPendingOutboxMessages(message.ID, 100)
+	for _, pending := range messages {
+		assert.NotEqual(t, parked.ID, pending.ID)
+	}
+}
+
+func TestTransactionRepository_WebhookDeliveries(t *testing.T) {
+	setTestEnvironment()
+
+	repository := NewTransactionRepository()
+
+	subscription := models.WebhookSubscription{WalletID: 1, URL: "https://partner.example/hooks", EventTypes: []string{models.OutboxEventWalletCredited, models.OutboxEventWalletDebited}, Secret: "0123456789abcdef"}
+	assert.Nil(t, repository.CreateWebhookSubscription(&subscription))
+	stored, _ := repository.GetWebhookSubscription(int(subscription.ID))
+	assert.Equal(t, subscription.EventTypes, stored.EventTypes)
+
+	now := time.Now()
+	delivery := models.WebhookDelivery{SubscriptionID: subscription.ID, EventID: "webhook-test", EventType: models.OutboxEventWalletCredited,
+		Payload: "{}", Status: models.WebhookDeliveryPending, NextAttemptAt: now.Add(-time.Minute)}
+	created, err := repository.CreateWebhookDelivery(&delivery)
+	assert.Nil(t, err)
+	assert.True(t, created)
+
+	// the same event is delivered once per subscription however many times it is published
+	created, err = repository.CreateWebhookDelivery(&models.WebhookDelivery{SubscriptionID: subscription.ID, EventID: "webhook-test",
+		EventType: models.OutboxEventWalletCredited, Payload: "{}", Status: models.WebhookDeliveryPending, NextAttemptAt: now})
+	assert.Nil(t, err)
+	assert.False(t, created)
+
+	due, _ := repository.ClaimDueWebhookDeliveries(now, time.Minute, 100)
+	assert.NotEmpty(t, due)
+	// a claimed delivery is not handed to another worker until its lease ends
+	claimed, _ := repository.ClaimDueWebhookDeliveries(now, time.Minute, 100)
+	for _, other := range claimed {
+		assert.NotEqual(t, delivery.ID, other.ID)
+	}
+
+	delivery.Status = models.WebhookDeliveryDead
+	assert.Nil(t, repository.UpdateWebhookDelivery(&delivery))
+	dead, _ := repository.GetDeadWebhookDeliveries()
+	assert.Equal(t, delivery.ID, dead[len(dead)-1].ID)
+}
+
 func setTestEnvironment() {
 	viper.Set("env", "test")
 	viper.Set("database.host", "localhost:3305")
@@ -41,6 +343,6 @@ func setTestEnvironment() {
 	viper.Set("database.user", "root")
 	viper.Set("database.name", "challenge")
 
-	viper.Set("cache.host","localhost")
+	viper.Set("cache.host", "localhost")
 	viper.Set("cache.port", "6378")
 }
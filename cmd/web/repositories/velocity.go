@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"github.com/wallet-api/cmd/web/models"
+	"strconv"
+	"time"
+)
+
+// IVelocityRepository stores the per wallet velocity limits and the usage of their windows. The usage
+// is counted in the cache and recomputed from the ledger whenever the cache doesn't have it.
+type IVelocityRepository interface {
+	GetVelocityLimits(walletId int) ([]models.VelocityLimit, error)
+	// ReplaceVelocityLimits must run within a transaction so the old overrides are never lost alone
+	ReplaceVelocityLimits(walletId int, limits []models.VelocityLimit) error
+	GetVelocityUsage(walletId int, window models.VelocityWindow) (models.VelocityUsage, error)
+	// AddVelocityUsage counts a debit in the window once the running transaction commits
+	AddVelocityUsage(walletId int, window models.VelocityWindow, amount decimal.Decimal)
+}
+
+const velocityCountKey string = "velocity_%d_%s_%d_count"
+const velocityAmountKey string = "velocity_%d_%s_%d_amount"
+
+// velocityAmountScale turns amounts into integers for the cache, matching the ledger decimal places
+const velocityAmountScale int32 = 8
+
+func (repository *TransactionRepository) GetVelocityLimits(walletId int) ([]models.VelocityLimit, error) {
+	var limits []models.VelocityLimit
+	status := repository.dbProvider.
+		Where("wallet_id = ?", walletId).
+		Order("id").
+		Find(&limits)
+
+	return limits, status.Error
+}
+
+func (repository *TransactionRepository) ReplaceVelocityLimits(walletId int, limits []models.VelocityLimit) error {
+	if err := repository.dbProvider.Where("wallet_id = ?", walletId).Delete(&models.VelocityLimit{}).Error; err != nil {
+		return err
+	}
+	for i := range limits {
+		if err := repository.dbProvider.Create(&limits[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (repository *TransactionRepository) GetVelocityUsage(walletId int, window models.VelocityWindow) (models.VelocityUsage, error) {
+	countKey, amountKey := velocityKeys(walletId, window)
+	if usage, ok := repository.getCachedVelocityUsage(countKey, amountKey); ok {
+		return usage, nil
+	}
+
+	var result struct {
+		Total decimal.NullDecimal
+		Count int
+	}
+	status := repository.dbProvider.Model(&models.Transaction{}).
+		Select("SUM(amount) AS total, COUNT(*) AS count").
+		Where("wallet_id = ? AND direction = ? AND type IN (?) AND created_at >= ?",
+			walletId, models.DirectionDebit, models.VelocityTransactionTypes, window.Start).
+		Scan(&result)
+	if status.Error != nil {
+		return models.VelocityUsage{}, status.Error
+	}
+	usage := models.VelocityUsage{Amount: result.Total.Decimal, Count: result.Count}
+
+	// the amount is seeded first, a count without its amount is never read
+	ttl := time.Until(window.End)
+	if ttl > 0 {
+		if _, err := repository.cacheProvider.Set(amountKey, usage.Amount.Shift(velocityAmountScale).IntPart(), ttl); err == nil {
+			repository.cacheProvider.Set(countKey, usage.Count, ttl)
+		}
+	}
+	return usage, nil
+}
+
+func (repository *TransactionRepository) AddVelocityUsage(walletId int, window models.VelocityWindow, amount decimal.Decimal) {
+	countKey, amountKey := velocityKeys(walletId, window)
+	repository.onCommit(func() {
+		// a counter created by the increment itself missed the previous debits, so it is dropped
+		// and the next read recomputes it from the ledger
+		count, err := repository.cacheProvider.IncrBy(countKey, 1)
+		if err == nil && count > 1 {
+			scaled := amount.Shift(velocityAmountScale).IntPart()
+			total, err := repository.cacheProvider.IncrBy(amountKey, scaled)
+			if err == nil && total != scaled {
+				return
+			}
+		}
+		if err != nil {
+			logrus.Errorf("couldn't count velocity usage of wallet %d: %v", walletId, err)
+		}
+		repository.cacheProvider.Del(countKey)
+		repository.cacheProvider.Del(amountKey)
+	})
+}
+
+func (repository *TransactionRepository) getCachedVelocityUsage(countKey string, amountKey string) (models.VelocityUsage, bool) {
+	count, err := repository.cacheProvider.Get(countKey)
+	if err != nil || count == "" {
+		return models.VelocityUsage{}, false
+	}
+	amount, err := repository.cacheProvider.Get(amountKey)
+	if err != nil || amount == "" {
+		return models.VelocityUsage{}, false
+	}
+
+	usage := models.VelocityUsage{}
+	if usage.Count, err = strconv.Atoi(count); err != nil {
+		return models.VelocityUsage{}, false
+	}
+	scaled, err := strconv.ParseInt(amount, 10, 64)
+	if err != nil {
+		return models.VelocityUsage{}, false
+	}
+	usage.Amount = decimal.New(scaled, -velocityAmountScale)
+	return usage, true
+}
+
+func velocityKeys(walletId int, window models.VelocityWindow) (string, string) {
+	return fmt.Sprintf(velocityCountKey, walletId, window.Period, window.Start.Unix()),
+		fmt.Sprintf(velocityAmountKey, walletId, window.Period, window.Start.Unix())
+}
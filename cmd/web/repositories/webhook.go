@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IWebhookRepository stores the webhook subscriptions, their deliveries and every delivery attempt
+type IWebhookRepository interface {
+	CreateWebhookSubscription(subscription *models.WebhookSubscription) error
+	GetWebhookSubscription(subscriptionId int) (models.WebhookSubscription, error)
+	GetWebhookSubscriptions(walletId int) ([]models.WebhookSubscription, error)
+	// CreateWebhookDelivery reports false when the subscription already has a delivery of the event
+	CreateWebhookDelivery(delivery *models.WebhookDelivery) (bool, error)
+	GetWebhookDelivery(deliveryId int) (models.WebhookDelivery, error)
+	// ClaimDueWebhookDeliveries returns the pending deliveries whose next attempt is due, oldest first, and
+	// postpones their next attempt by the lease so no other worker takes them while they are attempted.
+	// A delivery whose worker died is taken again once its lease ends
+	ClaimDueWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error)
+	GetDeadWebhookDeliveries() ([]models.WebhookDelivery, error)
+	UpdateWebhookDelivery(delivery *models.WebhookDelivery) error
+	CreateWebhookAttempt(attempt *models.WebhookAttempt) error
+	GetWebhookAttempts(deliveryId int) ([]models.WebhookAttempt, error)
+}
+
+const webhookSubscriptionNotFound string = "webhook subscription with id=%s not found"
+const webhookDeliveryNotFound string = "webhook delivery with id=%s not found"
+
+// webhookErrorLength is the size of the error columns
+const webhookErrorLength int = 255
+
+func (repository *TransactionRepository) CreateWebhookSubscription(subscription *models.WebhookSubscription) error {
+	subscription.Events = strings.Join(subscription.EventTypes, ",")
+	return repository.dbProvider.Create(subscription).Error
+}
+
+func (repository *TransactionRepository) GetWebhookSubscription(subscriptionId int) (models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	status := repository.dbProvider.First(&subscription, subscriptionId)
+
+	if gorm.IsRecordNotFoundError(status.Error) {
+		return subscription, exceptions.NewNotFoundException(webhookSubscriptionNotFound, strconv.Itoa(subscriptionId))
+	}
+	subscription.EventTypes = strings.Split(subscription.Events, ",")
+	return subscription, status.Error
+}
+
+func (repository *TransactionRepository) GetWebhookSubscriptions(walletId int) ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	status := repository.dbProvider.
+		Where("wallet_id = ?", walletId).
+		Order("id").
+		Find(&subscriptions)
+
+	for i := range subscriptions {
+		subscriptions[i].EventTypes = strings.Split(subscriptions[i].Events, ",")
+	}
+	return subscriptions, status.Error
+}
+
+func (repository *TransactionRepository) CreateWebhookDelivery(delivery *models.WebhookDelivery) (bool, error) {
+	err := repository.dbProvider.Create(delivery).Error
+	if mysqlErr, ok := err.(*mysql.MySQLError); ok && mysqlErr.Number == mysqlDuplicateEntry {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (repository *TransactionRepository) GetWebhookDelivery(deliveryId int) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	status := repository.dbProvider.First(&delivery, deliveryId)
+
+	if gorm.IsRecordNotFoundError(status.Error) {
+		return delivery, exceptions.NewNotFoundException(webhookDeliveryNotFound, strconv.Itoa(deliveryId))
+	}
+	return delivery, status.Error
+}
+
+func (repository *TransactionRepository) ClaimDueWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := repository.dbProvider.Transaction(func(tx *gorm.DB) error {
+		// rows claimed by a concurrent worker are skipped rather than waited for
+		status := tx.Set("gorm:query_option", "FOR UPDATE SKIP LOCKED").
+			Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+			Order("next_attempt_at, id").
+			Limit(limit).
+			Find(&deliveries)
+		if status.Error != nil || len(deliveries) == 0 {
+			return status.Error
+		}
+
+		ids := make([]uint, len(deliveries))
+		for i, delivery := range deliveries {
+			ids[i] = delivery.ID
+		}
+		return tx.Model(&models.WebhookDelivery{}).
+			Where("id IN (?)", ids).
+			UpdateColumn("next_attempt_at", now.Add(lease)).Error
+	})
+
+	return deliveries, err
+}
+
+func (repository *TransactionRepository) GetDeadWebhookDeliveries() ([]models.WebhookDelivery, error) {
+	deliveries := []models.WebhookDelivery{}
+	status := repository.dbProvider.
+		Where("status = ?", models.WebhookDeliveryDead).
+		Order("id").
+		Find(&deliveries)
+
+	return deliveries, status.Error
+}
+
+func (repository *TransactionRepository) UpdateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	if len(delivery.LastError) > webhookErrorLength {
+		delivery.LastError = delivery.LastError[:webhookErrorLength]
+	}
+	return repository.dbProvider.Save(delivery).Error
+}
+
+func (repository *TransactionRepository) CreateWebhookAttempt(attempt *models.WebhookAttempt) error {
+	if len(attempt.Error) > webhookErrorLength {
+		attempt.Error = attempt.Error[:webhookErrorLength]
+	}
+	return repository.dbProvider.Create(attempt).Error
+}
+
+func (repository *TransactionRepository) GetWebhookAttempts(deliveryId int) ([]models.WebhookAttempt, error) {
+	attempts := []models.WebhookAttempt{}
+	status := repository.dbProvider.
+		Where("delivery_id = ?", deliveryId).
+		Order("id").
+		Find(&attempts)
+
+	return attempts, status.Error
+}
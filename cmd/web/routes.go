@@ -14,8 +14,68 @@ func Routes(r *gin.Engine) {
 		})
 	})
 
+	idempotency := handlers.NewIdempotencyMiddleware()
+
+	walletHandler := handlers.NewWalletHandler()
+	r.POST("/api/v1/wallets", walletHandler.Create)
+	r.GET("/api/v1/wallets/:wallet_id", walletHandler.Get)
+	r.POST("/api/v1/wallets/:wallet_id/freeze", walletHandler.Freeze)
+	r.POST("/api/v1/wallets/:wallet_id/unfreeze", walletHandler.Unfreeze)
+	r.POST("/api/v1/wallets/:wallet_id/close", walletHandler.Close)
+	r.PUT("/api/v1/admin/wallets/:wallet_id/overdraft-limit", walletHandler.SetOverdraftLimit)
+	r.GET("/api/v1/admin/wallets/:wallet_id/overdraft-limit/changes", walletHandler.GetOverdraftLimitChanges)
+	r.PUT("/api/v1/admin/wallets/:wallet_id/velocity-limits", walletHandler.SetVelocityLimits)
+	r.GET("/api/v1/admin/wallets/:wallet_id/velocity-limits", walletHandler.GetVelocityLimits)
+
+	ownerHandler := handlers.NewOwnerHandler()
+	r.POST("/api/v1/owners", ownerHandler.Create)
+	r.GET("/api/v1/owners/:owner_id", ownerHandler.Get)
+	r.GET("/api/v1/owners/:owner_id/wallets", ownerHandler.GetWallets)
+	r.GET("/api/v1/owners/:owner_id/balance", ownerHandler.GetBalance)
+
 	transactionHandler := handlers.NewTransactionHandler()
 	r.GET("/api/v1/wallets/:wallet_id/balance", transactionHandler.GetBalance)
-	r.POST("/api/v1/wallets/:wallet_id/debit", transactionHandler.Debit)
-	r.POST("/api/v1/wallets/:wallet_id/credit", transactionHandler.Credit)
+	r.POST("/api/v1/wallets/:wallet_id/debit", idempotency, transactionHandler.Debit)
+	r.POST("/api/v1/wallets/:wallet_id/credit", idempotency, transactionHandler.Credit)
+	r.GET("/api/v1/wallets/:wallet_id/transactions", transactionHandler.GetTransactions)
+	r.POST("/api/v1/transactions/:transaction_id/reverse", idempotency, transactionHandler.Reverse)
+
+	statementHandler := handlers.NewStatementHandler()
+	r.GET("/api/v1/wallets/:wallet_id/statement", statementHandler.Export)
+
+	holdHandler := handlers.NewHoldHandler()
+	r.POST("/api/v1/wallets/:wallet_id/holds", idempotency, holdHandler.Place)
+	r.GET("/api/v1/wallets/:wallet_id/holds/:hold_id", holdHandler.Get)
+	r.POST("/api/v1/wallets/:wallet_id/holds/:hold_id/capture", idempotency, holdHandler.Capture)
+	r.POST("/api/v1/wallets/:wallet_id/holds/:hold_id/void", holdHandler.Void)
+
+	transferHandler := handlers.NewTransferHandler()
+	r.POST("/api/v1/transfers", idempotency, transferHandler.Transfer)
+
+	batchHandler := handlers.NewBatchHandler()
+	r.POST("/api/v1/batches", idempotency, batchHandler.Submit)
+	r.GET("/api/v1/batches/:batch_id", batchHandler.Get)
+
+	reconciliationHandler := handlers.NewReconciliationHandler()
+	r.POST("/api/v1/admin/reconciliations", reconciliationHandler.Reconcile)
+
+	journalHandler := handlers.NewJournalHandler()
+	r.GET("/api/v1/admin/trial-balance", journalHandler.TrialBalance)
+
+	systemAccountHandler := handlers.NewSystemAccountHandler()
+	r.GET("/api/v1/admin/system-accounts", systemAccountHandler.GetBalances)
+
+	webhookHandler := handlers.NewWebhookHandler()
+	r.POST("/api/v1/webhooks", webhookHandler.Create)
+	r.POST("/api/v1/wallets/:wallet_id/webhooks", webhookHandler.Create)
+	r.GET("/api/v1/admin/webhooks/dead-letters", webhookHandler.GetDeadLetters)
+	r.GET("/api/v1/admin/webhooks/deliveries/:delivery_id/attempts", webhookHandler.GetAttempts)
+	r.POST("/api/v1/admin/webhooks/deliveries/:delivery_id/replay", webhookHandler.Replay)
+
+	fxHandler := handlers.NewFXHandler()
+	r.POST("/api/v1/fx/quotes", fxHandler.Quote)
+	r.POST("/api/v1/fx/conversions", idempotency, fxHandler.Convert)
+
+	feeHandler := handlers.NewFeeHandler()
+	r.POST("/api/v1/fees/quote", feeHandler.Quote)
 }
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"github.com/wallet-api/exceptions"
+	"strconv"
+	"time"
+)
+
+type IBatchService interface {
+	Submit(request models.BatchRequest) (models.Batch, error)
+	Get(batchId int) (models.Batch, error)
+	ProcessPending() (int, error)
+}
+
+type BatchService struct {
+	transactionRepository repositories.ITransactionRepository
+	concurrency           concurrencyPolicy
+	velocity              velocityPolicy
+	fees                  feePolicy
+	// batches with more items than asyncThreshold are processed in the background
+	asyncThreshold int
+	maxItems       int
+	// a batch still processing after staleAfter is considered abandoned and can be claimed again
+	staleAfter   time.Duration
+	processAsync func(batchId uint)
+}
+
+const (
+	ErrorCodeBatchTooLarge    string = "batch_too_large"
+	ErrorCodeInvalidBatchItem string = "invalid_batch_item"
+)
+
+const (
+	batchItemErrorNotAllowed string = "operation_not_allowed"
+	batchItemErrorNotFound   string = "not_found"
+	batchItemErrorConflict   string = "conflict"
+	batchItemErrorInternal   string = "internal_error"
+)
+
+const (
+	pendingBatchesLimit  int = 10
+	batchHeartbeatItems  int = 50
+	maxBatchErrorMessage int = 255
+)
+
+// errBatchItemProcessed rolls back an item that another worker already processed
+var errBatchItemProcessed = errors.New("batch item already processed")
+
+// Submit stores the batch and processes it, large batches are left pending and processed in the
+// background so the caller polls their status
+func (service *BatchService) Submit(request models.BatchRequest) (models.Batch, error) {
+	if len(request.Items) > service.maxItems {
+		return models.Batch{}, exceptions.NewForbiddenExceptionWithCode(ErrorCodeBatchTooLarge, "operation not allowed: a batch accepts up to %s items", strconv.Itoa(service.maxItems))
+	}
+
+	batch := models.Batch{
+		Mode:       request.Mode,
+		Status:     models.BatchStatusPending,
+		TotalItems: len(request.Items),
+	}
+	for position, itemRequest := range request.Items {
+		if err := checkBatchItem(position, itemRequest); err != nil {
+			return models.Batch{}, err
+		}
+		batch.Items = append(batch.Items, models.BatchItem{
+			Position:            position,
+			Type:                itemRequest.Type,
+			WalletID:            itemRequest.WalletID,
+			SourceWalletID:      itemRequest.SourceWalletID,
+			DestinationWalletID: itemRequest.DestinationWalletID,
+			Amount:              itemRequest.Amount,
+			Currency:            itemRequest.Currency,
+			Status:              models.BatchItemStatusPending,
+		})
+	}
+
+	if err := service.transactionRepository.CreateBatch(&batch); err != nil {
+		return models.Batch{}, err
+	}
+
+	if len(batch.Items) > service.asyncThreshold {
+		service.processAsync(batch.ID)
+		return batch, nil
+	}
+
+	if err := service.process(batch.ID); err != nil {
+		return models.Batch{}, err
+	}
+	return service.transactionRepository.GetBatch(int(batch.ID))
+}
+
+func (service *BatchService) Get(batchId int) (models.Batch, error) {
+	return service.transactionRepository.GetBatch(batchId)
+}
+
+// ProcessPending processes the batches left pending or abandoned by a crashed instance
+func (service *BatchService) ProcessPending() (int, error) {
+	ids, err := service.transactionRepository.GetClaimableBatchIDs(time.Now().Add(-service.staleAfter), pendingBatchesLimit)
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, id := range ids {
+		if err := service.process(id); err != nil {
+			logrus.Errorf("couldn't process batch %d: %v", id, err)
+			continue
+		}
+		processed++
+	}
+	return processed, nil
+}
+
+func (service *BatchService) process(batchId uint) error {
+	claimed, err := service.transactionRepository.ClaimBatch(batchId, time.Now().Add(-service.staleAfter))
+	if err != nil || !claimed {
+		return err
+	}
+
+	batch, err := service.transactionRepository.GetBatch(int(batchId))
+	if err != nil {
+		return err
+	}
+
+	if batch.Mode == models.BatchModeAtomic {
+		return service.processAtomic(&batch)
+	}
+	return service.processBestEffort(&batch)
+}
+
+// processAtomic posts every item in one database transaction, the items are marked as succeeded in
+// that same transaction. When an item fails nothing is posted and the rest are marked as rolled back.
+func (service *BatchService) processAtomic(batch *models.Batch) error {
+	failed := -1
+	err := service.concurrency.run(service.transactionRepository, func(repository repositories.ITransactionRepository) error {
+		failed = -1
+		// lock every wallet up front in ascending order, like transfers do, so concurrent batches cannot deadlock.
+		// Missing wallets are left to the item that uses them to report
+		if _, err := service.concurrency.lockWallets(repository, batchWalletIds(batch.Items)...); err != nil {
+			if _, notFound := err.(*exceptions.NotFoundException); !notFound {
+				return err
+			}
+		}
+
+		for i := range batch.Items {
+			item := &batch.Items[i]
+			operationReference, err := applyBatchItem(service.concurrency, service.velocity, service.fees, repository, *item)
+			if err != nil {
+				failed = i
+				return err
+			}
+			item.Status = models.BatchItemStatusSucceeded
+			item.OperationReference = operationReference
+		}
+
+		for i := range batch.Items {
+			completed, err := repository.CompleteBatchItem(&batch.Items[i])
+			if err != nil {
+				return err
+			}
+			if !completed {
+				return errBatchItemProcessed
+			}
+		}
+
+		batch.Status = models.BatchStatusCompleted
+		batch.SucceededItems = len(batch.Items)
+		return repository.UpdateBatch(batch)
+	})
+	if err == nil || err == errBatchItemProcessed {
+		return nil
+	}
+	if failed < 0 {
+		// not caused by an item, the batch stays processing until it is claimed again
+		return err
+	}
+	failure := err
+
+	for i := range batch.Items {
+		item := &batch.Items[i]
+		item.OperationReference = ""
+		item.Status = models.BatchItemStatusRolledBack
+		if i == failed {
+			item.Status = models.BatchItemStatusFailed
+			item.ErrorCode, item.Error = batchItemError(failure)
+		}
+		if _, err := service.transactionRepository.CompleteBatchItem(item); err != nil {
+			return err
+		}
+	}
+
+	batch.Status = models.BatchStatusFailed
+	batch.FailedItems = 1
+	return service.transactionRepository.UpdateBatch(batch)
+}
+
+// processBestEffort posts each pending item in its own database transaction, so a failing item
+// doesn't affect the rest and a batch resumed after a crash skips the items already posted
+func (service *BatchService) processBestEffort(batch *models.Batch) error {
+	for i := range batch.Items {
+		item := &batch.Items[i]
+		if item.Status != models.BatchItemStatusPending {
+			continue
+		}
+
+		err := service.concurrency.run(service.transactionRepository, func(repository repositories.ITransactionRepository) error {
+			operationReference, err := applyBatchItem(service.concurrency, service.velocity, service.fees, repository, *item)
+			if err != nil {
+				return err
+			}
+
+			item.Status = models.BatchItemStatusSucceeded
+			item.OperationReference = operationReference
+			completed, err := repository.CompleteBatchItem(item)
+			if err != nil {
+				return err
+			}
+			if !completed {
+				return errBatchItemProcessed
+			}
+			return nil
+		})
+		if err != nil && err != errBatchItemProcessed {
+			item.Status = models.BatchItemStatusFailed
+			item.OperationReference = ""
+			item.ErrorCode, item.Error = batchItemError(err)
+			if _, err := service.transactionRepository.CompleteBatchItem(item); err != nil {
+				return err
+			}
+		}
+
+		if (i+1)%batchHeartbeatItems == 0 {
+			if err := service.transactionRepository.UpdateBatch(batch); err != nil {
+				return err
+			}
+		}
+	}
+
+	batch.Status = models.BatchStatusCompleted
+	batch.SucceededItems, batch.FailedItems = 0, 0
+	for _, item := range batch.Items {
+		switch item.Status {
+		case models.BatchItemStatusSucceeded:
+			batch.SucceededItems++
+		case models.BatchItemStatusFailed:
+			batch.FailedItems++
+		}
+	}
+	return service.transactionRepository.UpdateBatch(batch)
+}
+
+// applyBatchItem posts the item within the running database transaction and returns its operation reference
+func applyBatchItem(policy concurrencyPolicy, velocity velocityPolicy, fees feePolicy, repository repositories.ITransactionRepository, item models.BatchItem) (string, error) {
+	switch item.Type {
+	case models.BatchItemTypeDebit:
+		entry, err := debitWallet(policy, velocity, fees, repository, item.WalletID, models.WalletRequest{Amount: item.Amount, Currency: item.Currency})
+		return entry.OperationReference, err
+	case models.BatchItemTypeCredit:
+		entry, err := creditWallet(policy, fees, repository, item.WalletID, models.WalletRequest{Amount: item.Amount, Currency: item.Currency})
+		return entry.OperationReference, err
+	default:
+		return transferFunds(policy, velocity, fees, repository, models.TransferRequest{
+			SourceWalletID:      item.SourceWalletID,
+			DestinationWalletID: item.DestinationWalletID,
+			Amount:              item.Amount,
+			Currency:            item.Currency,
+		})
+	}
+}
+
+func checkBatchItem(position int, item models.BatchItemRequest) error {
+	valid := item.WalletID > 0
+	if item.Type == models.BatchItemTypeTransfer {
+		valid = item.SourceWalletID > 0 && item.DestinationWalletID > 0
+	}
+	if valid {
+		return nil
+	}
+	return exceptions.NewForbiddenExceptionWithDetails(ErrorCodeInvalidBatchItem, map[string]interface{}{"position": position},
+		"operation not allowed: item %s has no wallet for a %s", strconv.Itoa(position), item.Type)
+}
+
+func batchWalletIds(items []models.BatchItem) []int {
+	seen := map[int]bool{}
+	var ids []int
+	for _, item := range items {
+		for _, id := range []int{item.WalletID, item.SourceWalletID, item.DestinationWalletID} {
+			if id > 0 && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// batchItemError turns the error of a failed item into the code and message reported for it
+func batchItemError(err error) (string, string) {
+	code := batchItemErrorInternal
+	switch e := err.(type) {
+	case *exceptions.ForbiddenException:
+		code = e.Code
+		if code == "" {
+			code = batchItemErrorNotAllowed
+		}
+	case *exceptions.NotFoundException:
+		code = batchItemErrorNotFound
+	case *exceptions.ConflictException:
+		code = batchItemErrorConflict
+	}
+
+	message := err.Error()
+	if len(message) > maxBatchErrorMessage {
+		message = message[:maxBatchErrorMessage]
+	}
+	return code, message
+}
+
+func NewBatchService() IBatchService {
+	service := &BatchService{
+		transactionRepository: repositories.NewTransactionRepository(),
+		concurrency:           newConcurrencyPolicy(),
+		velocity:              newVelocityPolicy(),
+		fees:                  newFeePolicy(),
+		asyncThreshold:        viper.GetInt("batches.async_threshold"),
+		maxItems:              viper.GetInt("batches.max_items"),
+		staleAfter:            viper.GetDuration("batches.stale_after"),
+	}
+	service.processAsync = func(batchId uint) {
+		go func() {
+			if err := service.process(batchId); err != nil {
+				logrus.Errorf("couldn't process batch %d: %v", batchId, err)
+			}
+		}()
+	}
+	return service
+}
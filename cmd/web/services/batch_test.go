@@ -0,0 +1,191 @@
+package services
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"testing"
+)
+
+func TestBatchService_Submit(t *testing.T) {
+
+	repositoryMock := &mocks.RepositoryMock{}
+	var dispatched []uint
+
+	items := func() []models.BatchItem {
+		return []models.BatchItem{
+			{ID: 1, Position: 0, Type: models.BatchItemTypeCredit, WalletID: 1, Amount: decimal.NewFromInt(10), Status: models.BatchItemStatusPending},
+			{ID: 2, Position: 1, Type: models.BatchItemTypeDebit, WalletID: 2, Amount: decimal.NewFromInt(50), Status: models.BatchItemStatusPending},
+		}
+	}
+	request := func(mode string) models.BatchRequest {
+		return models.BatchRequest{
+			Mode: mode,
+			Items: []models.BatchItemRequest{
+				{Type: models.BatchItemTypeCredit, WalletID: 1, Amount: decimal.NewFromInt(10)},
+				{Type: models.BatchItemTypeDebit, WalletID: 2, Amount: decimal.NewFromInt(50)},
+			},
+		}
+	}
+	createBatch := func() {
+		repositoryMock.On("CreateBatch", mock.Anything).Run(func(args mock.Arguments) {
+			args.Get(0).(*models.Batch).ID = 7
+		}).Return(nil).Once()
+	}
+	itemCompleted := func(id uint, status string, errorCode string) {
+		repositoryMock.On("CompleteBatchItem", mock.MatchedBy(func(item *models.BatchItem) bool {
+			return item.ID == id && item.Status == status && item.ErrorCode == errorCode
+		})).Return(true, nil).Once()
+	}
+
+	type args struct {
+		request        models.BatchRequest
+		asyncThreshold int
+	}
+
+	tests := []struct {
+		name        string
+		initMocks   func()
+		args        args
+		assertMocks func(*testing.T)
+		assertError func(*testing.T, error)
+		assertFunc  func(*testing.T, models.Batch)
+	}{
+		{
+			name: "Success - best effort reports each item",
+			initMocks: func() {
+				createBatch()
+				repositoryMock.On("ClaimBatch", uint(7), mock.Anything).Return(true, nil).Once()
+				repositoryMock.On("GetBatch", 7).
+					Return(models.Batch{ID: 7, Mode: models.BatchModeBestEffort, Items: items()}, nil).Once()
+
+				repositoryMock.On("GetWalletForUpdate", 1).Return(models.Wallet{Balance: decimal.NewFromInt(100)}, nil).Once()
+				repositoryMock.On("UpdateWallet", mock.Anything).Return(nil).Once()
+				repositoryMock.On("CreateTransaction", mock.Anything).Return(nil).Once()
+				expectCounterparty(repositoryMock, models.SystemAccountFunding, models.TransactionTypeCredit, "")
+				itemCompleted(1, models.BatchItemStatusSucceeded, "")
+
+				repositoryMock.On("GetWalletForUpdate", 2).Return(models.Wallet{Balance: decimal.NewFromInt(20)}, nil).Once()
+				itemCompleted(2, models.BatchItemStatusFailed, ErrorCodeInsufficientFunds)
+
+				repositoryMock.On("UpdateBatch", mock.MatchedBy(func(batch *models.Batch) bool {
+					return batch.Status == models.BatchStatusCompleted && batch.SucceededItems == 1 && batch.FailedItems == 1
+				})).Return(nil).Once()
+				repositoryMock.On("GetBatch", 7).
+					Return(models.Batch{ID: 7, Status: models.BatchStatusCompleted}, nil).Once()
+			},
+			args: args{
+				request:        request(models.BatchModeBestEffort),
+				asyncThreshold: 100,
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, batch models.Batch) {
+				assert.Equal(t, models.BatchStatusCompleted, batch.Status)
+			},
+		},
+		{
+			name: "Success - atomic batch rolled back when an item fails",
+			initMocks: func() {
+				createBatch()
+				repositoryMock.On("ClaimBatch", uint(7), mock.Anything).Return(true, nil).Once()
+				repositoryMock.On("GetBatch", 7).
+					Return(models.Batch{ID: 7, Mode: models.BatchModeAtomic, Items: items()}, nil).Once()
+
+				// wallets locked up front, then loaded again by each item
+				repositoryMock.On("GetWalletForUpdate", 1).Return(models.Wallet{Balance: decimal.NewFromInt(100)}, nil).Twice()
+				repositoryMock.On("GetWalletForUpdate", 2).Return(models.Wallet{Balance: decimal.NewFromInt(20)}, nil).Twice()
+				repositoryMock.On("UpdateWallet", mock.Anything).Return(nil).Once()
+				repositoryMock.On("CreateTransaction", mock.Anything).Return(nil).Once()
+				expectCounterparty(repositoryMock, models.SystemAccountFunding, models.TransactionTypeCredit, "")
+
+				itemCompleted(1, models.BatchItemStatusRolledBack, "")
+				itemCompleted(2, models.BatchItemStatusFailed, ErrorCodeInsufficientFunds)
+				repositoryMock.On("UpdateBatch", mock.MatchedBy(func(batch *models.Batch) bool {
+					return batch.Status == models.BatchStatusFailed && batch.FailedItems == 1
+				})).Return(nil).Once()
+				repositoryMock.On("GetBatch", 7).
+					Return(models.Batch{ID: 7, Status: models.BatchStatusFailed}, nil).Once()
+			},
+			args: args{
+				request:        request(models.BatchModeAtomic),
+				asyncThreshold: 100,
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, batch models.Batch) {
+				assert.Equal(t, models.BatchStatusFailed, batch.Status)
+			},
+		},
+		{
+			name: "Success - large batch left pending for the background",
+			initMocks: func() {
+				createBatch()
+			},
+			args: args{
+				request:        request(models.BatchModeBestEffort),
+				asyncThreshold: 1,
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+				assert.Equal(t, []uint{7}, dispatched)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, batch models.Batch) {
+				assert.Equal(t, models.BatchStatusPending, batch.Status)
+				assert.Equal(t, 2, batch.TotalItems)
+			},
+		},
+		{
+			name:      "Error - item without wallet",
+			initMocks: func() {},
+			args: args{
+				request: models.BatchRequest{
+					Mode:  models.BatchModeAtomic,
+					Items: []models.BatchItemRequest{{Type: models.BatchItemTypeTransfer, SourceWalletID: 1, Amount: decimal.NewFromInt(1)}},
+				},
+				asyncThreshold: 100,
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeInvalidBatchItem, e.(*exceptions.ForbiddenException).Code)
+			},
+			assertFunc: func(t *testing.T, batch models.Batch) {
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dispatched = nil
+			tt.initMocks()
+			service := BatchService{
+				transactionRepository: repositoryMock,
+				asyncThreshold:        tt.args.asyncThreshold,
+				maxItems:              10,
+				processAsync: func(batchId uint) {
+					dispatched = append(dispatched, batchId)
+				},
+			}
+
+			batch, err := service.Submit(tt.args.request)
+			tt.assertMocks(t)
+			tt.assertError(t, err)
+			tt.assertFunc(t, batch)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"github.com/spf13/viper"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"github.com/wallet-api/exceptions"
+	"sort"
+	"time"
+)
+
+const lockingOptimistic string = "optimistic"
+const sourceEvents string = "events"
+
+// concurrencyPolicy decides how wallets are protected against concurrent updates: pessimistic
+// mode locks the wallet rows, optimistic mode relies on the wallet version and retries on conflict.
+// When eventSourced the wallet balances and statuses are folded from the wallet event streams, which
+// every wallet change appends to
+type concurrencyPolicy struct {
+	optimistic   bool
+	eventSourced bool
+	attempts     int
+	backoff      time.Duration
+}
+
+func (policy concurrencyPolicy) loadWallet(repository repositories.ITransactionRepository, walletId int) (models.Wallet, error) {
+	if policy.optimistic {
+		return repository.GetWalletFromDatabase(walletId)
+	}
+	return repository.GetWalletForUpdate(walletId)
+}
+
+// lockWallets loads the wallets always in ascending id order, so two operations touching the same
+// wallets in opposite order cannot deadlock waiting for each other's row lock
+func (policy concurrencyPolicy) lockWallets(repository repositories.ITransactionRepository, walletIds ...int) (map[int]*models.Wallet, error) {
+	ordered := append([]int{}, walletIds...)
+	sort.Ints(ordered)
+
+	wallets := make(map[int]*models.Wallet, len(ordered))
+	for _, walletId := range ordered {
+		wallet, err := policy.loadWallet(repository, walletId)
+		if err != nil {
+			return nil, err
+		}
+		wallets[walletId] = &wallet
+	}
+	return wallets, nil
+}
+
+// run executes fn inside a database transaction, retrying it with exponential backoff while it
+// fails with a ConflictException
+func (policy concurrencyPolicy) run(repository repositories.ITransactionRepository, fn func(repository repositories.ITransactionRepository) error) error {
+	attempts := policy.attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	if policy.eventSourced {
+		fn = withEvents(fn)
+	}
+
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(policy.backoff * time.Duration(1<<uint(attempt-1)))
+		}
+
+		err = repository.WithinTransaction(fn)
+		if _, conflict := err.(*exceptions.ConflictException); !conflict {
+			return err
+		}
+	}
+	return err
+}
+
+func newConcurrencyPolicy() concurrencyPolicy {
+	return concurrencyPolicy{
+		optimistic:   viper.GetString("transactions.locking") == lockingOptimistic,
+		eventSourced: viper.GetString("transactions.source") == sourceEvents,
+		attempts:     viper.GetInt("transactions.retry.attempts"),
+		backoff:      viper.GetDuration("transactions.retry.backoff"),
+	}
+}
@@ -0,0 +1,406 @@
+package services
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+)
+
+type IWalletEventService interface {
+	Replay() (models.ReplayRun, error)
+}
+
+type WalletEventService struct {
+	transactionRepository repositories.ITransactionRepository
+	concurrency           concurrencyPolicy
+	// eventsBatch is how many events of a stream are read at a time
+	eventsBatch int
+}
+
+const replayWalletsBatch int = 100
+
+// defaultEventsBatch is how many events of a stream are read at a time when folding it, by the
+// replay when replay.events_batch is not configured
+const defaultEventsBatch int = 500
+
+// statusEvents maps each wallet status to the event that moves a wallet into it
+var statusEvents = map[string]string{
+	models.WalletStatusActive: models.WalletEventUnfrozen,
+	models.WalletStatusFrozen: models.WalletEventFrozen,
+	models.WalletStatusClosed: models.WalletEventClosed,
+}
+
+// Replay rebuilds the balance and status of every wallet with events from its full stream, overwriting
+// the wallet rows that drifted from it. Holds and overdraft limits are not event sourced and are kept
+func (service *WalletEventService) Replay() (models.ReplayRun, error) {
+	var run models.ReplayRun
+
+	var afterId uint
+	for {
+		ids, err := service.transactionRepository.GetEventSourcedWalletIDs(afterId, replayWalletsBatch)
+		if err != nil {
+			return run, err
+		}
+
+		for _, id := range ids {
+			events, rebuilt, err := service.rebuild(int(id))
+			if err != nil {
+				logrus.Errorf("couldn't replay the events of wallet %d: %v", id, err)
+				run.FailedWallets = append(run.FailedWallets, id)
+				continue
+			}
+			run.Wallets++
+			run.Events += events
+			if rebuilt {
+				run.Rebuilt++
+			}
+		}
+
+		if len(ids) < replayWalletsBatch {
+			return run, nil
+		}
+		afterId = ids[len(ids)-1]
+	}
+}
+
+// rebuild projects the wallet from its events, saving it when the row disagrees with the projection
+func (service *WalletEventService) rebuild(walletId int) (int, bool, error) {
+	events := 0
+	rebuilt := false
+	err := service.concurrency.run(service.transactionRepository, func(repository repositories.ITransactionRepository) error {
+		wallet, err := service.concurrency.loadWallet(repository, walletId)
+		if err != nil {
+			return err
+		}
+
+		projection, _, read, err := foldStream(repository, wallet, service.eventsBatch)
+		events = read
+		if err != nil {
+			return err
+		}
+		if read == 0 {
+			return fmt.Errorf("wallet %d has no events", walletId)
+		}
+
+		if projection.Balance.Equal(wallet.Balance) && projection.Status == wallet.Status {
+			return nil
+		}
+
+		logrus.Warnf("wallet %d drifted from its events: balance %s status %s, projected balance %s status %s",
+			walletId, wallet.Balance.String(), wallet.Status, projection.Balance.String(), projection.Status)
+		rebuilt = true
+		return repository.UpdateWallet(&projection)
+	})
+
+	return events, rebuilt, err
+}
+
+// foldStream folds the event stream of the wallet over its row a page at a time, so long streams are
+// not loaded whole. It returns the projection, the sequence of the last event and how many events
+// were read; a wallet without events is returned as it is
+func foldStream(repository repositories.ITransactionRepository, wallet models.Wallet, eventsBatch int) (models.Wallet, uint, int, error) {
+	if eventsBatch < 1 {
+		eventsBatch = defaultEventsBatch
+	}
+
+	projection := wallet
+	var sequence uint
+	events := 0
+	for {
+		page, err := repository.GetWalletEvents(int(wallet.ID), sequence, eventsBatch)
+		if err != nil {
+			return wallet, sequence, events, err
+		}
+		if events == 0 && len(page) == 0 {
+			return wallet, 0, 0, nil
+		}
+
+		if events == 0 {
+			projection, err = projectWallet(projection, page)
+		} else {
+			projection, err = projectEvents(projection, page)
+		}
+		if err != nil {
+			return wallet, sequence, events, err
+		}
+		events += len(page)
+		if len(page) > 0 {
+			sequence = page[len(page)-1].Sequence
+		}
+
+		if len(page) < eventsBatch {
+			return projection, sequence, events, nil
+		}
+	}
+}
+
+// projectedWallet reads the wallet outside of a transaction with its balance and status folded from
+// its events
+func projectedWallet(repository repositories.ITransactionRepository, walletId int) (models.Wallet, error) {
+	wallets, err := projectedWallets(repository, func(repository repositories.ITransactionRepository) ([]models.Wallet, error) {
+		wallet, err := repository.GetWalletFromDatabase(walletId)
+		return []models.Wallet{wallet}, err
+	})
+	if err != nil {
+		return models.Wallet{}, err
+	}
+	return wallets[0], nil
+}
+
+// projectedWallets reads wallets outside of a transaction with the balance and status of each folded
+// from its events, the rows and the streams read from the same snapshot
+func projectedWallets(repository repositories.ITransactionRepository, read func(repository repositories.ITransactionRepository) ([]models.Wallet, error)) ([]models.Wallet, error) {
+	var wallets []models.Wallet
+	err := repository.WithinSnapshot(func(repository repositories.ITransactionRepository) error {
+		var err error
+		if wallets, err = read(repository); err != nil {
+			return err
+		}
+		for i := range wallets {
+			if wallets[i].IsSystem() {
+				continue
+			}
+			if wallets[i], _, _, err = foldStream(repository, wallets[i], defaultEventsBatch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return wallets, err
+}
+
+// projectWallet folds the events of the wallet, which must start with WalletOpened, over the parts of
+// the wallet row that are not event sourced
+func projectWallet(wallet models.Wallet, events []models.WalletEvent) (models.Wallet, error) {
+	if len(events) == 0 || events[0].Type != models.WalletEventOpened {
+		return wallet, fmt.Errorf("the events of wallet %d don't start with %s", wallet.ID, models.WalletEventOpened)
+	}
+	return projectEvents(wallet, events)
+}
+
+// projectEvents folds events that continue the stream of the wallet over it
+func projectEvents(wallet models.Wallet, events []models.WalletEvent) (models.Wallet, error) {
+	for _, event := range events {
+		if err := project(&wallet, event); err != nil {
+			return wallet, err
+		}
+	}
+	return wallet, nil
+}
+
+// project applies the event to the wallet
+func project(wallet *models.Wallet, event models.WalletEvent) error {
+	data, err := event.Data()
+	if err != nil {
+		return err
+	}
+
+	switch event.Type {
+	case models.WalletEventOpened:
+		wallet.Currency = data.Currency
+		wallet.Product = data.Product
+		wallet.OwnerID = data.OwnerID
+		wallet.SystemAccount = data.SystemAccount
+		wallet.Status = data.Status
+		wallet.Balance = decimal.Zero
+		if data.Balance != nil {
+			wallet.Balance = *data.Balance
+		}
+	case models.WalletEventCredited, models.WalletEventDebited:
+		if data.Amount == nil {
+			return fmt.Errorf("event %d of wallet %d has no amount", event.Sequence, event.WalletID)
+		}
+		if event.Type == models.WalletEventDebited {
+			wallet.Balance = wallet.Balance.Sub(*data.Amount)
+		} else {
+			wallet.Balance = wallet.Balance.Add(*data.Amount)
+		}
+	default:
+		for status, eventType := range statusEvents {
+			if event.Type == eventType {
+				wallet.Status = status
+				return nil
+			}
+		}
+		return fmt.Errorf("event %d of wallet %d has unknown type %s", event.Sequence, event.WalletID, event.Type)
+	}
+	return nil
+}
+
+// eventSourcedRepository makes the event streams the source of truth of the wallets: every wallet it
+// loads gets its balance and status folded from its stream, and every change the services make to a
+// wallet is appended to the stream in the same database transaction, the wallet rows being written
+// as its projection. Appends expect the stream unchanged since the wallet was loaded and fail with a
+// ConflictException otherwise, which the concurrency policy retries
+type eventSourcedRepository struct {
+	repositories.ITransactionRepository
+	streams map[uint]*walletStream
+	// systemAccounts holds the ids of the system accounts read in the transaction, they have no stream
+	// since their rows are not maintained
+	systemAccounts map[uint]bool
+}
+
+// walletStream is what the transaction knows about the stream of a loaded wallet
+type walletStream struct {
+	sequence uint
+	status   string
+}
+
+// withEvents makes fn run against an eventSourcedRepository, a new one on every attempt
+func withEvents(fn func(repository repositories.ITransactionRepository) error) func(repository repositories.ITransactionRepository) error {
+	return func(repository repositories.ITransactionRepository) error {
+		return fn(&eventSourcedRepository{
+			ITransactionRepository: repository,
+			streams:                map[uint]*walletStream{},
+			systemAccounts:         map[uint]bool{},
+		})
+	}
+}
+
+func (repository *eventSourcedRepository) GetWalletForUpdate(walletId int) (models.Wallet, error) {
+	wallet, err := repository.ITransactionRepository.GetWalletForUpdate(walletId)
+	if err != nil {
+		return wallet, err
+	}
+	return repository.track(wallet)
+}
+
+func (repository *eventSourcedRepository) GetWalletFromDatabase(walletId int) (models.Wallet, error) {
+	wallet, err := repository.ITransactionRepository.GetWalletFromDatabase(walletId)
+	if err != nil {
+		return wallet, err
+	}
+	return repository.track(wallet)
+}
+
+func (repository *eventSourcedRepository) GetSystemAccount(accountType string, currency string) (models.Wallet, error) {
+	account, err := repository.ITransactionRepository.GetSystemAccount(accountType, currency)
+	if err == nil {
+		repository.systemAccounts[account.ID] = true
+	}
+	return account, err
+}
+
+func (repository *eventSourcedRepository) CreateWallet(wallet *models.Wallet) error {
+	if err := repository.ITransactionRepository.CreateWallet(wallet); err != nil {
+		return err
+	}
+	repository.streams[wallet.ID] = &walletStream{}
+	return repository.open(*wallet)
+}
+
+func (repository *eventSourcedRepository) UpdateWallet(wallet *models.Wallet) error {
+	if err := repository.ITransactionRepository.UpdateWallet(wallet); err != nil {
+		return err
+	}
+
+	stream, err := repository.stream(wallet.ID)
+	if err != nil || stream.status == wallet.Status {
+		return err
+	}
+	stream.status = wallet.Status
+	return repository.append(wallet.ID, statusEvents[wallet.Status], models.WalletEventData{})
+}
+
+func (repository *eventSourcedRepository) CreateTransaction(transaction *models.Transaction) error {
+	if err := repository.ITransactionRepository.CreateTransaction(transaction); err != nil {
+		return err
+	}
+	if repository.systemAccounts[transaction.WalletID] {
+		return nil
+	}
+
+	eventType := models.WalletEventCredited
+	if transaction.Direction == models.DirectionDebit {
+		eventType = models.WalletEventDebited
+	}
+	amount := transaction.Amount
+	transactionId := transaction.ID
+	return repository.append(transaction.WalletID, eventType, models.WalletEventData{Amount: &amount, TransactionID: &transactionId})
+}
+
+// track folds the stream of a loaded wallet into its balance and status, remembering its sequence the
+// first time the transaction loads the wallet. Wallets that were written before the event sourced mode
+// start their stream with their current state
+func (repository *eventSourcedRepository) track(wallet models.Wallet) (models.Wallet, error) {
+	if repository.systemAccounts[wallet.ID] {
+		return wallet, nil
+	}
+	if wallet.IsSystem() {
+		repository.systemAccounts[wallet.ID] = true
+		return wallet, nil
+	}
+
+	projection, sequence, events, err := foldStream(repository.ITransactionRepository, wallet, defaultEventsBatch)
+	if err != nil {
+		return wallet, err
+	}
+	if !projection.Balance.Equal(wallet.Balance) || projection.Status != wallet.Status {
+		logrus.Warnf("wallet %d row drifted from its events: balance %s status %s, projected balance %s status %s",
+			wallet.ID, wallet.Balance.String(), wallet.Status, projection.Balance.String(), projection.Status)
+	}
+	if _, ok := repository.streams[wallet.ID]; ok {
+		return projection, nil
+	}
+
+	repository.streams[wallet.ID] = &walletStream{sequence: sequence, status: projection.Status}
+	if events > 0 {
+		return projection, nil
+	}
+	return projection, repository.open(projection)
+}
+
+func (repository *eventSourcedRepository) open(wallet models.Wallet) error {
+	repository.streams[wallet.ID].status = wallet.Status
+	balance := wallet.Balance
+	return repository.append(wallet.ID, models.WalletEventOpened, models.WalletEventData{
+		Currency:      wallet.Currency,
+		Product:       wallet.Product,
+		OwnerID:       wallet.OwnerID,
+		SystemAccount: wallet.SystemAccount,
+		Status:        wallet.Status,
+		Balance:       &balance,
+	})
+}
+
+func (repository *eventSourcedRepository) append(walletId uint, eventType string, data models.WalletEventData) error {
+	stream, err := repository.stream(walletId)
+	if err != nil {
+		return err
+	}
+	event, err := models.NewWalletEvent(eventType, data)
+	if err != nil {
+		return err
+	}
+
+	if err := repository.AppendWalletEvents(int(walletId), stream.sequence, []models.WalletEvent{event}); err != nil {
+		return err
+	}
+	stream.sequence++
+	return nil
+}
+
+// stream returns the stream of a wallet loaded in the transaction, changes to wallets that were not
+// loaded first cannot be appended
+func (repository *eventSourcedRepository) stream(walletId uint) (*walletStream, error) {
+	stream, ok := repository.streams[walletId]
+	if !ok {
+		return nil, fmt.Errorf("wallet %d was changed without being loaded in the transaction", walletId)
+	}
+	return stream, nil
+}
+
+// NewWalletEventService replays the events without appending new ones, whatever the configured source
+func NewWalletEventService() IWalletEventService {
+	concurrency := newConcurrencyPolicy()
+	concurrency.eventSourced = false
+
+	return &WalletEventService{
+		transactionRepository: repositories.NewTransactionRepository(),
+		concurrency:           concurrency,
+		eventsBatch:           viper.GetInt("replay.events_batch"),
+	}
+}
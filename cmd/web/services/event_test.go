@@ -0,0 +1,317 @@
+package services
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"testing"
+)
+
+func walletEvent(eventType string, data models.WalletEventData) models.WalletEvent {
+	event, _ := models.NewWalletEvent(eventType, data)
+	return event
+}
+
+func amountData(amount int64) models.WalletEventData {
+	value := decimal.NewFromInt(amount)
+	return models.WalletEventData{Amount: &value}
+}
+
+// numberedEvents numbers the events from 1 as the repository stores them
+func numberedEvents(events ...models.WalletEvent) []models.WalletEvent {
+	for i := range events {
+		events[i].Sequence = uint(i) + 1
+	}
+	return events
+}
+
+// openedAt is the WalletOpened event of an active EUR wallet with the given balance
+func openedAt(balance int64) models.WalletEvent {
+	opening := decimal.NewFromInt(balance)
+	return walletEvent(models.WalletEventOpened, models.WalletEventData{Currency: "EUR", Status: models.WalletStatusActive, Balance: &opening})
+}
+
+// appendedEvent matches an append of a single event of the given type carrying the given amount, if any
+func appendedEvent(eventType string, amount *decimal.Decimal) interface{} {
+	return mock.MatchedBy(func(events []models.WalletEvent) bool {
+		if len(events) != 1 || events[0].Type != eventType {
+			return false
+		}
+		data, err := events[0].Data()
+		if err != nil || amount == nil {
+			return err == nil
+		}
+		return data.Amount != nil && data.Amount.Equal(*amount)
+	})
+}
+
+func TestProjectWallet(t *testing.T) {
+	opening := decimal.NewFromInt(100)
+	opened := walletEvent(models.WalletEventOpened, models.WalletEventData{
+		Currency: "EUR", Product: models.WalletProductStandard, Status: models.WalletStatusActive, Balance: &opening,
+	})
+
+	tests := []struct {
+		name        string
+		events      []models.WalletEvent
+		assertError func(*testing.T, error)
+		assertFunc  func(*testing.T, models.Wallet)
+	}{
+		{
+			name: "Success - balance and status folded from the opening state",
+			events: []models.WalletEvent{
+				opened,
+				walletEvent(models.WalletEventCredited, amountData(50)),
+				walletEvent(models.WalletEventDebited, amountData(30)),
+				walletEvent(models.WalletEventFrozen, models.WalletEventData{}),
+			},
+			assertError: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+			assertFunc: func(t *testing.T, wallet models.Wallet) {
+				assert.True(t, decimal.NewFromInt(120).Equal(wallet.Balance))
+				assert.Equal(t, models.WalletStatusFrozen, wallet.Status)
+				assert.Equal(t, "EUR", wallet.Currency)
+				assert.True(t, decimal.NewFromInt(5).Equal(wallet.HeldBalance))
+			},
+		},
+		{
+			name: "Success - unfrozen and closed",
+			events: []models.WalletEvent{
+				opened,
+				walletEvent(models.WalletEventFrozen, models.WalletEventData{}),
+				walletEvent(models.WalletEventUnfrozen, models.WalletEventData{}),
+				walletEvent(models.WalletEventDebited, amountData(100)),
+				walletEvent(models.WalletEventClosed, models.WalletEventData{}),
+			},
+			assertError: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+			assertFunc: func(t *testing.T, wallet models.Wallet) {
+				assert.True(t, wallet.Balance.IsZero())
+				assert.Equal(t, models.WalletStatusClosed, wallet.Status)
+			},
+		},
+		{
+			name:   "Error - stream not opened",
+			events: []models.WalletEvent{walletEvent(models.WalletEventCredited, amountData(50))},
+			assertError: func(t *testing.T, err error) {
+				assert.EqualError(t, err, "the events of wallet 1 don't start with WalletOpened")
+			},
+		},
+		{
+			name:   "Error - unknown event type",
+			events: []models.WalletEvent{opened, walletEvent("Renamed", models.WalletEventData{})},
+			assertError: func(t *testing.T, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+		{
+			name:   "Error - movement without amount",
+			events: []models.WalletEvent{opened, walletEvent(models.WalletEventCredited, models.WalletEventData{})},
+			assertError: func(t *testing.T, err error) {
+				assert.NotNil(t, err)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wallet := models.Wallet{HeldBalance: decimal.NewFromInt(5)}
+			wallet.ID = 1
+
+			projection, err := projectWallet(wallet, tt.events)
+			tt.assertError(t, err)
+			if tt.assertFunc != nil {
+				tt.assertFunc(t, projection)
+			}
+		})
+	}
+}
+
+func TestTransactionService_CreditEventSourced(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	wallet := models.Wallet{Balance: decimal.NewFromInt(100), Currency: "EUR", Status: models.WalletStatusActive}
+	wallet.ID = 1
+	amount := decimal.NewFromInt(10)
+
+	service := TransactionService{
+		transactionRepository: repositoryMock,
+		concurrency:           concurrencyPolicy{eventSourced: true},
+	}
+
+	tests := []struct {
+		name        string
+		initMocks   func()
+		assertMocks func(*testing.T)
+		assertError func(*testing.T, error)
+	}{
+		{
+			name: "Success - the credit applied to the balance folded from the events and appended, the system account has no stream",
+			initMocks: func() {
+				repositoryMock.On("GetWalletForUpdate", 1).Return(wallet, nil).Once()
+				// the row drifted to 100 while the events make 80
+				repositoryMock.On("GetWalletEvents", 1, uint(0), defaultEventsBatch).Return(numberedEvents(openedAt(50),
+					walletEvent(models.WalletEventCredited, amountData(40)), walletEvent(models.WalletEventDebited, amountData(20)),
+					walletEvent(models.WalletEventCredited, amountData(10))), nil).Once()
+				repositoryMock.On("UpdateWallet", mock.MatchedBy(func(wallet *models.Wallet) bool {
+					return wallet.Balance.Equal(decimal.NewFromInt(90))
+				})).Return(nil).Once()
+				repositoryMock.On("CreateTransaction", mock.Anything).Return(nil).Once()
+				repositoryMock.On("AppendWalletEvents", 1, uint(4), appendedEvent(models.WalletEventCredited, &amount)).Return(nil).Once()
+				expectCounterparty(repositoryMock, models.SystemAccountFunding, models.TransactionTypeCredit, "EUR")
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+				repositoryMock.AssertNotCalled(t, "GetWalletEvents", 90, mock.Anything, mock.Anything)
+			},
+			assertError: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+		},
+		{
+			name: "Error - stream appended concurrently",
+			initMocks: func() {
+				repositoryMock.On("GetWalletForUpdate", 1).Return(wallet, nil).Once()
+				repositoryMock.On("GetWalletEvents", 1, uint(0), defaultEventsBatch).Return(numberedEvents(openedAt(60),
+					walletEvent(models.WalletEventCredited, amountData(40)), walletEvent(models.WalletEventDebited, amountData(20)),
+					walletEvent(models.WalletEventCredited, amountData(20))), nil).Once()
+				repositoryMock.On("UpdateWallet", mock.Anything).Return(nil).Once()
+				repositoryMock.On("CreateTransaction", mock.Anything).Return(nil).Once()
+				repositoryMock.On("AppendWalletEvents", 1, uint(4), mock.Anything).
+					Return(exceptions.NewConflictException("events of wallet with id=%s were appended concurrently", "1")).Once()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+				repositoryMock.AssertNotCalled(t, "GetSystemAccount", mock.Anything, mock.Anything)
+			},
+			assertError: func(t *testing.T, err error) {
+				assert.IsType(t, &exceptions.ConflictException{}, err)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repositoryMock.ExpectedCalls = nil
+			repositoryMock.Calls = nil
+			tt.initMocks()
+
+			err := service.Credit(1, models.WalletRequest{Amount: amount})
+			tt.assertMocks(t)
+			tt.assertError(t, err)
+		})
+	}
+}
+
+func TestWalletService_FreezeEventSourced(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	wallet := models.Wallet{Balance: decimal.NewFromInt(100), Currency: "EUR", Status: models.WalletStatusActive}
+	wallet.ID = 1
+	repositoryMock.On("GetWalletForUpdate", 1).Return(wallet, nil).Once()
+	repositoryMock.On("GetWalletEvents", 1, uint(0), defaultEventsBatch).Return(nil, nil).Once()
+	repositoryMock.On("AppendWalletEvents", 1, uint(0), appendedEvent(models.WalletEventOpened, nil)).Return(nil).Once()
+	repositoryMock.On("UpdateWallet", mock.Anything).Return(nil).Once()
+	repositoryMock.On("AppendWalletEvents", 1, uint(1), appendedEvent(models.WalletEventFrozen, nil)).Return(nil).Once()
+
+	service := WalletService{
+		transactionRepository: repositoryMock,
+		concurrency:           concurrencyPolicy{eventSourced: true},
+	}
+
+	frozen, err := service.Freeze(1)
+	repositoryMock.AssertExpectations(t)
+	assert.Nil(t, err)
+	assert.Equal(t, models.WalletStatusFrozen, frozen.Status)
+}
+
+func TestWalletEventService_Replay(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	opening := decimal.NewFromInt(100)
+	opened := walletEvent(models.WalletEventOpened, models.WalletEventData{Currency: "EUR", Status: models.WalletStatusActive, Balance: &opening})
+	drifted := models.Wallet{Balance: decimal.NewFromInt(90), Currency: "EUR", Status: models.WalletStatusActive}
+	drifted.ID = 1
+	consistent := models.Wallet{Balance: decimal.NewFromInt(100), Currency: "EUR", Status: models.WalletStatusActive}
+	consistent.ID = 2
+	broken := models.Wallet{Currency: "EUR", Status: models.WalletStatusActive}
+	broken.ID = 3
+
+	repositoryMock.On("GetEventSourcedWalletIDs", uint(0), replayWalletsBatch).Return([]uint{1, 2, 3}, nil).Once()
+	repositoryMock.On("GetWalletForUpdate", 1).Return(drifted, nil).Once()
+	// the stream of wallet 1 is read in two pages
+	credited := walletEvent(models.WalletEventCredited, amountData(20))
+	credited.Sequence = 2
+	repositoryMock.On("GetWalletEvents", 1, uint(0), 2).Return([]models.WalletEvent{opened, credited}, nil).Once()
+	repositoryMock.On("GetWalletEvents", 1, uint(2), 2).Return([]models.WalletEvent{walletEvent(models.WalletEventDebited, amountData(5))}, nil).Once()
+	repositoryMock.On("UpdateWallet", mock.MatchedBy(func(wallet *models.Wallet) bool {
+		return wallet.ID == 1 && wallet.Balance.Equal(decimal.NewFromInt(115))
+	})).Return(nil).Once()
+	repositoryMock.On("GetWalletForUpdate", 2).Return(consistent, nil).Once()
+	repositoryMock.On("GetWalletEvents", 2, uint(0), 2).Return([]models.WalletEvent{opened}, nil).Once()
+	repositoryMock.On("GetWalletForUpdate", 3).Return(broken, nil).Once()
+	repositoryMock.On("GetWalletEvents", 3, uint(0), 2).Return([]models.WalletEvent{walletEvent(models.WalletEventDebited, amountData(5))}, nil).Once()
+
+	service := WalletEventService{
+		transactionRepository: repositoryMock,
+		eventsBatch:           2,
+	}
+
+	run, err := service.Replay()
+	repositoryMock.AssertExpectations(t)
+	assert.Nil(t, err)
+	assert.Equal(t, models.ReplayRun{Wallets: 2, Events: 4, Rebuilt: 1, FailedWallets: []uint{3}}, run)
+}
+
+func TestReconciliationService_FreezeEventSourced(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	wallet := models.Wallet{Balance: decimal.NewFromInt(100), Currency: "EUR", Status: models.WalletStatusActive}
+	wallet.ID = 1
+	freeze := true
+	repositoryMock.On("GetWalletIDs", uint(0), reconciliationWalletsBatch).Return([]uint{1}, nil).Once()
+	stream := numberedEvents(openedAt(80),
+		walletEvent(models.WalletEventCredited, amountData(30)), walletEvent(models.WalletEventDebited, amountData(10)))
+	// compared in the snapshot, the balance folded from the events against the ledger
+	repositoryMock.On("GetWalletFromDatabase", 1).Return(wallet, nil).Once()
+	repositoryMock.On("GetWalletEvents", 1, uint(0), defaultEventsBatch).Return(stream, nil).Twice()
+	repositoryMock.On("GetLatestSnapshot", 1, mock.Anything).Return(nil, nil).Once()
+	repositoryMock.On("SumLedger", 1, uint(0), mock.Anything).Return(models.LedgerSum{Total: decimal.NewFromInt(90)}, nil).Once()
+	repositoryMock.On("GetWalletForUpdate", 1).Return(wallet, nil).Once()
+	repositoryMock.On("UpdateWallet", mock.Anything).Return(nil).Once()
+	// without the event a replay would unfreeze the wallet
+	repositoryMock.On("AppendWalletEvents", 1, uint(3), appendedEvent(models.WalletEventFrozen, nil)).Return(nil).Once()
+	repositoryMock.On("GetCachedWallet", 1).Return(nil, nil).Once()
+
+	service := ReconciliationService{
+		transactionRepository: repositoryMock,
+		concurrency:           concurrencyPolicy{eventSourced: true},
+	}
+
+	report, err := service.Reconcile(models.ReconciliationOptions{Freeze: &freeze})
+	repositoryMock.AssertExpectations(t)
+	assert.Nil(t, err)
+	assert.True(t, report.Discrepancies[0].Frozen)
+}
+
+func TestWalletService_GetEventSourced(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	// the row lags behind the stream, the events are what the wallet reports
+	wallet := models.Wallet{Balance: decimal.NewFromInt(100), Currency: "EUR", Status: models.WalletStatusActive}
+	wallet.ID = 1
+	repositoryMock.On("GetWalletFromDatabase", 1).Return(wallet, nil).Once()
+	repositoryMock.On("GetWalletEvents", 1, uint(0), defaultEventsBatch).Return(numberedEvents(openedAt(100),
+		walletEvent(models.WalletEventDebited, amountData(30)), walletEvent(models.WalletEventFrozen, models.WalletEventData{})), nil).Once()
+
+	service := WalletService{
+		transactionRepository: repositoryMock,
+		concurrency:           concurrencyPolicy{eventSourced: true},
+	}
+
+	projection, err := service.Get(1)
+	repositoryMock.AssertExpectations(t)
+	assert.Nil(t, err)
+	assert.True(t, projection.Balance.Equal(decimal.NewFromInt(70)))
+	assert.Equal(t, models.WalletStatusFrozen, projection.Status)
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"github.com/wallet-api/exceptions"
+)
+
+type IFeeService interface {
+	Quote(request models.FeeQuoteRequest) (models.FeeQuote, error)
+}
+
+type FeeService struct {
+	transactionRepository repositories.ITransactionRepository
+	fees                  feePolicy
+}
+
+// Quote returns the fee the operation would be charged without moving any funds
+func (service *FeeService) Quote(request models.FeeQuoteRequest) (models.FeeQuote, error) {
+	if !request.Amount.IsPositive() {
+		return models.FeeQuote{}, exceptions.NewForbiddenException("operation not allowed: %s", ErrorCodeInvalidParamsPositive)
+	}
+
+	wallet, err := service.transactionRepository.GetWallet(request.WalletID)
+	if err != nil {
+		return models.FeeQuote{}, err
+	}
+	if err := checkAmount(wallet, request.Amount, ""); err != nil {
+		return models.FeeQuote{}, err
+	}
+
+	return models.FeeQuote{
+		Operation: request.Operation,
+		WalletID:  wallet.ID,
+		Amount:    request.Amount,
+		Fee:       service.fees.fee(request.Operation, wallet, request.Amount),
+		Currency:  wallet.Currency,
+	}, nil
+}
+
+// feePolicy prices operations with the first matching rule and credits the fees to the fee income
+// system account of each currency. The zero value charges nothing.
+type feePolicy struct {
+	rules []models.FeeRule
+}
+
+func (policy feePolicy) fee(operation string, wallet models.Wallet, amount decimal.Decimal) decimal.Decimal {
+	for _, rule := range policy.rules {
+		if !rule.Matches(operation, wallet) {
+			continue
+		}
+
+		fee := rule.Fee(amount)
+		if units, ok := models.MinorUnits(wallet.Currency); ok {
+			fee = fee.Round(units)
+		}
+		if fee.IsNegative() {
+			return decimal.Zero
+		}
+		return fee
+	}
+	return decimal.Zero
+}
+
+// charge posts the fee as its own ledger line on the paying wallet and credits it to the fee income
+// account, both under the reference of the operation that caused it. The fee income account is not
+// locked, so charging takes no lock beyond the ones of the operation
+func (policy feePolicy) charge(repository repositories.ITransactionRepository, wallet *models.Wallet, fee decimal.Decimal, operationReference string) error {
+	if !fee.IsPositive() {
+		return nil
+	}
+
+	debit, err := postEntry(repository, wallet, models.Transaction{
+		Type:               models.TransactionTypeFee,
+		Direction:          models.DirectionDebit,
+		Amount:             fee,
+		OperationReference: operationReference,
+	})
+	if err != nil {
+		return err
+	}
+	return postCounterparty(repository, models.SystemAccountFeeIncome, *wallet, debit)
+}
+
+// refundFees credits back the share of the operation fees matching the share of the original entry
+// being reversed, under the reference of the reversal. The shares are rounded on the cumulative reversed
+// amount, so once the entry is fully reversed the whole fee has been refunded.
+func refundFees(repository repositories.ITransactionRepository, wallet *models.Wallet, original models.Transaction, reversedBefore decimal.Decimal, amount decimal.Decimal, operationReference string) error {
+	if original.OperationReference == "" {
+		return nil
+	}
+
+	fees, err := repository.GetOperationFees(int(original.WalletID), original.OperationReference)
+	if err != nil {
+		return err
+	}
+	for _, fee := range fees {
+		feeId := fee.ID
+		refund := feeShare(fee, original, reversedBefore.Add(amount)).Sub(feeShare(fee, original, reversedBefore))
+		if !refund.IsPositive() {
+			continue
+		}
+
+		credit, err := postEntry(repository, wallet, models.Transaction{
+			Type:                  models.TransactionTypeReversal,
+			Direction:             models.DirectionCredit,
+			Amount:                refund,
+			ReversedTransactionID: &feeId,
+			OperationReference:    operationReference,
+		})
+		if err != nil {
+			return err
+		}
+		if err := postCounterparty(repository, models.SystemAccountFeeIncome, *wallet, credit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// feeShare is the part of the fee matching the reversed part of the original entry
+func feeShare(fee models.Transaction, original models.Transaction, reversed decimal.Decimal) decimal.Decimal {
+	if reversed.GreaterThanOrEqual(original.Amount) {
+		return fee.Amount
+	}
+	share := fee.Amount.Mul(reversed).Div(original.Amount)
+	if units, ok := models.MinorUnits(fee.Currency); ok {
+		share = share.Round(units)
+	}
+	return share
+}
+
+// newFeePolicy reads the fee rules in precedence order
+func newFeePolicy() feePolicy {
+	var entries []struct {
+		Operation string `mapstructure:"operation"`
+		Product   string `mapstructure:"product"`
+		Currency  string `mapstructure:"currency"`
+		Type      string `mapstructure:"type"`
+		Amount    string `mapstructure:"amount"`
+		Rate      string `mapstructure:"rate"`
+		Min       string `mapstructure:"min"`
+		Max       string `mapstructure:"max"`
+		Tiers     []struct {
+			UpTo   string `mapstructure:"up_to"`
+			Amount string `mapstructure:"amount"`
+			Rate   string `mapstructure:"rate"`
+		} `mapstructure:"tiers"`
+	}
+	if err := viper.UnmarshalKey("fees.rules", &entries); err != nil {
+		logrus.Errorf("couldn't read the fee rules: %v", err)
+	}
+
+	var policy feePolicy
+	for _, entry := range entries {
+		parser := &feeValueParser{}
+		rule := models.FeeRule{
+			Operation: entry.Operation,
+			Product:   entry.Product,
+			Currency:  entry.Currency,
+			Type:      entry.Type,
+			Amount:    parser.value(entry.Amount),
+			Rate:      parser.value(entry.Rate),
+			Min:       parser.nullable(entry.Min),
+			Max:       parser.nullable(entry.Max),
+		}
+		for _, tier := range entry.Tiers {
+			rule.Tiers = append(rule.Tiers, models.FeeTier{
+				UpTo:   parser.nullable(tier.UpTo),
+				Amount: parser.value(tier.Amount),
+				Rate:   parser.value(tier.Rate),
+			})
+		}
+		if parser.err != nil {
+			logrus.Errorf("couldn't read a %s fee rule: %v", entry.Operation, parser.err)
+			continue
+		}
+		policy.rules = append(policy.rules, rule)
+	}
+	return policy
+}
+
+// feeValueParser parses the decimals of a fee rule keeping the first error, empty values are zero or null
+type feeValueParser struct {
+	err error
+}
+
+func (parser *feeValueParser) value(value string) decimal.Decimal {
+	return parser.nullable(value).Decimal
+}
+
+func (parser *feeValueParser) nullable(value string) decimal.NullDecimal {
+	if value == "" || parser.err != nil {
+		return decimal.NullDecimal{}
+	}
+	parsed, err := decimal.NewFromString(value)
+	parser.err = err
+	return decimal.NullDecimal{Decimal: parsed, Valid: err == nil}
+}
+
+func NewFeeService() IFeeService {
+	return &FeeService{
+		transactionRepository: repositories.NewTransactionRepository(),
+		fees:                  newFeePolicy(),
+	}
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"testing"
+)
+
+func TestFeeRule_Fee(t *testing.T) {
+
+	bound := func(value int64) decimal.NullDecimal {
+		return decimal.NullDecimal{Decimal: decimal.NewFromInt(value), Valid: true}
+	}
+
+	tests := []struct {
+		name   string
+		rule   models.FeeRule
+		amount decimal.Decimal
+		fee    decimal.Decimal
+	}{
+		{
+			name:   "Fixed",
+			rule:   models.FeeRule{Type: models.FeeTypeFixed, Amount: decimal.NewFromFloat(1.5)},
+			amount: decimal.NewFromInt(100),
+			fee:    decimal.NewFromFloat(1.5),
+		},
+		{
+			name:   "Percentage",
+			rule:   models.FeeRule{Type: models.FeeTypePercentage, Rate: decimal.NewFromFloat(0.01)},
+			amount: decimal.NewFromInt(250),
+			fee:    decimal.NewFromFloat(2.5),
+		},
+		{
+			name:   "Percentage raised to the minimum",
+			rule:   models.FeeRule{Type: models.FeeTypePercentage, Rate: decimal.NewFromFloat(0.01), Min: bound(1)},
+			amount: decimal.NewFromInt(10),
+			fee:    decimal.NewFromInt(1),
+		},
+		{
+			name:   "Percentage capped at the maximum",
+			rule:   models.FeeRule{Type: models.FeeTypePercentage, Rate: decimal.NewFromFloat(0.01), Max: bound(5)},
+			amount: decimal.NewFromInt(1000),
+			fee:    decimal.NewFromInt(5),
+		},
+		{
+			name: "Tiered within the first tier",
+			rule: models.FeeRule{Type: models.FeeTypeTiered, Tiers: []models.FeeTier{
+				{UpTo: bound(100), Amount: decimal.NewFromInt(1)},
+				{Amount: decimal.NewFromInt(2), Rate: decimal.NewFromFloat(0.01)},
+			}},
+			amount: decimal.NewFromInt(100),
+			fee:    decimal.NewFromInt(1),
+		},
+		{
+			name: "Tiered over the last bound",
+			rule: models.FeeRule{Type: models.FeeTypeTiered, Tiers: []models.FeeTier{
+				{UpTo: bound(100), Amount: decimal.NewFromInt(1)},
+				{Amount: decimal.NewFromInt(2), Rate: decimal.NewFromFloat(0.01)},
+			}},
+			amount: decimal.NewFromInt(300),
+			fee:    decimal.NewFromInt(5),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fee := tt.rule.Fee(tt.amount)
+			assert.True(t, tt.fee.Equal(fee), "expected %s got %s", tt.fee, fee)
+		})
+	}
+}
+
+func TestFeeService_Quote(t *testing.T) {
+
+	repositoryMock := &mocks.RepositoryMock{}
+	fees := feePolicy{
+		rules: []models.FeeRule{
+			{Operation: models.FeeOperationTransfer, Product: models.WalletProductSavings, Type: models.FeeTypeFixed, Amount: decimal.NewFromInt(3)},
+			{Operation: models.FeeOperationTransfer, Type: models.FeeTypePercentage, Rate: decimal.NewFromFloat(0.003)},
+		},
+	}
+	wallet := models.Wallet{Balance: decimal.NewFromInt(100), Currency: "EUR", Product: models.WalletProductStandard}
+	wallet.ID = 1
+
+	tests := []struct {
+		name        string
+		initMocks   func()
+		args        models.FeeQuoteRequest
+		assertMocks func(*testing.T)
+		assertError func(*testing.T, error)
+		assertFunc  func(*testing.T, models.FeeQuote)
+	}{
+		{
+			name: "Success - first matching rule rounded to minor units",
+			initMocks: func() {
+				repositoryMock.On("GetWallet", 1).Return(wallet, nil).Once()
+			},
+			args: models.FeeQuoteRequest{Operation: models.FeeOperationTransfer, WalletID: 1, Amount: decimal.NewFromFloat(10.55)},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, quote models.FeeQuote) {
+				assert.True(t, decimal.NewFromFloat(0.03).Equal(quote.Fee))
+				assert.Equal(t, "EUR", quote.Currency)
+			},
+		},
+		{
+			name: "Success - no rule for the operation",
+			initMocks: func() {
+				repositoryMock.On("GetWallet", 1).Return(wallet, nil).Once()
+			},
+			args: models.FeeQuoteRequest{Operation: models.FeeOperationCredit, WalletID: 1, Amount: decimal.NewFromInt(10)},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, quote models.FeeQuote) {
+				assert.True(t, quote.Fee.IsZero())
+			},
+		},
+		{
+			name:      "Error - amount not positive",
+			initMocks: func() {},
+			args:      models.FeeQuoteRequest{Operation: models.FeeOperationDebit, WalletID: 1, Amount: decimal.NewFromInt(-1)},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertNotCalled(t, "GetWallet", 1)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.IsType(t, &exceptions.ForbiddenException{}, e)
+			},
+			assertFunc: func(t *testing.T, quote models.FeeQuote) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repositoryMock.ExpectedCalls = nil
+			repositoryMock.Calls = nil
+			tt.initMocks()
+			service := FeeService{
+				transactionRepository: repositoryMock,
+				fees:                  fees,
+			}
+
+			quote, err := service.Quote(tt.args)
+			tt.assertMocks(t)
+			tt.assertError(t, err)
+			tt.assertFunc(t, quote)
+		})
+	}
+}
+
+func TestTransactionService_DebitFee(t *testing.T) {
+
+	repositoryMock := &mocks.RepositoryMock{}
+	fees := feePolicy{
+		rules: []models.FeeRule{{Operation: models.FeeOperationDebit, Type: models.FeeTypeFixed, Amount: decimal.NewFromInt(2)}},
+	}
+	wallet := models.Wallet{Balance: decimal.NewFromInt(50), Currency: "EUR"}
+	wallet.ID = 1
+
+	entry := func(walletId uint, transactionType string, amount int64) interface{} {
+		return mock.MatchedBy(func(transaction *models.Transaction) bool {
+			return transaction.WalletID == walletId && transaction.Type == transactionType &&
+				transaction.Amount.Equal(decimal.NewFromInt(amount))
+		})
+	}
+
+	type args struct {
+		amount decimal.Decimal
+	}
+
+	tests := []struct {
+		name        string
+		initMocks   func()
+		args        args
+		assertMocks func(*testing.T)
+		assertError func(*testing.T, error)
+	}{
+		{
+			name: "Success - fee debited and credited to the fee income account",
+			initMocks: func() {
+				repositoryMock.On("GetWalletForUpdate", 1).Return(wallet, nil).Once()
+				repositoryMock.On("UpdateWallet", mock.MatchedBy(func(updated *models.Wallet) bool {
+					return updated.ID == 1
+				})).Return(nil).Twice()
+				repositoryMock.On("CreateTransaction", entry(1, models.TransactionTypeDebit, 40)).Return(nil).Once()
+				repositoryMock.On("CreateTransaction", entry(1, models.TransactionTypeFee, 2)).Return(nil).Once()
+				expectCounterparty(repositoryMock, models.SystemAccountFunding, models.TransactionTypeDebit, "EUR")
+				expectCounterparty(repositoryMock, models.SystemAccountFeeIncome, models.TransactionTypeFee, "EUR")
+			},
+			args: args{
+				amount: decimal.NewFromInt(40),
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+		},
+		{
+			name: "Error - the currency has no fee income account",
+			initMocks: func() {
+				repositoryMock.On("GetWalletForUpdate", 1).Return(wallet, nil).Once()
+				repositoryMock.On("UpdateWallet", mock.Anything).Return(nil).Twice()
+				repositoryMock.On("CreateTransaction", entry(1, models.TransactionTypeDebit, 40)).Return(nil).Once()
+				repositoryMock.On("CreateTransaction", entry(1, models.TransactionTypeFee, 2)).Return(nil).Once()
+				expectCounterparty(repositoryMock, models.SystemAccountFunding, models.TransactionTypeDebit, "EUR")
+				repositoryMock.On("GetSystemAccount", models.SystemAccountFeeIncome, "EUR").
+					Return(models.Wallet{}, exceptions.NewNotFoundException("there is no %s system account for %s", models.SystemAccountFeeIncome, "EUR")).Once()
+			},
+			args: args{
+				amount: decimal.NewFromInt(40),
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				forbidden := e.(*exceptions.ForbiddenException)
+				assert.Equal(t, ErrorCodeSystemAccountMissing, forbidden.Code)
+			},
+		},
+		{
+			name: "Error - balance covers the amount but not the fee",
+			initMocks: func() {
+				repositoryMock.On("GetWalletForUpdate", 1).Return(wallet, nil).Once()
+			},
+			args: args{
+				amount: decimal.NewFromInt(49),
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertNotCalled(t, "CreateTransaction", mock.Anything)
+			},
+			assertError: func(t *testing.T, e error) {
+				forbidden := e.(*exceptions.ForbiddenException)
+				assert.Equal(t, ErrorCodeInsufficientFunds, forbidden.Code)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repositoryMock.ExpectedCalls = nil
+			repositoryMock.Calls = nil
+			tt.initMocks()
+			service := TransactionService{
+				transactionRepository: repositoryMock,
+				fees:                  fees,
+			}
+
+			err := service.Debit(1, models.WalletRequest{Amount: tt.args.amount})
+			tt.assertMocks(t)
+			tt.assertError(t, err)
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"github.com/spf13/viper"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"github.com/wallet-api/exceptions"
+	"github.com/wallet-api/infrastructure"
+	"time"
+)
+
+type IFXService interface {
+	Quote(request models.FXQuoteRequest) (models.FXQuote, error)
+	Convert(request models.FXConversionRequest) (string, error)
+}
+
+type FXService struct {
+	transactionRepository repositories.ITransactionRepository
+	rateProvider          infrastructure.FXRateProvider
+	concurrency           concurrencyPolicy
+	velocity              velocityPolicy
+	quoteTTL              time.Duration
+}
+
+const (
+	ErrorCodeQuoteExpired    string = "quote_expired"
+	ErrorCodeQuoteRedeemed   string = "quote_redeemed"
+	ErrorCodeUnsupportedPair string = "unsupported_currency_pair"
+	ErrorCodeQuoteTooSmall   string = "quote_amount_too_small"
+)
+
+// Quote fixes the rate for converting the amount and stores it until the quote expires
+func (service *FXService) Quote(request models.FXQuoteRequest) (models.FXQuote, error) {
+	if !request.Amount.IsPositive() {
+		return models.FXQuote{}, exceptions.NewForbiddenException("operation not allowed: %s", ErrorCodeInvalidParamsPositive)
+	}
+	for _, currency := range []string{request.SourceCurrency, request.TargetCurrency} {
+		if _, ok := models.MinorUnits(currency); !ok {
+			return models.FXQuote{}, exceptions.NewForbiddenExceptionWithCode(ErrorCodeUnsupportedCurrency, "operation not allowed: currency %s is not supported", currency)
+		}
+	}
+	if request.SourceCurrency == request.TargetCurrency {
+		return models.FXQuote{}, exceptions.NewForbiddenExceptionWithCode(ErrorCodeCurrencyMismatch, "operation not allowed: source and target currencies must differ")
+	}
+	if err := checkAmount(models.Wallet{Currency: request.SourceCurrency}, request.Amount, ""); err != nil {
+		return models.FXQuote{}, err
+	}
+
+	rate, err := service.rateProvider.GetRate(request.SourceCurrency, request.TargetCurrency)
+	if err == infrastructure.ErrFXPairNotSupported {
+		return models.FXQuote{}, exceptions.NewForbiddenExceptionWithCode(ErrorCodeUnsupportedPair, "operation not allowed: %s cannot be converted to %s", request.SourceCurrency, request.TargetCurrency)
+	}
+	if err != nil {
+		return models.FXQuote{}, err
+	}
+
+	rate = rate.Round(models.FXRateScale)
+
+	// the converted amount is rounded down to the target currency precision, an amount that buys
+	// nothing would debit the source wallet without crediting the target one
+	targetUnits, _ := models.MinorUnits(request.TargetCurrency)
+	targetAmount := request.Amount.Mul(rate).Truncate(targetUnits)
+	if !targetAmount.IsPositive() {
+		return models.FXQuote{}, exceptions.NewForbiddenExceptionWithCode(ErrorCodeQuoteTooSmall, "operation not allowed: %s %s converts to nothing in %s", request.Amount.String(), request.SourceCurrency, request.TargetCurrency)
+	}
+
+	reference, err := newReference()
+	if err != nil {
+		return models.FXQuote{}, err
+	}
+	quote := models.FXQuote{
+		Reference:      reference,
+		SourceCurrency: request.SourceCurrency,
+		TargetCurrency: request.TargetCurrency,
+		Rate:           rate,
+		SourceAmount:   request.Amount,
+		TargetAmount:   targetAmount,
+		ExpiresAt:      time.Now().Add(service.quoteTTL),
+	}
+	err = service.transactionRepository.CreateQuote(&quote)
+
+	return quote, err
+}
+
+// Convert redeems the quote, debiting its source amount from one wallet and crediting its target
+// amount to another in a single database transaction
+func (service *FXService) Convert(request models.FXConversionRequest) (string, error) {
+	if request.SourceWalletID == request.DestinationWalletID {
+		return "", exceptions.NewForbiddenException("operation not allowed: %s", ErrorCodeSelfTransfer)
+	}
+
+	var operationReference string
+	err := service.concurrency.run(service.transactionRepository, func(repository repositories.ITransactionRepository) error {
+		quote, err := repository.GetQuote(request.QuoteID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if quote.RedeemedAt != nil {
+			return exceptions.NewForbiddenExceptionWithCode(ErrorCodeQuoteRedeemed, "operation not allowed: the quote was already redeemed")
+		}
+		if !now.Before(quote.ExpiresAt) {
+			return exceptions.NewForbiddenExceptionWithCode(ErrorCodeQuoteExpired, "operation not allowed: the quote expired")
+		}
+
+		wallets, err := service.concurrency.lockWallets(repository, request.SourceWalletID, request.DestinationWalletID)
+		if err != nil {
+			return err
+		}
+		source, destination := wallets[request.SourceWalletID], wallets[request.DestinationWalletID]
+
+		if err := checkActive(*source); err != nil {
+			return err
+		}
+		if err := checkActive(*destination); err != nil {
+			return err
+		}
+		if err := checkAmount(*source, quote.SourceAmount, quote.SourceCurrency); err != nil {
+			return err
+		}
+		if err := checkAmount(*destination, quote.TargetAmount, quote.TargetCurrency); err != nil {
+			return err
+		}
+		if err := checkDebit(*source, quote.SourceAmount); err != nil {
+			return err
+		}
+		if err := service.velocity.check(repository, *source, quote.SourceAmount); err != nil {
+			return err
+		}
+
+		redeemed, err := repository.RedeemQuote(quote.Reference, now)
+		if err != nil {
+			return err
+		}
+		if !redeemed {
+			return exceptions.NewForbiddenExceptionWithCode(ErrorCodeQuoteRedeemed, "operation not allowed: the quote was already redeemed")
+		}
+
+		debit, err := postEntry(repository, source, models.Transaction{
+			Type:      models.TransactionTypeFX,
+			Direction: models.DirectionDebit,
+			Amount:    quote.SourceAmount,
+		})
+		if err != nil {
+			return err
+		}
+
+		credit, err := postEntry(repository, destination, models.Transaction{
+			Type:               models.TransactionTypeFX,
+			Direction:          models.DirectionCredit,
+			Amount:             quote.TargetAmount,
+			OperationReference: debit.OperationReference,
+		})
+		if err != nil {
+			return err
+		}
+		operationReference = debit.OperationReference
+
+		// the fx position account buys the source currency and sells the target one, keeping
+		// each currency balanced on its own
+		return newJournal(debit.Type, debit.OperationReference).
+			wallet(*source, debit).
+			system(models.AccountFXPosition, debit.Currency, debit.Amount).
+			wallet(*destination, credit).
+			system(models.AccountFXPosition, credit.Currency, credit.Amount.Neg()).
+			post(repository)
+	})
+
+	return operationReference, err
+}
+
+func NewFXService() IFXService {
+	return &FXService{
+		transactionRepository: repositories.NewTransactionRepository(),
+		rateProvider:          infrastructure.NewFXRateProvider(),
+		concurrency:           newConcurrencyPolicy(),
+		velocity:              newVelocityPolicy(),
+		quoteTTL:              viper.GetDuration("fx.quote_ttl"),
+	}
+}
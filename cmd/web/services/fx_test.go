@@ -0,0 +1,209 @@
+package services
+
+import (
+	"errors"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	"github.com/wallet-api/infrastructure"
+	infrastructureMocks "github.com/wallet-api/mocks/infrastructure"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"testing"
+	"time"
+)
+
+func TestFXService_Quote(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		initMocks   func(*mocks.RepositoryMock, *infrastructureMocks.FXRateProviderMock)
+		request     models.FXQuoteRequest
+		assertError func(*testing.T, error)
+		assertFunc  func(*testing.T, models.FXQuote)
+	}{
+		{
+			name: "Success - target amount rounded down to target precision",
+			initMocks: func(repositoryMock *mocks.RepositoryMock, providerMock *infrastructureMocks.FXRateProviderMock) {
+				providerMock.On("GetRate", "EUR", "USD").Return(decimal.RequireFromString("1.0855"), nil).Once()
+				repositoryMock.On("CreateQuote", mock.Anything).Return(nil).Once()
+			},
+			request: models.FXQuoteRequest{SourceCurrency: "EUR", TargetCurrency: "USD", Amount: decimal.NewFromInt(10)},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, quote models.FXQuote) {
+				assert.True(t, quote.TargetAmount.Equal(decimal.RequireFromString("10.85")))
+				assert.True(t, quote.ExpiresAt.After(time.Now()))
+				assert.NotEmpty(t, quote.Reference)
+			},
+		},
+		{
+			name: "Success - rate rounded to its stored scale before converting",
+			initMocks: func(repositoryMock *mocks.RepositoryMock, providerMock *infrastructureMocks.FXRateProviderMock) {
+				providerMock.On("GetRate", "EUR", "USD").Return(decimal.RequireFromString("1.085499996"), nil).Once()
+				repositoryMock.On("CreateQuote", mock.Anything).Return(nil).Once()
+			},
+			request: models.FXQuoteRequest{SourceCurrency: "EUR", TargetCurrency: "USD", Amount: decimal.NewFromInt(1000)},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, quote models.FXQuote) {
+				assert.True(t, quote.Rate.Equal(decimal.RequireFromString("1.0855")))
+				// the unrounded rate would give 1085.49
+				assert.True(t, quote.TargetAmount.Equal(quote.SourceAmount.Mul(quote.Rate).Truncate(2)))
+				assert.True(t, quote.TargetAmount.Equal(decimal.RequireFromString("1085.5")))
+			},
+		},
+		{
+			name: "Error - rate not available",
+			initMocks: func(repositoryMock *mocks.RepositoryMock, providerMock *infrastructureMocks.FXRateProviderMock) {
+				providerMock.On("GetRate", "EUR", "USD").Return(decimal.Decimal{}, errors.New("no rate")).Once()
+			},
+			request: models.FXQuoteRequest{SourceCurrency: "EUR", TargetCurrency: "USD", Amount: decimal.NewFromInt(10)},
+			assertError: func(t *testing.T, e error) {
+				assert.NotNil(t, e)
+			},
+			assertFunc: func(t *testing.T, quote models.FXQuote) {},
+		},
+		{
+			name: "Error - the provider has no rate for the pair",
+			initMocks: func(repositoryMock *mocks.RepositoryMock, providerMock *infrastructureMocks.FXRateProviderMock) {
+				providerMock.On("GetRate", "EUR", "USD").Return(decimal.Decimal{}, infrastructure.ErrFXPairNotSupported).Once()
+			},
+			request: models.FXQuoteRequest{SourceCurrency: "EUR", TargetCurrency: "USD", Amount: decimal.NewFromInt(10)},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeUnsupportedPair, e.(*exceptions.ForbiddenException).Code)
+			},
+			assertFunc: func(t *testing.T, quote models.FXQuote) {},
+		},
+		{
+			name: "Error - the target amount rounds down to zero",
+			initMocks: func(repositoryMock *mocks.RepositoryMock, providerMock *infrastructureMocks.FXRateProviderMock) {
+				providerMock.On("GetRate", "EUR", "JPY").Return(decimal.RequireFromString("0.5"), nil).Once()
+			},
+			request: models.FXQuoteRequest{SourceCurrency: "EUR", TargetCurrency: "JPY", Amount: decimal.RequireFromString("1.5")},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeQuoteTooSmall, e.(*exceptions.ForbiddenException).Code)
+			},
+			assertFunc: func(t *testing.T, quote models.FXQuote) {},
+		},
+		{
+			name:      "Error - same currency",
+			initMocks: func(repositoryMock *mocks.RepositoryMock, providerMock *infrastructureMocks.FXRateProviderMock) {},
+			request:   models.FXQuoteRequest{SourceCurrency: "EUR", TargetCurrency: "EUR", Amount: decimal.NewFromInt(10)},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeCurrencyMismatch, e.(*exceptions.ForbiddenException).Code)
+			},
+			assertFunc: func(t *testing.T, quote models.FXQuote) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repositoryMock := &mocks.RepositoryMock{}
+			providerMock := &infrastructureMocks.FXRateProviderMock{}
+			tt.initMocks(repositoryMock, providerMock)
+			service := FXService{
+				transactionRepository: repositoryMock,
+				rateProvider:          providerMock,
+				quoteTTL:              time.Minute,
+			}
+
+			quote, err := service.Quote(tt.request)
+			repositoryMock.AssertExpectations(t)
+			providerMock.AssertExpectations(t)
+			tt.assertError(t, err)
+			tt.assertFunc(t, quote)
+		})
+	}
+}
+
+func TestFXService_Convert(t *testing.T) {
+
+	quote := models.FXQuote{
+		Reference:      "quote-1",
+		SourceCurrency: "EUR",
+		TargetCurrency: "USD",
+		SourceAmount:   decimal.NewFromInt(10),
+		TargetAmount:   decimal.RequireFromString("10.85"),
+		ExpiresAt:      time.Now().Add(time.Minute),
+	}
+	eurWallet := models.Wallet{Model: gorm.Model{ID: 1}, Balance: decimal.NewFromInt(100), Currency: "EUR"}
+	usdWallet := models.Wallet{Model: gorm.Model{ID: 2}, Balance: decimal.Zero, Currency: "USD"}
+
+	tests := []struct {
+		name        string
+		initMocks   func(*mocks.RepositoryMock)
+		assertError func(*testing.T, error)
+	}{
+		{
+			name: "Success - quote redeemed and both legs posted",
+			initMocks: func(repositoryMock *mocks.RepositoryMock) {
+				repositoryMock.On("GetQuote", "quote-1").Return(quote, nil).Once()
+				repositoryMock.On("GetWalletForUpdate", 1).Return(eurWallet, nil).Once()
+				repositoryMock.On("GetWalletForUpdate", 2).Return(usdWallet, nil).Once()
+				repositoryMock.On("RedeemQuote", "quote-1", mock.Anything).Return(true, nil).Once()
+				repositoryMock.On("UpdateWallet", mock.Anything).Return(nil).Twice()
+				repositoryMock.On("CreateTransaction", mock.MatchedBy(func(transaction *models.Transaction) bool {
+					return transaction.WalletID == 1 && transaction.Amount.Equal(decimal.NewFromInt(10))
+				})).Return(nil).Once()
+				repositoryMock.On("CreateTransaction", mock.MatchedBy(func(transaction *models.Transaction) bool {
+					return transaction.WalletID == 2 && transaction.Amount.Equal(decimal.RequireFromString("10.85"))
+				})).Return(nil).Once()
+				repositoryMock.On("CreateJournalEntry", balancedEntry(models.TransactionTypeFX, models.AccountWallets, models.AccountFXPosition, models.AccountWallets, models.AccountFXPosition)).Return(nil).Once()
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+		},
+		{
+			name: "Error - quote redeemed concurrently",
+			initMocks: func(repositoryMock *mocks.RepositoryMock) {
+				repositoryMock.On("GetQuote", "quote-1").Return(quote, nil).Once()
+				repositoryMock.On("GetWalletForUpdate", 1).Return(eurWallet, nil).Once()
+				repositoryMock.On("GetWalletForUpdate", 2).Return(usdWallet, nil).Once()
+				repositoryMock.On("RedeemQuote", "quote-1", mock.Anything).Return(false, nil).Once()
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeQuoteRedeemed, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
+		{
+			name: "Error - quote expired",
+			initMocks: func(repositoryMock *mocks.RepositoryMock) {
+				expired := quote
+				expired.ExpiresAt = time.Now().Add(-time.Second)
+				repositoryMock.On("GetQuote", "quote-1").Return(expired, nil).Once()
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeQuoteExpired, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
+		{
+			name: "Error - destination wallet in another currency",
+			initMocks: func(repositoryMock *mocks.RepositoryMock) {
+				repositoryMock.On("GetQuote", "quote-1").Return(quote, nil).Once()
+				repositoryMock.On("GetWalletForUpdate", 1).Return(eurWallet, nil).Once()
+				repositoryMock.On("GetWalletForUpdate", 2).Return(models.Wallet{Model: gorm.Model{ID: 2}, Currency: "ARS"}, nil).Once()
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeCurrencyMismatch, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repositoryMock := &mocks.RepositoryMock{}
+			tt.initMocks(repositoryMock)
+			service := FXService{
+				transactionRepository: repositoryMock,
+			}
+
+			_, err := service.Convert(models.FXConversionRequest{QuoteID: "quote-1", SourceWalletID: 1, DestinationWalletID: 2})
+			repositoryMock.AssertExpectations(t)
+			tt.assertError(t, err)
+		})
+	}
+}
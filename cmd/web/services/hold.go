@@ -0,0 +1,246 @@
+package services
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"github.com/wallet-api/exceptions"
+	"strconv"
+	"time"
+)
+
+type IHoldService interface {
+	Place(walletId int, request models.HoldRequest) (models.Hold, error)
+	Get(walletId int, holdId int) (models.Hold, error)
+	Capture(walletId int, holdId int, request models.CaptureRequest) (models.Hold, error)
+	Void(walletId int, holdId int) (models.Hold, error)
+	ExpireHolds() (int, error)
+}
+
+type HoldService struct {
+	transactionRepository repositories.ITransactionRepository
+	concurrency           concurrencyPolicy
+	velocity              velocityPolicy
+	defaultTTL            time.Duration
+	// maxTTL bounds the expires_in of the requests
+	maxTTL time.Duration
+}
+
+const (
+	ErrorCodeHoldNotActive     string = "hold_not_active"
+	ErrorCodeHoldExpired       string = "hold_expired"
+	ErrorCodeCaptureExceedHold string = "capture_exceeds_hold"
+	ErrorCodeInvalidHoldExpiry string = "invalid_hold_expiry"
+)
+
+// defaultHoldMaxTTL is used when holds.max_ttl is not configured
+const defaultHoldMaxTTL time.Duration = 30 * 24 * time.Hour
+
+const holdNotFound string = "hold with id=%s not found"
+const expiredHoldsBatch int = 100
+
+// Place reserves the amount on the wallet, reducing its available balance
+func (service *HoldService) Place(walletId int, request models.HoldRequest) (models.Hold, error) {
+	if !request.Amount.IsPositive() {
+		return models.Hold{}, exceptions.NewForbiddenException("operation not allowed: %s", ErrorCodeInvalidParamsPositive)
+	}
+	maxTTL := service.maxTTL
+	if maxTTL <= 0 {
+		maxTTL = defaultHoldMaxTTL
+	}
+	// checked in seconds before converting, a large expires_in would overflow the duration
+	if request.ExpiresIn < 0 || int64(request.ExpiresIn) > int64(maxTTL/time.Second) {
+		return models.Hold{}, exceptions.NewForbiddenExceptionWithCode(ErrorCodeInvalidHoldExpiry, "operation not allowed: expires_in must be between 0 and %s seconds", strconv.FormatInt(int64(maxTTL/time.Second), 10))
+	}
+	ttl := service.defaultTTL
+	if request.ExpiresIn > 0 {
+		ttl = time.Duration(request.ExpiresIn) * time.Second
+	}
+
+	var hold models.Hold
+	err := service.concurrency.run(service.transactionRepository, func(repository repositories.ITransactionRepository) error {
+		wallet, err := service.concurrency.loadWallet(repository, walletId)
+		if err != nil {
+			return err
+		}
+		if err := checkActive(wallet); err != nil {
+			return err
+		}
+		if err := checkAmount(wallet, request.Amount, request.Currency); err != nil {
+			return err
+		}
+		if err := checkDebit(wallet, request.Amount); err != nil {
+			return err
+		}
+
+		wallet.HeldBalance = wallet.HeldBalance.Add(request.Amount)
+		if err := repository.UpdateWallet(&wallet); err != nil {
+			return err
+		}
+
+		hold = models.Hold{
+			WalletID:       wallet.ID,
+			Amount:         request.Amount,
+			CapturedAmount: decimal.Zero,
+			Currency:       wallet.Currency,
+			Status:         models.HoldStatusActive,
+			ExpiresAt:      time.Now().Add(ttl),
+		}
+		return repository.CreateHold(&hold)
+	})
+
+	return hold, err
+}
+
+func (service *HoldService) Get(walletId int, holdId int) (models.Hold, error) {
+	hold, err := service.transactionRepository.GetHold(holdId)
+	if err != nil {
+		return hold, err
+	}
+	if int(hold.WalletID) != walletId {
+		return models.Hold{}, exceptions.NewNotFoundException(holdNotFound, strconv.Itoa(holdId))
+	}
+	return hold, nil
+}
+
+// Capture turns the hold, fully or partially, into a debit and releases whatever was not captured
+func (service *HoldService) Capture(walletId int, holdId int, request models.CaptureRequest) (models.Hold, error) {
+	var hold models.Hold
+	err := service.concurrency.run(service.transactionRepository, func(repository repositories.ITransactionRepository) error {
+		var wallet models.Wallet
+		var err error
+		wallet, hold, err = service.lockHold(repository, walletId, holdId)
+		if err != nil {
+			return err
+		}
+		if err := checkActive(wallet); err != nil {
+			return err
+		}
+		if !time.Now().Before(hold.ExpiresAt) {
+			return exceptions.NewForbiddenExceptionWithCode(ErrorCodeHoldExpired, "operation not allowed: the hold expired")
+		}
+
+		amount := hold.Amount
+		if request.Amount != nil {
+			amount = *request.Amount
+		}
+		if !amount.IsPositive() {
+			return exceptions.NewForbiddenException("operation not allowed: %s", ErrorCodeInvalidParamsPositive)
+		}
+		if err := checkAmount(wallet, amount, ""); err != nil {
+			return err
+		}
+		if amount.GreaterThan(hold.Amount) {
+			return exceptions.NewForbiddenExceptionWithCode(ErrorCodeCaptureExceedHold, "operation not allowed: the hold only reserves %s", hold.Amount.String())
+		}
+		// the capture is the debit, placing the hold only reserved the funds
+		if err := service.velocity.check(repository, wallet, amount); err != nil {
+			return err
+		}
+
+		wallet.HeldBalance = wallet.HeldBalance.Sub(hold.Amount)
+		capture, err := postEntry(repository, &wallet, models.Transaction{
+			Type:      models.TransactionTypeCapture,
+			Direction: models.DirectionDebit,
+			Amount:    amount,
+		})
+		if err != nil {
+			return err
+		}
+		if err := postCounterparty(repository, models.SystemAccountFunding, wallet, capture); err != nil {
+			return err
+		}
+
+		hold.Status = models.HoldStatusCaptured
+		hold.CapturedAmount = amount
+		return repository.UpdateHold(&hold)
+	})
+
+	return hold, err
+}
+
+// Void releases the funds reserved by the hold
+func (service *HoldService) Void(walletId int, holdId int) (models.Hold, error) {
+	return service.release(walletId, holdId, models.HoldStatusVoided)
+}
+
+// ExpireHolds releases every active hold past its expiration, returning how many were expired
+func (service *HoldService) ExpireHolds() (int, error) {
+	expired := 0
+	for {
+		holds, err := service.transactionRepository.GetExpiredHolds(time.Now(), expiredHoldsBatch)
+		if err != nil {
+			return expired, err
+		}
+
+		released := 0
+		for _, hold := range holds {
+			if _, err := service.release(int(hold.WalletID), int(hold.ID), models.HoldStatusExpired); err != nil {
+				logrus.Errorf("couldn't expire hold %d: %v", hold.ID, err)
+				continue
+			}
+			released++
+		}
+		expired += released
+
+		// stop when there is nothing left or the batch keeps failing, the next run will retry it
+		if len(holds) < expiredHoldsBatch || released == 0 {
+			return expired, nil
+		}
+	}
+}
+
+func (service *HoldService) release(walletId int, holdId int, status string) (models.Hold, error) {
+	var hold models.Hold
+	err := service.concurrency.run(service.transactionRepository, func(repository repositories.ITransactionRepository) error {
+		var wallet models.Wallet
+		var err error
+		wallet, hold, err = service.lockHold(repository, walletId, holdId)
+		if err != nil {
+			return err
+		}
+
+		wallet.HeldBalance = wallet.HeldBalance.Sub(hold.Amount)
+		if err := repository.UpdateWallet(&wallet); err != nil {
+			return err
+		}
+
+		hold.Status = status
+		return repository.UpdateHold(&hold)
+	})
+
+	return hold, err
+}
+
+// lockHold locks the wallet and then the hold, always in that order, and verifies the hold belongs
+// to the wallet and is still active
+func (service *HoldService) lockHold(repository repositories.ITransactionRepository, walletId int, holdId int) (models.Wallet, models.Hold, error) {
+	wallet, err := service.concurrency.loadWallet(repository, walletId)
+	if err != nil {
+		return wallet, models.Hold{}, err
+	}
+
+	hold, err := repository.GetHoldForUpdate(holdId)
+	if err != nil {
+		return wallet, hold, err
+	}
+	if hold.WalletID != wallet.ID {
+		return wallet, hold, exceptions.NewNotFoundException(holdNotFound, strconv.Itoa(holdId))
+	}
+	if hold.Status != models.HoldStatusActive {
+		return wallet, hold, exceptions.NewForbiddenExceptionWithCode(ErrorCodeHoldNotActive, "operation not allowed: the hold is %s", hold.Status)
+	}
+	return wallet, hold, nil
+}
+
+func NewHoldService() IHoldService {
+	return &HoldService{
+		transactionRepository: repositories.NewTransactionRepository(),
+		concurrency:           newConcurrencyPolicy(),
+		velocity:              newVelocityPolicy(),
+		defaultTTL:            viper.GetDuration("holds.default_ttl"),
+		maxTTL:                viper.GetDuration("holds.max_ttl"),
+	}
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"testing"
+	"time"
+)
+
+func TestHoldService_Place(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		wallet      models.Wallet
+		amount      decimal.Decimal
+		expiresIn   int
+		placed      bool
+		assertError func(*testing.T, error)
+	}{
+		{
+			name:   "Success - funds reserved",
+			wallet: models.Wallet{Model: gorm.Model{ID: 1}, Balance: decimal.NewFromInt(100), HeldBalance: decimal.NewFromInt(20)},
+			amount: decimal.NewFromInt(50),
+			placed: true,
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+		},
+		{
+			name:      "Success - expires_in at the max ttl",
+			wallet:    models.Wallet{Model: gorm.Model{ID: 1}, Balance: decimal.NewFromInt(100)},
+			amount:    decimal.NewFromInt(50),
+			expiresIn: int(defaultHoldMaxTTL / time.Second),
+			placed:    true,
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+		},
+		{
+			name:      "Error - expires_in overflowing the duration",
+			wallet:    models.Wallet{Model: gorm.Model{ID: 1}, Balance: decimal.NewFromInt(100)},
+			amount:    decimal.NewFromInt(50),
+			expiresIn: int(^uint(0) >> 1),
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeInvalidHoldExpiry, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
+		{
+			name:      "Error - negative expires_in",
+			wallet:    models.Wallet{Model: gorm.Model{ID: 1}, Balance: decimal.NewFromInt(100)},
+			amount:    decimal.NewFromInt(50),
+			expiresIn: -1,
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeInvalidHoldExpiry, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
+		{
+			name:   "Error - available balance already held",
+			wallet: models.Wallet{Model: gorm.Model{ID: 1}, Balance: decimal.NewFromInt(100), HeldBalance: decimal.NewFromInt(80)},
+			amount: decimal.NewFromInt(50),
+			assertError: func(t *testing.T, e error) {
+				assert.NotNil(t, e)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repositoryMock := &mocks.RepositoryMock{}
+			if tt.placed || tt.expiresIn == 0 {
+				repositoryMock.On("GetWalletForUpdate", 1).Return(tt.wallet, nil).Once()
+			}
+			if tt.placed {
+				repositoryMock.On("UpdateWallet", mock.MatchedBy(func(wallet *models.Wallet) bool {
+					return wallet.HeldBalance.Equal(tt.wallet.HeldBalance.Add(tt.amount))
+				})).Return(nil).Once()
+				repositoryMock.On("CreateHold", mock.MatchedBy(func(hold *models.Hold) bool {
+					return hold.Status == models.HoldStatusActive && hold.ExpiresAt.After(time.Now())
+				})).Return(nil).Once()
+			}
+			service := HoldService{
+				transactionRepository: repositoryMock,
+				defaultTTL:            time.Hour,
+			}
+
+			_, err := service.Place(1, models.HoldRequest{Amount: tt.amount, ExpiresIn: tt.expiresIn})
+			repositoryMock.AssertExpectations(t)
+			tt.assertError(t, err)
+		})
+	}
+}
+
+func TestHoldService_Capture(t *testing.T) {
+
+	wallet := models.Wallet{Model: gorm.Model{ID: 1}, Balance: decimal.NewFromInt(100), HeldBalance: decimal.NewFromInt(50)}
+	hold := models.Hold{ID: 7, WalletID: 1, Amount: decimal.NewFromInt(50), Status: models.HoldStatusActive, ExpiresAt: time.Now().Add(time.Hour)}
+	partial := decimal.NewFromInt(30)
+	exceeding := decimal.NewFromInt(60)
+
+	tests := []struct {
+		name        string
+		hold        models.Hold
+		request     models.CaptureRequest
+		captured    bool
+		assertError func(*testing.T, error)
+	}{
+		{
+			name:     "Success - partial capture releases the remainder",
+			hold:     hold,
+			request:  models.CaptureRequest{Amount: &partial},
+			captured: true,
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+		},
+		{
+			name:    "Error - capture above the held amount",
+			hold:    hold,
+			request: models.CaptureRequest{Amount: &exceeding},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeCaptureExceedHold, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
+		{
+			name: "Error - hold already voided",
+			hold: models.Hold{ID: 7, WalletID: 1, Amount: decimal.NewFromInt(50), Status: models.HoldStatusVoided},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeHoldNotActive, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repositoryMock := &mocks.RepositoryMock{}
+			repositoryMock.On("GetWalletForUpdate", 1).Return(wallet, nil).Once()
+			repositoryMock.On("GetHoldForUpdate", 7).Return(tt.hold, nil).Once()
+			if tt.captured {
+				repositoryMock.On("UpdateWallet", mock.MatchedBy(func(wallet *models.Wallet) bool {
+					return wallet.HeldBalance.IsZero() && wallet.Balance.Equal(decimal.NewFromInt(70))
+				})).Return(nil).Once()
+				repositoryMock.On("CreateTransaction", mock.MatchedBy(func(transaction *models.Transaction) bool {
+					return transaction.Type == models.TransactionTypeCapture && transaction.Amount.Equal(partial)
+				})).Return(nil).Once()
+				expectCounterparty(repositoryMock, models.SystemAccountFunding, models.TransactionTypeCapture, "")
+				repositoryMock.On("UpdateHold", mock.MatchedBy(func(hold *models.Hold) bool {
+					return hold.Status == models.HoldStatusCaptured && hold.CapturedAmount.Equal(partial)
+				})).Return(nil).Once()
+			}
+			service := HoldService{
+				transactionRepository: repositoryMock,
+			}
+
+			_, err := service.Capture(1, 7, tt.request)
+			repositoryMock.AssertExpectations(t)
+			tt.assertError(t, err)
+		})
+	}
+}
+
+func TestHoldService_ExpireHolds(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	hold := models.Hold{ID: 7, WalletID: 1, Amount: decimal.NewFromInt(50), Status: models.HoldStatusActive}
+
+	repositoryMock.On("GetExpiredHolds", mock.Anything, expiredHoldsBatch).Return([]models.Hold{hold}, nil).Once()
+	repositoryMock.On("GetWalletForUpdate", 1).
+		Return(models.Wallet{Model: gorm.Model{ID: 1}, Balance: decimal.NewFromInt(100), HeldBalance: decimal.NewFromInt(50)}, nil).Once()
+	repositoryMock.On("GetHoldForUpdate", 7).Return(hold, nil).Once()
+	repositoryMock.On("UpdateWallet", mock.MatchedBy(func(wallet *models.Wallet) bool {
+		return wallet.HeldBalance.IsZero() && wallet.Balance.Equal(decimal.NewFromInt(100))
+	})).Return(nil).Once()
+	repositoryMock.On("UpdateHold", mock.MatchedBy(func(hold *models.Hold) bool {
+		return hold.Status == models.HoldStatusExpired
+	})).Return(nil).Once()
+
+	service := HoldService{
+		transactionRepository: repositoryMock,
+	}
+
+	expired, err := service.ExpireHolds()
+	repositoryMock.AssertExpectations(t)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, expired)
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"time"
+)
+
+type IInterestService interface {
+	Run(now time.Time) (models.InterestRun, error)
+}
+
+type InterestService struct {
+	transactionRepository repositories.ITransactionRepository
+	concurrency           concurrencyPolicy
+	annualRate            decimal.Decimal
+	dayCount              string
+}
+
+const interestWalletsBatch int = 100
+
+// interestPrecision is the number of decimal places accruals are kept with until they are paid out
+const interestPrecision int32 = 18
+
+// Run accrues the interest of every savings wallet for each day completed before now and pays out the
+// accruals of the months already finished. Days and months already processed are skipped, so running
+// it again after a crash neither accrues nor pays twice
+func (service *InterestService) Run(now time.Time) (models.InterestRun, error) {
+	today := startOfDay(now)
+	monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+	run := models.InterestRun{Through: today.AddDate(0, 0, -1)}
+
+	var afterId uint
+	for {
+		ids, err := service.transactionRepository.GetWalletIDsByProduct(models.WalletProductSavings, afterId, interestWalletsBatch)
+		if err != nil {
+			return run, err
+		}
+
+		for _, id := range ids {
+			accrued, err := service.accrue(int(id), run.Through)
+			run.Accruals += accrued
+			if err != nil {
+				logrus.Errorf("couldn't accrue interest of wallet %d: %v", id, err)
+				run.FailedWallets = append(run.FailedWallets, id)
+				continue
+			}
+
+			paid, err := service.payout(int(id), monthStart)
+			if err != nil {
+				logrus.Errorf("couldn't pay out interest of wallet %d: %v", id, err)
+				run.FailedWallets = append(run.FailedWallets, id)
+				continue
+			}
+			if paid {
+				run.Payouts++
+			}
+		}
+
+		if len(ids) < interestWalletsBatch {
+			return run, nil
+		}
+		afterId = ids[len(ids)-1]
+	}
+}
+
+// accrue stores the accruals of the days after the last accrued one, starting on the day the wallet
+// was created, up to the given day included
+func (service *InterestService) accrue(walletId int, through time.Time) (int, error) {
+	wallet, err := service.transactionRepository.GetWalletFromDatabase(walletId)
+	if err != nil {
+		return 0, err
+	}
+	if wallet.Status == models.WalletStatusClosed {
+		return 0, nil
+	}
+
+	day := startOfDay(wallet.CreatedAt)
+	last, err := service.transactionRepository.GetLastInterestAccrual(walletId)
+	if err != nil {
+		return 0, err
+	}
+	if last != nil {
+		day = startOfDay(last.Day).AddDate(0, 0, 1)
+	}
+
+	accrued := 0
+	for ; !day.After(through); day = day.AddDate(0, 0, 1) {
+		balance, err := balanceAt(service.transactionRepository, walletId, day.AddDate(0, 0, 1).Add(-time.Nanosecond))
+		if err != nil {
+			return accrued, err
+		}
+
+		created, err := service.transactionRepository.CreateInterestAccrual(&models.InterestAccrual{
+			WalletID:   wallet.ID,
+			Day:        day,
+			Balance:    balance,
+			AnnualRate: service.annualRate,
+			DayCount:   service.dayCount,
+			Amount:     service.interest(balance, day),
+		})
+		if err != nil {
+			return accrued, err
+		}
+		if created {
+			accrued++
+		}
+	}
+	return accrued, nil
+}
+
+// interest is what the balance earns in one day, negative balances earn nothing
+func (service *InterestService) interest(balance decimal.Decimal, day time.Time) decimal.Decimal {
+	if !balance.IsPositive() {
+		return decimal.Zero
+	}
+	days := decimal.NewFromInt(daysInYear(service.dayCount, day.Year()))
+	return balance.Mul(service.annualRate).DivRound(days, interestPrecision)
+}
+
+// payout credits the wallet with its accruals from before monthStart, truncated to the currency minor
+// units, and marks them paid in the same database transaction. What the truncation leaves is carried
+// to the next payout in a residual accrual. Accruals that add up to less than a minor unit, or belong
+// to a wallet that isn't active, wait for a later payout
+func (service *InterestService) payout(walletId int, monthStart time.Time) (bool, error) {
+	paid := false
+	err := service.concurrency.run(service.transactionRepository, func(repository repositories.ITransactionRepository) error {
+		paid = false
+		wallet, err := service.concurrency.loadWallet(repository, walletId)
+		if err != nil {
+			return err
+		}
+		if wallet.Status != models.WalletStatusActive {
+			return nil
+		}
+
+		accruals, err := repository.GetUnpaidInterestAccruals(walletId, monthStart)
+		if err != nil {
+			return err
+		}
+		accrued := decimal.Zero
+		ids := make([]uint, 0, len(accruals))
+		for _, accrual := range accruals {
+			accrued = accrued.Add(accrual.Amount)
+			ids = append(ids, accrual.ID)
+		}
+		total := accrued
+		if units, ok := models.MinorUnits(wallet.Currency); ok {
+			total = total.Truncate(units)
+		}
+		if !total.IsPositive() {
+			return nil
+		}
+
+		entry, err := postEntry(repository, &wallet, models.Transaction{
+			Type:        models.TransactionTypeInterest,
+			Direction:   models.DirectionCredit,
+			Amount:      total,
+			Description: fmt.Sprintf("interest until %s", monthStart.AddDate(0, 0, -1).Format("2006-01-02")),
+		})
+		if err != nil {
+			return err
+		}
+		err = newJournal(entry.Type, entry.OperationReference).
+			wallet(wallet, entry).
+			system(models.AccountInterestExpense, entry.Currency, total.Neg()).
+			post(repository)
+		if err != nil {
+			return err
+		}
+		paid = true
+		if err := repository.MarkInterestAccrualsPaid(ids, entry.ID); err != nil {
+			return err
+		}
+
+		remainder := accrued.Sub(total)
+		if !remainder.IsPositive() {
+			return nil
+		}
+		_, err = repository.CreateInterestAccrual(&models.InterestAccrual{
+			WalletID:   wallet.ID,
+			Day:        monthStart,
+			Residual:   true,
+			AnnualRate: service.annualRate,
+			DayCount:   service.dayCount,
+			Amount:     remainder,
+		})
+		return err
+	})
+	return paid, err
+}
+
+func daysInYear(dayCount string, year int) int64 {
+	switch dayCount {
+	case models.DayCountActual360:
+		return 360
+	case models.DayCountActualActual:
+		return int64(time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay())
+	default:
+		return 365
+	}
+}
+
+// startOfDay truncates the time to the start of its UTC day
+func startOfDay(at time.Time) time.Time {
+	at = at.UTC()
+	return time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func NewInterestService() IInterestService {
+	annualRate, err := decimal.NewFromString(viper.GetString("interest.annual_rate"))
+	if err != nil {
+		logrus.Errorf("couldn't read the interest rate: %v", err)
+	}
+	dayCount := viper.GetString("interest.day_count")
+	switch dayCount {
+	case models.DayCountActual365, models.DayCountActual360, models.DayCountActualActual:
+	default:
+		logrus.Errorf("unknown interest day count %q, using %s", dayCount, models.DayCountActual365)
+		dayCount = models.DayCountActual365
+	}
+
+	return &InterestService{
+		transactionRepository: repositories.NewTransactionRepository(),
+		concurrency:           newConcurrencyPolicy(),
+		annualRate:            annualRate,
+		dayCount:              dayCount,
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wallet-api/cmd/web/models"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"testing"
+	"time"
+)
+
+func TestInterestService_Run(t *testing.T) {
+
+	repositoryMock := &mocks.RepositoryMock{}
+	now := time.Date(2026, 11, 1, 10, 0, 0, 0, time.UTC)
+	monthStart := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	wallet := models.Wallet{Balance: decimal.NewFromInt(1000), Currency: "EUR", Status: models.WalletStatusActive}
+	wallet.ID = 1
+	wallet.CreatedAt = time.Date(2026, 10, 30, 15, 0, 0, 0, time.UTC)
+	frozen := wallet
+	frozen.Status = models.WalletStatusFrozen
+
+	accrual := func(day int) interface{} {
+		return mock.MatchedBy(func(accrual *models.InterestAccrual) bool {
+			return accrual.WalletID == 1 && accrual.Day.Equal(time.Date(2026, 10, day, 0, 0, 0, 0, time.UTC)) &&
+				accrual.Amount.Equal(decimal.NewFromFloat(0.1))
+		})
+	}
+	unpaid := []models.InterestAccrual{
+		{ID: 7, Amount: decimal.RequireFromString("0.104")},
+		{ID: 8, Amount: decimal.RequireFromString("0.1")},
+	}
+
+	tests := []struct {
+		name        string
+		initMocks   func()
+		assertMocks func(*testing.T)
+		assertError func(*testing.T, error)
+		assertFunc  func(*testing.T, models.InterestRun)
+	}{
+		{
+			name: "Success - pending days accrued and the finished month paid out truncated, the remainder carried",
+			initMocks: func() {
+				repositoryMock.On("GetWalletIDsByProduct", models.WalletProductSavings, uint(0), interestWalletsBatch).
+					Return([]uint{1}, nil).Once()
+				repositoryMock.On("GetWalletFromDatabase", 1).Return(wallet, nil).Once()
+				repositoryMock.On("GetLastInterestAccrual", 1).Return(nil, nil).Once()
+				repositoryMock.On("GetLatestSnapshot", 1, mock.Anything).Return(nil, nil).Twice()
+				repositoryMock.On("SumLedger", 1, uint(0), mock.Anything).
+					Return(models.LedgerSum{Total: decimal.NewFromInt(1000)}, nil).Twice()
+				repositoryMock.On("CreateInterestAccrual", accrual(30)).Return(true, nil).Once()
+				repositoryMock.On("CreateInterestAccrual", accrual(31)).Return(true, nil).Once()
+				repositoryMock.On("GetWalletForUpdate", 1).Return(wallet, nil).Once()
+				repositoryMock.On("GetUnpaidInterestAccruals", 1, monthStart).Return(unpaid, nil).Once()
+				repositoryMock.On("UpdateWallet", mock.MatchedBy(func(updated *models.Wallet) bool {
+					return updated.Balance.Equal(decimal.RequireFromString("1000.2"))
+				})).Return(nil).Once()
+				repositoryMock.On("CreateTransaction", mock.MatchedBy(func(transaction *models.Transaction) bool {
+					return transaction.Type == models.TransactionTypeInterest && transaction.Direction == models.DirectionCredit &&
+						transaction.Amount.Equal(decimal.RequireFromString("0.2"))
+				})).Return(nil).Once()
+				repositoryMock.On("CreateJournalEntry", balancedEntry(models.TransactionTypeInterest, models.AccountWallets, models.AccountInterestExpense)).Return(nil).Once()
+				repositoryMock.On("MarkInterestAccrualsPaid", []uint{7, 8}, uint(0)).Return(nil).Once()
+				// the 0.004 below the cent is paid with the next month
+				repositoryMock.On("CreateInterestAccrual", mock.MatchedBy(func(residual *models.InterestAccrual) bool {
+					return residual.Residual && residual.Day.Equal(monthStart) && residual.Amount.Equal(decimal.RequireFromString("0.004"))
+				})).Return(true, nil).Once()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, run models.InterestRun) {
+				assert.Equal(t, 2, run.Accruals)
+				assert.Equal(t, 1, run.Payouts)
+				assert.Empty(t, run.FailedWallets)
+			},
+		},
+		{
+			name: "Success - run again after everything was processed",
+			initMocks: func() {
+				repositoryMock.On("GetWalletIDsByProduct", models.WalletProductSavings, uint(0), interestWalletsBatch).
+					Return([]uint{1}, nil).Once()
+				repositoryMock.On("GetWalletFromDatabase", 1).Return(wallet, nil).Once()
+				repositoryMock.On("GetLastInterestAccrual", 1).
+					Return(&models.InterestAccrual{Day: time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC)}, nil).Once()
+				repositoryMock.On("GetWalletForUpdate", 1).Return(wallet, nil).Once()
+				repositoryMock.On("GetUnpaidInterestAccruals", 1, monthStart).Return([]models.InterestAccrual{}, nil).Once()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+				repositoryMock.AssertNotCalled(t, "CreateInterestAccrual", mock.Anything)
+				repositoryMock.AssertNotCalled(t, "CreateTransaction", mock.Anything)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, run models.InterestRun) {
+				assert.Equal(t, 0, run.Accruals)
+				assert.Equal(t, 0, run.Payouts)
+			},
+		},
+		{
+			name: "Success - frozen wallets accrue but wait for the payout",
+			initMocks: func() {
+				repositoryMock.On("GetWalletIDsByProduct", models.WalletProductSavings, uint(0), interestWalletsBatch).
+					Return([]uint{1}, nil).Once()
+				repositoryMock.On("GetWalletFromDatabase", 1).Return(frozen, nil).Once()
+				repositoryMock.On("GetLastInterestAccrual", 1).
+					Return(&models.InterestAccrual{Day: time.Date(2026, 10, 30, 0, 0, 0, 0, time.UTC)}, nil).Once()
+				repositoryMock.On("GetLatestSnapshot", 1, mock.Anything).Return(nil, nil).Once()
+				repositoryMock.On("SumLedger", 1, uint(0), mock.Anything).
+					Return(models.LedgerSum{Total: decimal.NewFromInt(1000)}, nil).Once()
+				repositoryMock.On("CreateInterestAccrual", accrual(31)).Return(true, nil).Once()
+				repositoryMock.On("GetWalletForUpdate", 1).Return(frozen, nil).Once()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+				repositoryMock.AssertNotCalled(t, "GetUnpaidInterestAccruals", 1, monthStart)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, run models.InterestRun) {
+				assert.Equal(t, 1, run.Accruals)
+				assert.Equal(t, 0, run.Payouts)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repositoryMock.ExpectedCalls = nil
+			repositoryMock.Calls = nil
+			tt.initMocks()
+			service := InterestService{
+				transactionRepository: repositoryMock,
+				annualRate:            decimal.RequireFromString("0.0365"),
+				dayCount:              models.DayCountActual365,
+			}
+
+			run, err := service.Run(now)
+			tt.assertMocks(t)
+			tt.assertError(t, err)
+			tt.assertFunc(t, run)
+		})
+	}
+}
+
+func TestDaysInYear(t *testing.T) {
+	assert.Equal(t, int64(365), daysInYear(models.DayCountActual365, 2028))
+	assert.Equal(t, int64(360), daysInYear(models.DayCountActual360, 2027))
+	assert.Equal(t, int64(366), daysInYear(models.DayCountActualActual, 2028))
+	assert.Equal(t, int64(365), daysInYear(models.DayCountActualActual, 2027))
+}
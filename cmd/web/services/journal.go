@@ -0,0 +1,222 @@
+package services
+
+import (
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"time"
+)
+
+type IJournalService interface {
+	TrialBalance() (models.TrialBalance, error)
+	Backfill() (models.JournalBackfillRun, error)
+}
+
+type JournalService struct {
+	transactionRepository repositories.ITransactionRepository
+}
+
+// TrialBalance totals the postings of every account per currency and checks them against the
+// wallet balances, showing whether the books balance. Both are read from the same snapshot, an
+// operation committing in between would otherwise show up on one side only
+func (service *JournalService) TrialBalance() (models.TrialBalance, error) {
+	var lines []models.TrialBalanceLine
+	var balances map[string]decimal.Decimal
+	err := service.transactionRepository.WithinSnapshot(func(repository repositories.ITransactionRepository) error {
+		var err error
+		if lines, err = repository.GetTrialBalanceLines(); err != nil {
+			return err
+		}
+		balances, err = repository.GetWalletBalancesByCurrency()
+		return err
+	})
+	if err != nil {
+		return models.TrialBalance{}, err
+	}
+
+	return models.NewTrialBalance(lines, balances, time.Now()), nil
+}
+
+// journalBackfillBatch is how many ledger entries without postings a backfill loads at once
+const journalBackfillBatch int = 500
+
+// Backfill posts the journal entries of the ledger entries written before the journal existed, so the
+// trial balance of an upgraded database balances. The entries of an operation are journaled together
+// and what they leave unbalanced is posted where their type posts it today. It only touches entries
+// without postings and locks them while journaling, so it can be run again or by two hosts at once
+func (service *JournalService) Backfill() (models.JournalBackfillRun, error) {
+	run := models.JournalBackfillRun{FailedOperations: []string{}}
+
+	var afterId uint
+	for {
+		pending, err := service.transactionRepository.GetUnjournaledTransactions(afterId, journalBackfillBatch)
+		if err != nil {
+			return run, err
+		}
+
+		journaled := map[string]bool{}
+		for _, transaction := range pending {
+			if journaled[transaction.OperationReference] {
+				continue
+			}
+			if transaction.OperationReference != "" {
+				journaled[transaction.OperationReference] = true
+			}
+
+			entries := 0
+			err := service.transactionRepository.WithinTransaction(func(repository repositories.ITransactionRepository) error {
+				operation, err := repository.LockUnjournaledOperation(transaction)
+				if err != nil || len(operation) == 0 {
+					return err
+				}
+				entries = len(operation)
+				return backfillJournal(repository, operation)
+			})
+			if err != nil {
+				logrus.Errorf("couldn't backfill the journal of ledger entry %d: %v", transaction.ID, err)
+				operationReference := transaction.OperationReference
+				if operationReference == "" {
+					operationReference = transaction.Reference
+				}
+				run.FailedOperations = append(run.FailedOperations, operationReference)
+				continue
+			}
+			if entries > 0 {
+				run.Operations++
+				run.Entries += entries
+			}
+		}
+
+		if len(pending) < journalBackfillBatch {
+			return run, nil
+		}
+		afterId = pending[len(pending)-1].ID
+	}
+}
+
+// backfillJournal posts the ledger entries of one operation as a single journal entry
+func backfillJournal(repository repositories.ITransactionRepository, entries []models.Transaction) error {
+	first := entries[0]
+	operationReference := first.OperationReference
+	if operationReference == "" {
+		operationReference = first.Reference
+	}
+	j := newJournal(first.Type, operationReference)
+
+	// what the entries of each type leave unbalanced goes to the account that type posts against
+	var accounts []string
+	unbalanced := map[string]*models.JournalEntry{}
+	for _, entry := range entries {
+		wallet, err := repository.GetWallet(int(entry.WalletID))
+		if err != nil {
+			return err
+		}
+		j.wallet(wallet, entry)
+
+		account := backfillCounterparty(entry.Type)
+		if _, ok := unbalanced[account]; !ok {
+			accounts = append(accounts, account)
+			unbalanced[account] = &models.JournalEntry{}
+		}
+		unbalanced[account].Postings = append(unbalanced[account].Postings, j.entry.Postings[len(j.entry.Postings)-1])
+	}
+
+	for _, account := range accounts {
+		for currency, total := range unbalanced[account].Imbalances() {
+			if account == models.AccountFXPosition || account == models.AccountInterestExpense {
+				j.system(account, currency, total.Neg())
+				continue
+			}
+
+			system, err := repository.GetSystemAccount(account, currency)
+			if err != nil {
+				return err
+			}
+			direction := models.DirectionDebit
+			if total.IsNegative() {
+				direction = models.DirectionCredit
+			}
+			counterparty := models.Transaction{
+				WalletID:           system.ID,
+				Type:               first.Type,
+				Direction:          direction,
+				Amount:             total.Abs(),
+				Currency:           currency,
+				OperationReference: first.OperationReference,
+			}
+			if counterparty.Reference, err = newReference(); err != nil {
+				return err
+			}
+			if err := repository.CreateTransaction(&counterparty); err != nil {
+				return err
+			}
+			j.wallet(system, counterparty)
+		}
+	}
+	return j.post(repository)
+}
+
+// backfillCounterparty returns the account the services post against for ledger entries of the type
+func backfillCounterparty(entryType string) string {
+	switch entryType {
+	case models.TransactionTypeFX:
+		return models.AccountFXPosition
+	case models.TransactionTypeInterest:
+		return models.AccountInterestExpense
+	case models.TransactionTypeFee:
+		return models.SystemAccountFeeIncome
+	}
+	return models.SystemAccountFunding
+}
+
+// journal collects the postings of an operation until they are posted as a single entry
+type journal struct {
+	entry models.JournalEntry
+}
+
+func newJournal(entryType string, operationReference string) *journal {
+	return &journal{entry: models.JournalEntry{Type: entryType, OperationReference: operationReference}}
+}
+
+// wallet adds the posting of a ledger entry already applied to the wallet
+func (j *journal) wallet(wallet models.Wallet, transaction models.Transaction) *journal {
+	walletId, transactionId := transaction.WalletID, transaction.ID
+	j.entry.Postings = append(j.entry.Postings, models.Posting{
+		Account:       wallet.Account(),
+		Currency:      transaction.Currency,
+		WalletID:      &walletId,
+		TransactionID: &transactionId,
+		Amount:        transaction.Signed(),
+	})
+	return j
+}
+
+// system adds the posting of an account without wallet, amount is negative for debits
+func (j *journal) system(account string, currency string, amount decimal.Decimal) *journal {
+	j.entry.Postings = append(j.entry.Postings, models.Posting{
+		Account:  account,
+		Currency: currency,
+		Amount:   amount,
+	})
+	return j
+}
+
+// post stores the entry once it has at least two postings adding up to zero in every currency, an
+// unbalanced entry fails the operation so its database transaction is rolled back
+func (j *journal) post(repository repositories.ITransactionRepository) error {
+	if len(j.entry.Postings) < 2 {
+		return fmt.Errorf("journal entry %s of operation %s needs at least two postings", j.entry.Type, j.entry.OperationReference)
+	}
+	for currency, total := range j.entry.Imbalances() {
+		return fmt.Errorf("journal entry %s of operation %s is off by %s %s", j.entry.Type, j.entry.OperationReference, total.String(), currency)
+	}
+	return repository.CreateJournalEntry(&j.entry)
+}
+
+func NewJournalService() IJournalService {
+	return &JournalService{
+		transactionRepository: repositories.NewTransactionRepository(),
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wallet-api/cmd/web/models"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"testing"
+	"time"
+)
+
+// balancedEntry matches a journal entry of the given type that balances and posts to the given
+// accounts in order
+func balancedEntry(entryType string, accounts ...string) interface{} {
+	return mock.MatchedBy(func(entry *models.JournalEntry) bool {
+		if entry.Type != entryType || len(entry.Imbalances()) > 0 || len(entry.Postings) != len(accounts) {
+			return false
+		}
+		for i, posting := range entry.Postings {
+			if posting.Account != accounts[i] {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestJournal_Post(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	debit := models.Transaction{WalletID: 1, Direction: models.DirectionDebit, Amount: decimal.NewFromInt(10), Currency: "EUR"}
+
+	err := newJournal(models.TransactionTypeDebit, "operation").
+		wallet(models.Wallet{}, debit).
+		system(models.SystemAccountFunding, "EUR", decimal.NewFromInt(9)).
+		post(repositoryMock)
+	assert.EqualError(t, err, "journal entry debit of operation operation is off by -1 EUR")
+
+	err = newJournal(models.TransactionTypeDebit, "operation").wallet(models.Wallet{}, debit).post(repositoryMock)
+	assert.NotNil(t, err)
+	repositoryMock.AssertNotCalled(t, "CreateJournalEntry", mock.Anything)
+
+	repositoryMock.On("CreateJournalEntry", balancedEntry(models.TransactionTypeDebit, models.AccountWallets, models.SystemAccountFunding)).
+		Return(nil).Once()
+	err = newJournal(models.TransactionTypeDebit, "operation").
+		wallet(models.Wallet{}, debit).
+		system(models.SystemAccountFunding, "EUR", decimal.NewFromInt(10)).
+		post(repositoryMock)
+	assert.Nil(t, err)
+	repositoryMock.AssertExpectations(t)
+}
+
+func TestJournalService_TrialBalance(t *testing.T) {
+
+	repositoryMock := &mocks.RepositoryMock{}
+	lines := []models.TrialBalanceLine{
+		{Account: models.SystemAccountFunding, Currency: "EUR", Debits: decimal.NewFromInt(100), Credits: decimal.NewFromInt(30), Balance: decimal.NewFromInt(-70)},
+		{Account: models.AccountWallets, Currency: "EUR", Debits: decimal.NewFromInt(30), Credits: decimal.NewFromInt(100), Balance: decimal.NewFromInt(70)},
+		{Account: models.SystemAccountFunding, Currency: "USD", Debits: decimal.NewFromInt(5), Balance: decimal.NewFromInt(-5)},
+		{Account: models.AccountWallets, Currency: "USD", Credits: decimal.NewFromInt(5), Balance: decimal.NewFromInt(5)},
+	}
+
+	tests := []struct {
+		name       string
+		balances   map[string]decimal.Decimal
+		assertFunc func(*testing.T, models.TrialBalance)
+	}{
+		{
+			name:     "Success - books balance",
+			balances: map[string]decimal.Decimal{"EUR": decimal.NewFromInt(70), "USD": decimal.NewFromInt(5)},
+			assertFunc: func(t *testing.T, trialBalance models.TrialBalance) {
+				assert.True(t, trialBalance.Balanced)
+				assert.Len(t, trialBalance.Totals, 2)
+				assert.Equal(t, "EUR", trialBalance.Totals[0].Currency)
+				assert.True(t, decimal.NewFromInt(130).Equal(trialBalance.Totals[0].Debits))
+				assert.True(t, trialBalance.Totals[0].Balance.IsZero())
+			},
+		},
+		{
+			name:     "Success - wallet balances disagree with the journal",
+			balances: map[string]decimal.Decimal{"EUR": decimal.NewFromInt(70), "USD": decimal.NewFromInt(6)},
+			assertFunc: func(t *testing.T, trialBalance models.TrialBalance) {
+				assert.False(t, trialBalance.Balanced)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repositoryMock.ExpectedCalls = nil
+			repositoryMock.On("GetTrialBalanceLines").Return(lines, nil).Once()
+			repositoryMock.On("GetWalletBalancesByCurrency").Return(tt.balances, nil).Once()
+			service := JournalService{
+				transactionRepository: repositoryMock,
+			}
+
+			trialBalance, err := service.TrialBalance()
+			assert.Nil(t, err)
+			assert.WithinDuration(t, time.Now(), trialBalance.GeneratedAt, time.Minute)
+			tt.assertFunc(t, trialBalance)
+		})
+	}
+}
+
+func TestJournalService_Backfill(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	eur := func(id uint, walletId uint, entryType string, direction string, amount int64, operationReference string) models.Transaction {
+		return models.Transaction{ID: id, WalletID: walletId, Type: entryType, Direction: direction, Amount: decimal.NewFromInt(amount),
+			Currency: "EUR", Reference: fmt.Sprintf("reference-%d", id), OperationReference: operationReference}
+	}
+	transferDebit := eur(1, 1, models.TransactionTypeTransfer, models.DirectionDebit, 10, "transfer")
+	transferCredit := eur(2, 2, models.TransactionTypeTransfer, models.DirectionCredit, 10, "transfer")
+	transferFee := eur(3, 1, models.TransactionTypeFee, models.DirectionDebit, 1, "transfer")
+	legacyCredit := eur(4, 2, models.TransactionTypeCredit, models.DirectionCredit, 5, "")
+	journaledElsewhere := eur(5, 3, models.TransactionTypeDebit, models.DirectionDebit, 2, "elsewhere")
+	failing := eur(6, 3, models.TransactionTypeDebit, models.DirectionDebit, 2, "failing")
+
+	repositoryMock.On("GetUnjournaledTransactions", uint(0), journalBackfillBatch).
+		Return([]models.Transaction{transferDebit, transferCredit, transferFee, legacyCredit, journaledElsewhere, failing}, nil).Once()
+	repositoryMock.On("GetWallet", mock.Anything).Return(models.Wallet{Currency: "EUR"}, nil)
+
+	// the legs of the transfer balance each other, its fee goes to the fee income account
+	repositoryMock.On("LockUnjournaledOperation", transferDebit).Return([]models.Transaction{transferDebit, transferCredit, transferFee}, nil).Once()
+	repositoryMock.On("GetSystemAccount", models.SystemAccountFeeIncome, "EUR").Return(systemAccount(models.SystemAccountFeeIncome, "EUR"), nil).Once()
+	repositoryMock.On("CreateTransaction", mock.MatchedBy(func(transaction *models.Transaction) bool {
+		return transaction.WalletID == 90 && transaction.Direction == models.DirectionCredit && transaction.Amount.Equal(decimal.NewFromInt(1)) &&
+			transaction.OperationReference == "transfer"
+	})).Return(nil).Once()
+	repositoryMock.On("CreateJournalEntry", balancedEntry(models.TransactionTypeTransfer,
+		models.AccountWallets, models.AccountWallets, models.AccountWallets, models.SystemAccountFeeIncome)).Return(nil).Once()
+
+	// an entry without operation reference is journaled alone against the funding account
+	repositoryMock.On("LockUnjournaledOperation", legacyCredit).Return([]models.Transaction{legacyCredit}, nil).Once()
+	repositoryMock.On("GetSystemAccount", models.SystemAccountFunding, "EUR").Return(systemAccount(models.SystemAccountFunding, "EUR"), nil).Once()
+	repositoryMock.On("CreateTransaction", mock.MatchedBy(func(transaction *models.Transaction) bool {
+		return transaction.WalletID == 90 && transaction.Direction == models.DirectionDebit && transaction.Amount.Equal(decimal.NewFromInt(5))
+	})).Return(nil).Once()
+	repositoryMock.On("CreateJournalEntry", mock.MatchedBy(func(entry *models.JournalEntry) bool {
+		return entry.OperationReference == "reference-4" && len(entry.Imbalances()) == 0
+	})).Return(nil).Once()
+
+	// another run journaled it in between
+	repositoryMock.On("LockUnjournaledOperation", journaledElsewhere).Return([]models.Transaction{}, nil).Once()
+	repositoryMock.On("LockUnjournaledOperation", failing).Return(nil, errors.New("lock wait timeout")).Once()
+
+	service := JournalService{
+		transactionRepository: repositoryMock,
+	}
+
+	run, err := service.Backfill()
+	repositoryMock.AssertExpectations(t)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, run.Operations)
+	assert.Equal(t, 4, run.Entries)
+	assert.Equal(t, []string{"failing"}, run.FailedOperations)
+}
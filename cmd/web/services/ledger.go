@@ -0,0 +1,96 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/shopspring/decimal"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"github.com/wallet-api/exceptions"
+	"strconv"
+)
+
+const (
+	ErrorCodeWalletFrozen           string = "wallet_frozen"
+	ErrorCodeWalletClosed           string = "wallet_closed"
+	ErrorCodeCurrencyMismatch       string = "currency_mismatch"
+	ErrorCodeInvalidAmountPrecision string = "invalid_amount_precision"
+	ErrorCodeInsufficientFunds      string = "insufficient_funds"
+)
+
+// checkActive verifies that the wallet accepts balance movements. System accounts never do, they only
+// move as the counterparty of customer operations
+func checkActive(wallet models.Wallet) error {
+	if wallet.IsSystem() {
+		return exceptions.NewForbiddenExceptionWithCode(ErrorCodeSystemAccount, "operation not allowed: wallet %s is a system account", strconv.Itoa(int(wallet.ID)))
+	}
+	switch wallet.Status {
+	case models.WalletStatusFrozen:
+		return exceptions.NewForbiddenExceptionWithCode(ErrorCodeWalletFrozen, "operation not allowed: the wallet is frozen")
+	case models.WalletStatusClosed:
+		return exceptions.NewForbiddenExceptionWithCode(ErrorCodeWalletClosed, "operation not allowed: the wallet is closed")
+	}
+	return nil
+}
+
+// checkAmount verifies that the amount is expressed in the wallet currency and does not have more
+// decimal places than that currency allows
+func checkAmount(wallet models.Wallet, amount decimal.Decimal, currency string) error {
+	if currency != "" && currency != wallet.Currency {
+		return exceptions.NewForbiddenExceptionWithCode(ErrorCodeCurrencyMismatch, "operation not allowed: the wallet currency is %s", wallet.Currency)
+	}
+
+	units, ok := models.MinorUnits(wallet.Currency)
+	if ok && !amount.Equal(amount.Truncate(units)) {
+		return exceptions.NewForbiddenExceptionWithCode(ErrorCodeInvalidAmountPrecision, "operation not allowed: %s amounts allow %s decimal places", wallet.Currency, strconv.Itoa(int(units)))
+	}
+	return nil
+}
+
+// checkDebit verifies that the wallet spendable amount, which accounts for held funds and the
+// overdraft limit, covers the debit
+func checkDebit(wallet models.Wallet, amount decimal.Decimal) error {
+	spendable := wallet.Spendable()
+	if amount.GreaterThan(spendable) {
+		return exceptions.NewForbiddenExceptionWithDetails(ErrorCodeInsufficientFunds,
+			map[string]interface{}{"spendable": spendable, "currency": wallet.Currency},
+			"operation not allowed: %s, the spendable amount is %s %s", ErrorCodeInvalid, spendable.String(), wallet.Currency)
+	}
+	return nil
+}
+
+// postEntry applies the ledger entry to the wallet balance, saves the wallet and appends the entry
+// that explains the change. Entries without an operation reference start a new operation.
+func postEntry(repository repositories.ITransactionRepository, wallet *models.Wallet, entry models.Transaction) (models.Transaction, error) {
+	if entry.Direction == models.DirectionDebit {
+		wallet.Balance = wallet.Balance.Sub(entry.Amount)
+	} else {
+		wallet.Balance = wallet.Balance.Add(entry.Amount)
+	}
+
+	if err := repository.UpdateWallet(wallet); err != nil {
+		return entry, err
+	}
+
+	reference, err := newReference()
+	if err != nil {
+		return entry, err
+	}
+	if entry.OperationReference == "" {
+		entry.OperationReference = reference
+	}
+
+	entry.WalletID = wallet.ID
+	entry.Balance = wallet.Balance
+	entry.Currency = wallet.Currency
+	entry.Reference = reference
+	return entry, repository.CreateTransaction(&entry)
+}
+
+func newReference() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
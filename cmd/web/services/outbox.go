@@ -0,0 +1,153 @@
+package services
+
+import (
+	"github.com/spf13/viper"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"github.com/wallet-api/infrastructure"
+	"strconv"
+	"time"
+)
+
+type IOutboxService interface {
+	Relay() (int, error)
+}
+
+type OutboxService struct {
+	transactionRepository repositories.ITransactionRepository
+	// webhooks queues the webhook deliveries of a message, it runs once per message
+	webhooks infrastructure.Publisher
+	// publisher appends the message to the stream downstream services read
+	publisher   infrastructure.Publisher
+	batchSize   int
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+}
+
+// defaults used when the outbox settings are not configured or not positive
+const (
+	defaultOutboxBatchSize   int           = 100
+	defaultOutboxMaxAttempts int           = 10
+	defaultOutboxBackoff     time.Duration = time.Second
+)
+
+// Relay publishes the pending outbox messages oldest first and marks them delivered, returning how
+// many were. A message the publisher rejects is retried after a backoff and parked once it failed
+// maxAttempts times. Until then the later messages of its wallet wait so each wallet keeps its order,
+// while the messages of other wallets go on. A message published but not marked is published
+// again, so delivery is at least once. The first publication error is returned once the run ends
+func (service *OutboxService) Relay() (int, error) {
+	batchSize := service.batchSize
+	if batchSize < 1 {
+		batchSize = defaultOutboxBatchSize
+	}
+
+	now := time.Now()
+	delivered := 0
+	var firstErr error
+	// wallets with a message waiting for its retry, their later messages are held back
+	waiting := map[uint]bool{}
+	afterId := uint(0)
+	for {
+		messages, err := service.transactionRepository.GetPendingOutboxMessages(afterId, batchSize)
+		if err != nil {
+			return delivered, err
+		}
+
+		for _, message := range messages {
+			afterId = message.ID
+			if waiting[message.WalletID] {
+				continue
+			}
+			if message.NextAttemptAt != nil && message.NextAttemptAt.After(now) {
+				waiting[message.WalletID] = true
+				continue
+			}
+
+			err := service.publish(message, now)
+			if err == nil {
+				if err := service.transactionRepository.MarkOutboxMessageDelivered(message.ID, now); err != nil {
+					return delivered, err
+				}
+				delivered++
+				continue
+			}
+
+			if firstErr == nil {
+				firstErr = err
+			}
+			if message.Attempts+1 >= service.attempts() {
+				if err := service.transactionRepository.ParkOutboxMessage(message.ID, err.Error(), now); err != nil {
+					return delivered, err
+				}
+				continue
+			}
+			if err := service.transactionRepository.MarkOutboxMessageFailed(message.ID, err.Error(), now.Add(service.delay(message.Attempts+1))); err != nil {
+				return delivered, err
+			}
+			waiting[message.WalletID] = true
+		}
+
+		if len(messages) < batchSize {
+			return delivered, firstErr
+		}
+	}
+}
+
+// publish queues the webhook deliveries of the message unless a previous attempt did, then appends
+// it to the stream
+func (service *OutboxService) publish(message models.OutboxMessage, now time.Time) error {
+	published := infrastructure.Message{
+		ID:      strconv.Itoa(int(message.ID)),
+		Type:    message.EventType,
+		Key:     strconv.Itoa(int(message.WalletID)),
+		Payload: []byte(message.Payload),
+	}
+
+	if message.WebhooksQueuedAt == nil && service.webhooks != nil {
+		if err := service.webhooks.Publish(published); err != nil {
+			return err
+		}
+		if err := service.transactionRepository.MarkOutboxWebhooksQueued(message.ID, now); err != nil {
+			return err
+		}
+	}
+	return service.publisher.Publish(published)
+}
+
+func (service *OutboxService) attempts() int {
+	if service.maxAttempts < 1 {
+		return defaultOutboxMaxAttempts
+	}
+	return service.maxAttempts
+}
+
+// delay is how long the message waits after its failed attempt number attempts, doubling from the
+// backoff up to the max backoff
+func (service *OutboxService) delay(attempts int) time.Duration {
+	delay := service.backoff
+	if delay <= 0 {
+		delay = defaultOutboxBackoff
+	}
+	for i := 1; i < attempts && (service.maxBackoff <= 0 || delay < service.maxBackoff); i++ {
+		delay *= 2
+	}
+	if service.maxBackoff > 0 && delay > service.maxBackoff {
+		return service.maxBackoff
+	}
+	return delay
+}
+
+func NewOutboxService() IOutboxService {
+	transactionRepository := repositories.NewTransactionRepository()
+	return &OutboxService{
+		transactionRepository: transactionRepository,
+		webhooks:              &webhookPublisher{transactionRepository: transactionRepository},
+		publisher:             infrastructure.NewPublisher(),
+		batchSize:             viper.GetInt("outbox.batch_size"),
+		maxAttempts:           viper.GetInt("outbox.max_attempts"),
+		backoff:               viper.GetDuration("outbox.backoff"),
+		maxBackoff:            viper.GetDuration("outbox.max_backoff"),
+	}
+}
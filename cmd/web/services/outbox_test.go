@@ -0,0 +1,181 @@
+package services
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/infrastructure"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"testing"
+	"time"
+)
+
+func TestOutboxService_Relay(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	webhooks := &infrastructure.MemoryPublisher{}
+	publisher := &infrastructure.MemoryPublisher{}
+	queued := time.Now().Add(-time.Minute)
+	later := time.Now().Add(time.Hour)
+	credited := models.OutboxMessage{ID: 1, EventType: models.OutboxEventWalletCredited, WalletID: 1, Payload: `{"id":10}`}
+	debited := models.OutboxMessage{ID: 2, EventType: models.OutboxEventWalletDebited, WalletID: 1, Payload: `{"id":11}`}
+	third := models.OutboxMessage{ID: 3, EventType: models.OutboxEventWalletCredited, WalletID: 2, Payload: `{"id":12}`}
+
+	service := OutboxService{
+		transactionRepository: repositoryMock,
+		webhooks:              webhooks,
+		publisher:             publisher,
+		batchSize:             2,
+		maxAttempts:           3,
+		backoff:               time.Second,
+	}
+
+	tests := []struct {
+		name        string
+		initMocks   func()
+		assertMocks func(*testing.T)
+		assertError func(*testing.T, error)
+		assertFunc  func(*testing.T, int)
+	}{
+		{
+			name: "Success - pending messages published in order across batches",
+			initMocks: func() {
+				publisher.Err = nil
+				repositoryMock.On("GetPendingOutboxMessages", uint(0), 2).Return([]models.OutboxMessage{credited, debited}, nil).Once()
+				repositoryMock.On("GetPendingOutboxMessages", uint(2), 2).Return([]models.OutboxMessage{third}, nil).Once()
+				repositoryMock.On("MarkOutboxWebhooksQueued", mock.Anything, mock.Anything).Return(nil).Times(3)
+				repositoryMock.On("MarkOutboxMessageDelivered", uint(1), mock.Anything).Return(nil).Once()
+				repositoryMock.On("MarkOutboxMessageDelivered", uint(2), mock.Anything).Return(nil).Once()
+				repositoryMock.On("MarkOutboxMessageDelivered", uint(3), mock.Anything).Return(nil).Once()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+			assertFunc: func(t *testing.T, delivered int) {
+				assert.Equal(t, 3, delivered)
+				messages := publisher.Messages()
+				assert.Len(t, messages, 3)
+				assert.Equal(t, infrastructure.Message{ID: "2", Type: models.OutboxEventWalletDebited, Key: "1", Payload: []byte(`{"id":11}`)}, messages[1])
+			},
+		},
+		{
+			name: "Error - a failing message is retried later and only holds back its own wallet",
+			initMocks: func() {
+				publisher.Err = errors.New("connection refused")
+				repositoryMock.On("GetPendingOutboxMessages", uint(0), 2).Return([]models.OutboxMessage{credited, debited}, nil).Once()
+				repositoryMock.On("GetPendingOutboxMessages", uint(2), 2).Return([]models.OutboxMessage{third}, nil).Once()
+				repositoryMock.On("MarkOutboxWebhooksQueued", uint(1), mock.Anything).Return(nil).Once()
+				repositoryMock.On("MarkOutboxMessageFailed", uint(1), "connection refused", mock.MatchedBy(func(retryAt time.Time) bool {
+					return retryAt.After(time.Now())
+				})).Return(nil).Once()
+				repositoryMock.On("MarkOutboxWebhooksQueued", uint(3), mock.Anything).Return(nil).Once()
+				repositoryMock.On("MarkOutboxMessageFailed", uint(3), "connection refused", mock.Anything).Return(nil).Once()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+				repositoryMock.AssertNotCalled(t, "MarkOutboxWebhooksQueued", uint(2), mock.Anything)
+				repositoryMock.AssertNotCalled(t, "MarkOutboxMessageDelivered", mock.Anything, mock.Anything)
+			},
+			assertError: func(t *testing.T, err error) {
+				assert.EqualError(t, err, "connection refused")
+			},
+			assertFunc: func(t *testing.T, delivered int) {
+				assert.Equal(t, 0, delivered)
+			},
+		},
+		{
+			name: "Success - a message waiting for its retry holds back its wallet, its webhooks are not queued again",
+			initMocks: func() {
+				publisher.Err = nil
+				waiting := debited
+				waiting.NextAttemptAt = &later
+				retried := models.OutboxMessage{ID: 5, EventType: models.OutboxEventWalletCredited, WalletID: 2, Attempts: 1,
+					WebhooksQueuedAt: &queued, NextAttemptAt: &queued}
+				repositoryMock.On("GetPendingOutboxMessages", uint(0), 2).Return([]models.OutboxMessage{waiting, third}, nil).Once()
+				repositoryMock.On("GetPendingOutboxMessages", uint(3), 2).Return([]models.OutboxMessage{{ID: 4, WalletID: 1}, retried}, nil).Once()
+				repositoryMock.On("GetPendingOutboxMessages", uint(5), 2).Return([]models.OutboxMessage{}, nil).Once()
+				repositoryMock.On("MarkOutboxWebhooksQueued", uint(3), mock.Anything).Return(nil).Once()
+				repositoryMock.On("MarkOutboxMessageDelivered", uint(3), mock.Anything).Return(nil).Once()
+				repositoryMock.On("MarkOutboxMessageDelivered", uint(5), mock.Anything).Return(nil).Once()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+				repositoryMock.AssertNotCalled(t, "MarkOutboxMessageDelivered", uint(4), mock.Anything)
+				repositoryMock.AssertNotCalled(t, "MarkOutboxWebhooksQueued", uint(5), mock.Anything)
+			},
+			assertError: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+			assertFunc: func(t *testing.T, delivered int) {
+				assert.Equal(t, 2, delivered)
+			},
+		},
+		{
+			name: "Error - a message out of attempts is parked and its wallet goes on",
+			initMocks: func() {
+				publisher.Err = errors.New("connection refused")
+				exhausted := credited
+				exhausted.Attempts = 2
+				exhausted.WebhooksQueuedAt = &queued
+				repositoryMock.On("GetPendingOutboxMessages", uint(0), 2).Return([]models.OutboxMessage{exhausted, debited}, nil).Once()
+				repositoryMock.On("GetPendingOutboxMessages", uint(2), 2).Return([]models.OutboxMessage{}, nil).Once()
+				repositoryMock.On("ParkOutboxMessage", uint(1), "connection refused", mock.Anything).Return(nil).Once()
+				repositoryMock.On("MarkOutboxWebhooksQueued", uint(2), mock.Anything).Return(nil).Once()
+				repositoryMock.On("MarkOutboxMessageFailed", uint(2), "connection refused", mock.Anything).Return(nil).Once()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, err error) {
+				assert.EqualError(t, err, "connection refused")
+			},
+			assertFunc: func(t *testing.T, delivered int) {
+				assert.Equal(t, 0, delivered)
+			},
+		},
+		{
+			name: "Error - published but not marked, published again on the next run",
+			initMocks: func() {
+				publisher.Err = nil
+				repositoryMock.On("GetPendingOutboxMessages", uint(0), 2).Return([]models.OutboxMessage{credited}, nil).Once()
+				repositoryMock.On("MarkOutboxWebhooksQueued", uint(1), mock.Anything).Return(nil).Once()
+				repositoryMock.On("MarkOutboxMessageDelivered", uint(1), mock.Anything).Return(errors.New("database down")).Once()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, err error) {
+				assert.EqualError(t, err, "database down")
+			},
+			assertFunc: func(t *testing.T, delivered int) {
+				assert.Equal(t, 0, delivered)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repositoryMock.ExpectedCalls = nil
+			repositoryMock.Calls = nil
+			tt.initMocks()
+
+			delivered, err := service.Relay()
+			tt.assertMocks(t)
+			tt.assertError(t, err)
+			tt.assertFunc(t, delivered)
+		})
+	}
+}
+
+func TestOutboxService_Delay(t *testing.T) {
+	service := OutboxService{backoff: time.Second, maxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, service.delay(1))
+	assert.Equal(t, 4*time.Second, service.delay(3))
+	assert.Equal(t, 5*time.Second, service.delay(10))
+
+	// a non positive backoff falls back to the default so failed messages are never due right away
+	assert.Equal(t, defaultOutboxBackoff, (&OutboxService{}).delay(1))
+}
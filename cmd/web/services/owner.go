@@ -0,0 +1,89 @@
+package services
+
+import (
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"sort"
+)
+
+type IOwnerService interface {
+	Create(request models.CreateOwnerRequest) (models.Owner, error)
+	Get(ownerId int) (models.Owner, error)
+	GetWallets(ownerId int) ([]models.Wallet, error)
+	GetBalance(ownerId int) (models.OwnerBalance, error)
+}
+
+type OwnerService struct {
+	transactionRepository repositories.ITransactionRepository
+	// eventSourced folds the balance and status of the wallets from their events
+	eventSourced bool
+}
+
+const ErrorCodeOwnerNotActive string = "owner_not_active"
+
+func (service *OwnerService) Create(request models.CreateOwnerRequest) (models.Owner, error) {
+	owner := models.Owner{
+		ExternalUserID: request.ExternalUserID,
+		Name:           request.Name,
+		Status:         models.OwnerStatusActive,
+	}
+	err := service.transactionRepository.CreateOwner(&owner)
+
+	return owner, err
+}
+
+func (service *OwnerService) Get(ownerId int) (models.Owner, error) {
+	return service.transactionRepository.GetOwner(ownerId)
+}
+
+func (service *OwnerService) GetWallets(ownerId int) ([]models.Wallet, error) {
+	if _, err := service.transactionRepository.GetOwner(ownerId); err != nil {
+		return nil, err
+	}
+	if service.eventSourced {
+		return projectedWallets(service.transactionRepository, func(repository repositories.ITransactionRepository) ([]models.Wallet, error) {
+			return repository.GetOwnerWallets(ownerId)
+		})
+	}
+	return service.transactionRepository.GetOwnerWallets(ownerId)
+}
+
+// GetBalance adds up the balances of the owner wallets that are not closed, per currency
+func (service *OwnerService) GetBalance(ownerId int) (models.OwnerBalance, error) {
+	wallets, err := service.GetWallets(ownerId)
+	if err != nil {
+		return models.OwnerBalance{}, err
+	}
+
+	byCurrency := map[string]*models.OwnerCurrencyBalance{}
+	for _, wallet := range wallets {
+		if wallet.Status == models.WalletStatusClosed {
+			continue
+		}
+		balance, ok := byCurrency[wallet.Currency]
+		if !ok {
+			balance = &models.OwnerCurrencyBalance{Balance: models.Balance{Currency: wallet.Currency}}
+			byCurrency[wallet.Currency] = balance
+		}
+		balance.Balance.Balance = balance.Balance.Balance.Add(wallet.Balance)
+		balance.AvailableBalance = balance.AvailableBalance.Add(wallet.Available())
+		balance.OverdraftHeadroom = balance.OverdraftHeadroom.Add(wallet.OverdraftHeadroom())
+		balance.Wallets++
+	}
+
+	result := models.OwnerBalance{OwnerID: uint(ownerId), Balances: []models.OwnerCurrencyBalance{}}
+	for _, balance := range byCurrency {
+		result.Balances = append(result.Balances, *balance)
+	}
+	sort.Slice(result.Balances, func(i, j int) bool {
+		return result.Balances[i].Currency < result.Balances[j].Currency
+	})
+	return result, nil
+}
+
+func NewOwnerService() IOwnerService {
+	return &OwnerService{
+		transactionRepository: repositories.NewTransactionRepository(),
+		eventSourced:          newConcurrencyPolicy().eventSourced,
+	}
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"testing"
+)
+
+func TestOwnerService_GetBalance(t *testing.T) {
+
+	repositoryMock := &mocks.RepositoryMock{}
+	owner := models.Owner{ID: 1, Status: models.OwnerStatusActive}
+	wallets := []models.Wallet{
+		{Balance: decimal.NewFromInt(100), HeldBalance: decimal.NewFromInt(30), Currency: "USD", Status: models.WalletStatusActive},
+		{Balance: decimal.NewFromInt(50), Currency: "EUR", Status: models.WalletStatusFrozen},
+		{Balance: decimal.NewFromInt(20), OverdraftLimit: decimal.NewFromInt(10), Currency: "USD", Status: models.WalletStatusActive},
+		{Balance: decimal.Zero, Currency: "ARS", Status: models.WalletStatusClosed},
+	}
+
+	tests := []struct {
+		name        string
+		initMocks   func()
+		assertMocks func(*testing.T)
+		assertError func(*testing.T, error)
+		assertFunc  func(*testing.T, models.OwnerBalance)
+	}{
+		{
+			name: "Success - open wallets added up per currency",
+			initMocks: func() {
+				repositoryMock.On("GetOwner", 1).Return(owner, nil).Once()
+				repositoryMock.On("GetOwnerWallets", 1).Return(wallets, nil).Once()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, balance models.OwnerBalance) {
+				assert.Len(t, balance.Balances, 2)
+				assert.Equal(t, "EUR", balance.Balances[0].Currency)
+				assert.Equal(t, 1, balance.Balances[0].Wallets)
+				assert.Equal(t, "USD", balance.Balances[1].Currency)
+				assert.Equal(t, 2, balance.Balances[1].Wallets)
+				assert.True(t, decimal.NewFromInt(120).Equal(balance.Balances[1].Balance.Balance))
+				assert.True(t, decimal.NewFromInt(90).Equal(balance.Balances[1].AvailableBalance))
+				assert.True(t, decimal.NewFromInt(10).Equal(balance.Balances[1].OverdraftHeadroom))
+			},
+		},
+		{
+			name: "Error - owner not found",
+			initMocks: func() {
+				repositoryMock.On("GetOwner", 1).Return(models.Owner{}, exceptions.NewNotFoundException("owner with id=%s not found", "1")).Once()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertNotCalled(t, "GetOwnerWallets", 1)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.IsType(t, &exceptions.NotFoundException{}, e)
+			},
+			assertFunc: func(t *testing.T, balance models.OwnerBalance) {},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repositoryMock.ExpectedCalls = nil
+			repositoryMock.Calls = nil
+			tt.initMocks()
+			service := OwnerService{
+				transactionRepository: repositoryMock,
+			}
+
+			balance, err := service.GetBalance(1)
+			tt.assertMocks(t)
+			tt.assertError(t, err)
+			tt.assertFunc(t, balance)
+		})
+	}
+}
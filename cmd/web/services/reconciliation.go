@@ -0,0 +1,217 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"github.com/wallet-api/exceptions"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+type IReconciliationService interface {
+	Reconcile(options models.ReconciliationOptions) (models.ReconciliationReport, error)
+	Start(options models.ReconciliationOptions) (models.ReconciliationStarted, error)
+}
+
+type ReconciliationService struct {
+	transactionRepository repositories.ITransactionRepository
+	concurrency           concurrencyPolicy
+	reportDir             string
+	freezeMismatched      bool
+	// running is 1 while a run started by Start is in progress
+	running int32
+}
+
+const reconciliationWalletsBatch int = 100
+const reconciliationReportFile string = "reconciliation-%s.json"
+
+// Reconcile compares every wallet balance against the sum of its ledger entries and its cached copy.
+// Wallets whose ledger disagrees can be frozen, stale cache entries are only evicted since money
+// movements never read the cache
+func (service *ReconciliationService) Reconcile(options models.ReconciliationOptions) (models.ReconciliationReport, error) {
+	freeze := service.freezeMismatched
+	if options.Freeze != nil {
+		freeze = *options.Freeze
+	}
+
+	report := models.ReconciliationReport{
+		StartedAt:     time.Now(),
+		Discrepancies: []models.WalletDiscrepancy{},
+	}
+	var afterId uint
+	for {
+		ids, err := service.transactionRepository.GetWalletIDs(afterId, reconciliationWalletsBatch)
+		if err != nil {
+			return report, err
+		}
+
+		for _, id := range ids {
+			discrepancy, err := service.reconcileWallet(int(id), freeze)
+			if err != nil {
+				logrus.Errorf("couldn't reconcile wallet %d: %v", id, err)
+				report.FailedWallets = append(report.FailedWallets, id)
+				continue
+			}
+			report.WalletsChecked++
+			if discrepancy != nil {
+				report.Discrepancies = append(report.Discrepancies, *discrepancy)
+			}
+		}
+
+		if len(ids) < reconciliationWalletsBatch {
+			break
+		}
+		afterId = ids[len(ids)-1]
+	}
+	report.FinishedAt = time.Now()
+
+	if err := service.writeReport(&report); err != nil {
+		logrus.Errorf("couldn't write reconciliation report: %v", err)
+	}
+	return report, nil
+}
+
+// Start runs Reconcile in the background, one run at a time, its report is written to the report dir
+func (service *ReconciliationService) Start(options models.ReconciliationOptions) (models.ReconciliationStarted, error) {
+	if !atomic.CompareAndSwapInt32(&service.running, 0, 1) {
+		return models.ReconciliationStarted{}, exceptions.NewConflictException("operation not allowed: a reconciliation is already running")
+	}
+
+	started := models.ReconciliationStarted{StartedAt: time.Now()}
+	go func() {
+		defer atomic.StoreInt32(&service.running, 0)
+		report, err := service.Reconcile(options)
+		if err != nil {
+			logrus.Errorf("reconciliation failed: %v", err)
+			return
+		}
+		logrus.Infof("reconciliation checked %d wallets, found %d discrepancies, report %s",
+			report.WalletsChecked, len(report.Discrepancies), report.File)
+	}()
+	return started, nil
+}
+
+func (service *ReconciliationService) reconcileWallet(walletId int, freeze bool) (*models.WalletDiscrepancy, error) {
+	var discrepancy *models.WalletDiscrepancy
+	var wallet models.Wallet
+	// the row and the ledger are read from the same snapshot, so an entry posted meanwhile is seen in both
+	// or in neither without locking the wallet, and the ledger is summed from its latest balance snapshot
+	err := service.transactionRepository.WithinSnapshot(func(repository repositories.ITransactionRepository) error {
+		var err error
+		wallet, err = repository.GetWalletFromDatabase(walletId)
+		if err != nil {
+			return err
+		}
+		// the balance of system accounts only exists as the sum of their ledger
+		if wallet.IsSystem() {
+			return nil
+		}
+		if service.concurrency.eventSourced {
+			if wallet, _, _, err = foldStream(repository, wallet, defaultEventsBatch); err != nil {
+				return err
+			}
+		}
+
+		ledger, err := balanceAt(repository, walletId, time.Now())
+		if err != nil || ledger.Equal(wallet.Balance) {
+			return err
+		}
+		discrepancy = &models.WalletDiscrepancy{
+			WalletID:       wallet.ID,
+			Balance:        wallet.Balance,
+			LedgerBalance:  ledger,
+			LedgerMismatch: true,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if discrepancy != nil && freeze && wallet.Status == models.WalletStatusActive {
+		frozen, err := service.freeze(walletId)
+		if err != nil {
+			return nil, err
+		}
+		discrepancy.Frozen = frozen
+	}
+
+	cached, err := service.transactionRepository.GetCachedWallet(walletId)
+	if err != nil {
+		return nil, err
+	}
+	if cached == nil || cached.Balance.Equal(wallet.Balance) {
+		return discrepancy, nil
+	}
+
+	if discrepancy == nil {
+		discrepancy = &models.WalletDiscrepancy{
+			WalletID:      wallet.ID,
+			Balance:       wallet.Balance,
+			LedgerBalance: wallet.Balance,
+		}
+	}
+	discrepancy.CachedBalance = &cached.Balance
+	discrepancy.CacheMismatch = true
+	service.transactionRepository.EvictCachedWallet(wallet.ID)
+
+	return discrepancy, nil
+}
+
+// freeze locks the wallet only to freeze it, under the policy so the freeze is recorded as an event when
+// wallets are event sourced
+func (service *ReconciliationService) freeze(walletId int) (bool, error) {
+	frozen := false
+	err := service.concurrency.run(service.transactionRepository, func(repository repositories.ITransactionRepository) error {
+		frozen = false
+		wallet, err := service.concurrency.loadWallet(repository, walletId)
+		if err != nil || wallet.Status != models.WalletStatusActive {
+			return err
+		}
+
+		wallet.Status = models.WalletStatusFrozen
+		if err := repository.UpdateWallet(&wallet); err != nil {
+			return err
+		}
+		frozen = true
+		return nil
+	})
+	return frozen, err
+}
+
+func (service *ReconciliationService) writeReport(report *models.ReconciliationReport) error {
+	if service.reportDir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(service.reportDir, 0755); err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file := filepath.Join(service.reportDir, fmt.Sprintf(reconciliationReportFile, report.StartedAt.UTC().Format("20060102T150405Z")))
+	if err := ioutil.WriteFile(file, content, 0644); err != nil {
+		return err
+	}
+	report.File = file
+	return nil
+}
+
+func NewReconciliationService() IReconciliationService {
+	return &ReconciliationService{
+		transactionRepository: repositories.NewTransactionRepository(),
+		concurrency:           newConcurrencyPolicy(),
+		reportDir:             viper.GetString("reconciliation.report_dir"),
+		freezeMismatched:      viper.GetBool("reconciliation.freeze_mismatched"),
+	}
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"io/ioutil"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReconciliationService_Reconcile(t *testing.T) {
+
+	repositoryMock := &mocks.RepositoryMock{}
+	freeze := true
+
+	wallet := func(id uint, balance int64) models.Wallet {
+		w := models.Wallet{Balance: decimal.NewFromInt(balance), Status: models.WalletStatusActive}
+		w.ID = id
+		return w
+	}
+
+	type args struct {
+		options models.ReconciliationOptions
+	}
+
+	tests := []struct {
+		name        string
+		initMocks   func()
+		args        args
+		assertMocks func(*testing.T)
+		assertError func(*testing.T, error)
+		assertFunc  func(*testing.T, models.ReconciliationReport)
+	}{
+		{
+			name: "Success - ledger mismatch frozen and stale cache evicted",
+			initMocks: func() {
+				repositoryMock.On("GetWalletIDs", uint(0), reconciliationWalletsBatch).
+					Return([]uint{1, 2, 3}, nil).Once()
+
+				repositoryMock.On("GetWalletFromDatabase", 1).Return(wallet(1, 100), nil).Once()
+				repositoryMock.On("GetLatestSnapshot", 1, mock.Anything).Return(nil, nil).Once()
+				repositoryMock.On("SumLedger", 1, uint(0), mock.Anything).
+					Return(models.LedgerSum{Total: decimal.NewFromInt(100)}, nil).Once()
+				repositoryMock.On("GetCachedWallet", 1).Return(nil, nil).Once()
+
+				// summed from the latest balance snapshot, only locked to be frozen
+				repositoryMock.On("GetWalletFromDatabase", 2).Return(wallet(2, 100), nil).Once()
+				repositoryMock.On("GetLatestSnapshot", 2, mock.Anything).
+					Return(&models.BalanceSnapshot{Balance: decimal.NewFromInt(60), TransactionID: 7}, nil).Once()
+				repositoryMock.On("SumLedger", 2, uint(7), mock.Anything).
+					Return(models.LedgerSum{Total: decimal.NewFromInt(30)}, nil).Once()
+				repositoryMock.On("GetWalletForUpdate", 2).Return(wallet(2, 100), nil).Once()
+				repositoryMock.On("UpdateWallet", mock.MatchedBy(func(w *models.Wallet) bool {
+					return w.ID == 2 && w.Status == models.WalletStatusFrozen
+				})).Return(nil).Once()
+				cached := wallet(2, 100)
+				repositoryMock.On("GetCachedWallet", 2).Return(&cached, nil).Once()
+
+				repositoryMock.On("GetWalletFromDatabase", 3).Return(wallet(3, 50), nil).Once()
+				repositoryMock.On("GetLatestSnapshot", 3, mock.Anything).Return(nil, nil).Once()
+				repositoryMock.On("SumLedger", 3, uint(0), mock.Anything).
+					Return(models.LedgerSum{Total: decimal.NewFromInt(50)}, nil).Once()
+				stale := wallet(3, 70)
+				repositoryMock.On("GetCachedWallet", 3).Return(&stale, nil).Once()
+				repositoryMock.On("EvictCachedWallet", uint(3)).Once()
+			},
+			args: args{
+				options: models.ReconciliationOptions{Freeze: &freeze},
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, report models.ReconciliationReport) {
+				assert.Equal(t, 3, report.WalletsChecked)
+				assert.Len(t, report.Discrepancies, 2)
+
+				assert.Equal(t, uint(2), report.Discrepancies[0].WalletID)
+				assert.True(t, report.Discrepancies[0].LedgerMismatch)
+				assert.True(t, report.Discrepancies[0].Frozen)
+				assert.False(t, report.Discrepancies[0].CacheMismatch)
+
+				assert.Equal(t, uint(3), report.Discrepancies[1].WalletID)
+				assert.False(t, report.Discrepancies[1].LedgerMismatch)
+				assert.True(t, report.Discrepancies[1].CacheMismatch)
+				assert.True(t, report.Discrepancies[1].CachedBalance.Equal(decimal.NewFromInt(70)))
+
+				content, err := ioutil.ReadFile(report.File)
+				assert.Nil(t, err)
+				assert.Contains(t, string(content), `"wallets_checked": 3`)
+			},
+		},
+		{
+			name: "Success - failing wallet reported and skipped",
+			initMocks: func() {
+				repositoryMock.On("GetWalletIDs", uint(0), reconciliationWalletsBatch).
+					Return([]uint{4}, nil).Once()
+				repositoryMock.On("GetWalletFromDatabase", 4).Return(models.Wallet{}, errors.New("some error")).Once()
+			},
+			args: args{},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, report models.ReconciliationReport) {
+				assert.Equal(t, 0, report.WalletsChecked)
+				assert.Equal(t, []uint{4}, report.FailedWallets)
+			},
+		},
+		{
+			name: "Error - wallets couldn't be listed",
+			initMocks: func() {
+				repositoryMock.On("GetWalletIDs", uint(0), reconciliationWalletsBatch).
+					Return(nil, errors.New("some error")).Once()
+			},
+			args: args{},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.NotNil(t, e)
+			},
+			assertFunc: func(t *testing.T, report models.ReconciliationReport) {
+				assert.Empty(t, report.File)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.initMocks()
+			service := ReconciliationService{
+				transactionRepository: repositoryMock,
+				reportDir:             t.TempDir(),
+			}
+
+			report, err := service.Reconcile(tt.args.options)
+			tt.assertMocks(t)
+			tt.assertError(t, err)
+			tt.assertFunc(t, report)
+		})
+	}
+}
+
+func TestReconciliationService_Start(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	repositoryMock.On("GetWalletIDs", uint(0), reconciliationWalletsBatch).Return([]uint{}, nil).
+		Run(func(mock.Arguments) { <-release }).Once()
+
+	service := ReconciliationService{
+		transactionRepository: repositoryMock,
+	}
+
+	started, err := service.Start(models.ReconciliationOptions{})
+	assert.Nil(t, err)
+	assert.False(t, started.StartedAt.IsZero())
+
+	// a second run is refused while the first goes on in the background
+	_, err = service.Start(models.ReconciliationOptions{})
+	assert.IsType(t, &exceptions.ConflictException{}, err)
+
+	go func() {
+		for atomic.LoadInt32(&service.running) == 1 {
+			time.Sleep(time.Millisecond)
+		}
+		close(finished)
+	}()
+	close(release)
+	<-finished
+	repositoryMock.AssertExpectations(t)
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"time"
+)
+
+type ISnapshotService interface {
+	TakeSnapshots() (int, error)
+}
+
+type SnapshotService struct {
+	transactionRepository repositories.ITransactionRepository
+	lag                   time.Duration
+}
+
+const snapshotWalletsBatch int = 100
+
+// defaultSnapshotLag is used when snapshots.lag is not configured
+const defaultSnapshotLag = 5 * time.Minute
+
+// TakeSnapshots stores a new balance snapshot for every wallet with ledger entries posted since its last one.
+// Only entries older than the lag are covered, ids are taken when an entry is inserted but it is visible
+// from its commit, so the newest entries may still have lower ids committing after them
+func (service *SnapshotService) TakeSnapshots() (int, error) {
+	lag := service.lag
+	if lag <= 0 {
+		lag = defaultSnapshotLag
+	}
+
+	taken := 0
+	var afterId uint
+	for {
+		ids, err := service.transactionRepository.GetWalletIDs(afterId, snapshotWalletsBatch)
+		if err != nil {
+			return taken, err
+		}
+
+		cutoff := time.Now().Add(-lag)
+		for _, id := range ids {
+			ok, err := service.takeSnapshot(int(id), cutoff)
+			if err != nil {
+				logrus.Errorf("couldn't take balance snapshot of wallet %d: %v", id, err)
+				continue
+			}
+			if ok {
+				taken++
+			}
+		}
+
+		if len(ids) < snapshotWalletsBatch {
+			return taken, nil
+		}
+		afterId = ids[len(ids)-1]
+	}
+}
+
+// takeSnapshot covers the entries up to the newest one created before the cutoff, together with every
+// entry of a lower id created after it, so no entry is left behind the snapshot
+func (service *SnapshotService) takeSnapshot(walletId int, cutoff time.Time) (bool, error) {
+	snapshot, err := service.transactionRepository.GetLatestSnapshot(walletId, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	var base decimal.Decimal
+	var lastTransactionId uint
+	if snapshot != nil {
+		base = snapshot.Balance
+		lastTransactionId = snapshot.TransactionID
+	}
+
+	settled, err := service.transactionRepository.SumLedger(walletId, lastTransactionId, cutoff)
+	if err != nil {
+		return false, err
+	}
+	if settled.LastTransactionID == lastTransactionId {
+		return false, nil
+	}
+
+	sum, err := service.transactionRepository.SumLedgerRange(walletId, lastTransactionId, settled.LastTransactionID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, service.transactionRepository.CreateSnapshot(&models.BalanceSnapshot{
+		WalletID:      uint(walletId),
+		TransactionID: sum.LastTransactionID,
+		Balance:       base.Add(sum.Total),
+		TakenAt:       sum.LastCreatedAt,
+	})
+}
+
+// balanceAt computes the ledger balance of a wallet at the given time, starting from the latest snapshot
+// taken before it so only the entries posted after that snapshot are added up
+func balanceAt(repository repositories.ITransactionRepository, walletId int, at time.Time) (decimal.Decimal, error) {
+	snapshot, err := repository.GetLatestSnapshot(walletId, at)
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	var base decimal.Decimal
+	var lastTransactionId uint
+	if snapshot != nil {
+		base = snapshot.Balance
+		lastTransactionId = snapshot.TransactionID
+	}
+
+	sum, err := repository.SumLedger(walletId, lastTransactionId, at)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return base.Add(sum.Total), nil
+}
+
+func NewSnapshotService() ISnapshotService {
+	return &SnapshotService{
+		transactionRepository: repositories.NewTransactionRepository(),
+		lag:                   viper.GetDuration("snapshots.lag"),
+	}
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wallet-api/cmd/web/models"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"testing"
+	"time"
+)
+
+func TestSnapshotService_TakeSnapshots(t *testing.T) {
+
+	repositoryMock := &mocks.RepositoryMock{}
+	lastCreatedAt := time.Date(2021, 3, 31, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		initMocks   func()
+		assertMocks func(*testing.T)
+		assertError func(*testing.T, error)
+		assertFunc  func(*testing.T, int)
+	}{
+		{
+			name: "Success - only wallets with new entries get a snapshot",
+			initMocks: func() {
+				repositoryMock.On("GetWalletIDs", uint(0), snapshotWalletsBatch).
+					Return([]uint{1, 2}, nil).Once()
+				repositoryMock.On("GetLatestSnapshot", 1, mock.Anything).
+					Return(&models.BalanceSnapshot{TransactionID: 10, Balance: decimal.NewFromInt(50)}, nil).Once()
+				// entry 12 was created before the cutoff, entry 11 after it but it is covered by the snapshot
+				repositoryMock.On("SumLedger", 1, uint(10), mock.MatchedBy(func(cutoff time.Time) bool {
+					return cutoff.Before(time.Now().Add(-time.Minute))
+				})).Return(models.LedgerSum{Total: decimal.NewFromInt(5), LastTransactionID: 12, LastCreatedAt: lastCreatedAt}, nil).Once()
+				repositoryMock.On("SumLedgerRange", 1, uint(10), uint(12)).
+					Return(models.LedgerSum{Total: decimal.NewFromInt(8), LastTransactionID: 12, LastCreatedAt: lastCreatedAt.Add(time.Second)}, nil).Once()
+				repositoryMock.On("CreateSnapshot", mock.MatchedBy(func(snapshot *models.BalanceSnapshot) bool {
+					return snapshot.WalletID == 1 && snapshot.TransactionID == 12 &&
+						snapshot.Balance.Equal(decimal.NewFromInt(58)) && snapshot.TakenAt.Equal(lastCreatedAt.Add(time.Second))
+				})).Return(nil).Once()
+				repositoryMock.On("GetLatestSnapshot", 2, mock.Anything).
+					Return(&models.BalanceSnapshot{TransactionID: 7, Balance: decimal.NewFromInt(20)}, nil).Once()
+				repositoryMock.On("SumLedger", 2, uint(7), mock.Anything).
+					Return(models.LedgerSum{LastTransactionID: 7}, nil).Once()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, taken int) {
+				assert.Equal(t, 1, taken)
+			},
+		},
+		{
+			name: "Error - wallets couldn't be listed",
+			initMocks: func() {
+				repositoryMock.On("GetWalletIDs", uint(0), snapshotWalletsBatch).
+					Return(nil, errors.New("some error")).Once()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.NotNil(t, e)
+			},
+			assertFunc: func(t *testing.T, taken int) {
+				assert.Equal(t, 0, taken)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.initMocks()
+			service := SnapshotService{
+				transactionRepository: repositoryMock,
+				lag:                   2 * time.Minute,
+			}
+
+			taken, err := service.TakeSnapshots()
+			tt.assertMocks(t)
+			tt.assertError(t, err)
+			tt.assertFunc(t, taken)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"io"
+)
+
+type IStatementService interface {
+	Export(walletId int, request models.StatementRequest, out io.Writer) error
+}
+
+type StatementService struct {
+	transactionRepository repositories.ITransactionRepository
+}
+
+// Export writes the wallet statement for the period in the requested format. The entries are streamed
+// from the ledger as they are written, so nothing is written to out when the wallet doesn't exist
+func (service *StatementService) Export(walletId int, request models.StatementRequest, out io.Writer) error {
+	wallet, err := service.transactionRepository.GetWallet(walletId)
+	if err != nil {
+		return err
+	}
+
+	opening, err := balanceAt(service.transactionRepository, walletId, request.From)
+	if err != nil {
+		return err
+	}
+
+	writer := newStatementWriter(request.Format, out)
+	if err := writer.Begin(models.StatementHeader{
+		WalletID:       wallet.ID,
+		Currency:       wallet.Currency,
+		From:           request.From,
+		To:             request.To,
+		OpeningBalance: opening,
+	}); err != nil {
+		return err
+	}
+
+	summary := models.StatementSummary{
+		ClosingBalance: opening,
+		TotalCredits:   decimal.Zero,
+		TotalDebits:    decimal.Zero,
+	}
+	err = service.transactionRepository.StreamTransactions(walletId, request.From, request.To, func(transaction models.Transaction) error {
+		if transaction.Direction == models.DirectionCredit {
+			summary.ClosingBalance = summary.ClosingBalance.Add(transaction.Amount)
+			summary.TotalCredits = summary.TotalCredits.Add(transaction.Amount)
+		} else {
+			summary.ClosingBalance = summary.ClosingBalance.Sub(transaction.Amount)
+			summary.TotalDebits = summary.TotalDebits.Add(transaction.Amount)
+		}
+		summary.Entries++
+
+		return writer.Line(models.StatementLine{
+			TransactionID:      transaction.ID,
+			Date:               transaction.CreatedAt,
+			Type:               transaction.Type,
+			Direction:          transaction.Direction,
+			Amount:             transaction.Amount,
+			RunningBalance:     summary.ClosingBalance,
+			Reference:          transaction.Reference,
+			OperationReference: transaction.OperationReference,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return writer.End(summary)
+}
+
+func NewStatementService() IStatementService {
+	return &StatementService{
+		transactionRepository: repositories.NewTransactionRepository(),
+	}
+}
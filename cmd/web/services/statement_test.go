@@ -0,0 +1,151 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/wallet-api/cmd/web/models"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"testing"
+	"time"
+)
+
+func TestStatementService_Export(t *testing.T) {
+
+	repositoryMock := &mocks.RepositoryMock{}
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2021, 3, 31, 23, 59, 0, 0, time.UTC)
+
+	wallet := models.Wallet{Currency: "EUR"}
+	wallet.ID = 1
+	entries := []models.Transaction{
+		{ID: 11, Type: models.TransactionTypeCredit, Direction: models.DirectionCredit, Amount: decimal.NewFromInt(50), Reference: "a", CreatedAt: from.Add(time.Hour)},
+		{ID: 12, Type: models.TransactionTypeDebit, Direction: models.DirectionDebit, Amount: decimal.NewFromInt(30), Reference: "b&c", CreatedAt: from.Add(2 * time.Hour)},
+	}
+
+	mockStatement := func() {
+		repositoryMock.On("GetWallet", 1).Return(wallet, nil).Once()
+		repositoryMock.On("GetLatestSnapshot", 1, from).Return(nil, nil).Once()
+		repositoryMock.On("SumLedger", 1, uint(0), from).
+			Return(models.LedgerSum{Total: decimal.NewFromInt(100), LastTransactionID: 10}, nil).Once()
+		repositoryMock.On("StreamTransactions", 1, from, to).Return(entries, nil).Once()
+	}
+
+	type args struct {
+		format string
+	}
+
+	tests := []struct {
+		name        string
+		initMocks   func()
+		args        args
+		assertMocks func(*testing.T)
+		assertError func(*testing.T, error)
+		assertFunc  func(*testing.T, string)
+	}{
+		{
+			name:      "Success - json statement with running balance",
+			initMocks: mockStatement,
+			args: args{
+				format: models.StatementFormatJSON,
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, out string) {
+				var statement struct {
+					models.StatementHeader
+					models.StatementSummary
+					Lines []models.StatementLine `json:"lines"`
+				}
+				assert.Nil(t, json.Unmarshal([]byte(out), &statement))
+				assert.True(t, statement.OpeningBalance.Equal(decimal.NewFromInt(100)))
+				assert.Len(t, statement.Lines, 2)
+				assert.True(t, statement.Lines[0].RunningBalance.Equal(decimal.NewFromInt(150)))
+				assert.True(t, statement.Lines[1].RunningBalance.Equal(decimal.NewFromInt(120)))
+				assert.True(t, statement.ClosingBalance.Equal(decimal.NewFromInt(120)))
+				assert.True(t, statement.TotalDebits.Equal(decimal.NewFromInt(30)))
+				assert.Equal(t, 2, statement.Entries)
+			},
+		},
+		{
+			name:      "Success - csv statement with opening and closing rows",
+			initMocks: mockStatement,
+			args: args{
+				format: models.StatementFormatCSV,
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, out string) {
+				rows, err := csv.NewReader(bytes.NewBufferString(out)).ReadAll()
+				assert.Nil(t, err)
+				assert.Len(t, rows, 5)
+				assert.Equal(t, []string{"2021-03-01T00:00:00Z", "", "opening_balance", "", "", "100", "EUR", "", ""}, rows[1])
+				assert.Equal(t, "12", rows[3][1])
+				assert.Equal(t, "120", rows[3][5])
+				assert.Equal(t, "closing_balance", rows[4][2])
+				assert.Equal(t, "120", rows[4][5])
+			},
+		},
+		{
+			name:      "Success - ofx statement",
+			initMocks: mockStatement,
+			args: args{
+				format: models.StatementFormatOFX,
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, out string) {
+				assert.Contains(t, out, "<CURDEF>EUR</CURDEF>")
+				assert.Contains(t, out, "<TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20210301020000</DTPOSTED><TRNAMT>-30</TRNAMT><FITID>b&amp;c</FITID>")
+				assert.Contains(t, out, "<LEDGERBAL><BALAMT>120</BALAMT>")
+			},
+		},
+		{
+			name: "Error - wallet not found writes nothing",
+			initMocks: func() {
+				repositoryMock.On("GetWallet", 1).Return(models.Wallet{}, errors.New("some error")).Once()
+			},
+			args: args{
+				format: models.StatementFormatCSV,
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.NotNil(t, e)
+			},
+			assertFunc: func(t *testing.T, out string) {
+				assert.Empty(t, out)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.initMocks()
+			service := StatementService{
+				transactionRepository: repositoryMock,
+			}
+
+			var out bytes.Buffer
+			err := service.Export(1, models.StatementRequest{From: from, To: to, Format: tt.args.format}, &out)
+			tt.assertMocks(t)
+			tt.assertError(t, err)
+			tt.assertFunc(t, out.String())
+		})
+	}
+}
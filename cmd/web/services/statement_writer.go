@@ -0,0 +1,156 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/wallet-api/cmd/web/models"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statementWriter renders a statement as it is read from the ledger, one line at a time
+type statementWriter interface {
+	Begin(header models.StatementHeader) error
+	Line(line models.StatementLine) error
+	End(summary models.StatementSummary) error
+}
+
+func newStatementWriter(format string, out io.Writer) statementWriter {
+	switch format {
+	case models.StatementFormatCSV:
+		return &csvStatementWriter{writer: csv.NewWriter(out)}
+	case models.StatementFormatOFX:
+		return &ofxStatementWriter{writer: bufio.NewWriter(out)}
+	default:
+		return &jsonStatementWriter{writer: bufio.NewWriter(out)}
+	}
+}
+
+// jsonStatementWriter writes the header fields, a lines array and the summary fields as a single object
+type jsonStatementWriter struct {
+	writer *bufio.Writer
+	lines  int
+}
+
+func (w *jsonStatementWriter) Begin(header models.StatementHeader) error {
+	content, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	// reopen the header object to append the lines to it
+	w.writer.Write(content[:len(content)-1])
+	_, err = w.writer.WriteString(`,"lines":[`)
+	return err
+}
+
+func (w *jsonStatementWriter) Line(line models.StatementLine) error {
+	content, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	if w.lines > 0 {
+		w.writer.WriteByte(',')
+	}
+	w.lines++
+	_, err = w.writer.Write(content)
+	return err
+}
+
+func (w *jsonStatementWriter) End(summary models.StatementSummary) error {
+	content, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	w.writer.WriteString("],")
+	w.writer.Write(content[1:])
+	return w.writer.Flush()
+}
+
+// csvStatementWriter writes the opening and closing balances as the first and last rows
+type csvStatementWriter struct {
+	writer   *csv.Writer
+	currency string
+}
+
+func (w *csvStatementWriter) Begin(header models.StatementHeader) error {
+	w.currency = header.Currency
+	w.writer.Write([]string{"date", "transaction_id", "type", "direction", "amount", "balance", "currency", "reference", "operation_reference"})
+	return w.writer.Write([]string{formatStatementTime(header.From), "", "opening_balance", "", "", header.OpeningBalance.String(), w.currency, "", ""})
+}
+
+func (w *csvStatementWriter) Line(line models.StatementLine) error {
+	return w.writer.Write([]string{
+		formatStatementTime(line.Date),
+		strconv.FormatUint(uint64(line.TransactionID), 10),
+		line.Type,
+		line.Direction,
+		line.Amount.String(),
+		line.RunningBalance.String(),
+		w.currency,
+		line.Reference,
+		line.OperationReference,
+	})
+}
+
+func (w *csvStatementWriter) End(summary models.StatementSummary) error {
+	w.writer.Write([]string{"", "", "closing_balance", "", "", summary.ClosingBalance.String(), w.currency, "", ""})
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+func formatStatementTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// ofxStatementWriter writes an OFX 2.2 bank statement, which has no opening balance element
+// so only the closing balance is reported as the ledger balance
+type ofxStatementWriter struct {
+	writer *bufio.Writer
+	to     time.Time
+}
+
+const ofxDateFormat string = "20060102150405"
+
+func (w *ofxStatementWriter) Begin(header models.StatementHeader) error {
+	w.to = header.To
+	w.writer.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n")
+	w.writer.WriteString(`<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n")
+	w.writer.WriteString("<OFX><BANKMSGSRSV1><STMTTRNRS><TRNUID>0</TRNUID>")
+	w.writer.WriteString("<STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS><STMTRS>")
+	fmt.Fprintf(w.writer, "<CURDEF>%s</CURDEF>", ofxEscape(header.Currency))
+	fmt.Fprintf(w.writer, "<BANKACCTFROM><BANKID>wallet-api</BANKID><ACCTID>%d</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>", header.WalletID)
+	_, err := fmt.Fprintf(w.writer, "<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>\n",
+		header.From.UTC().Format(ofxDateFormat), header.To.UTC().Format(ofxDateFormat))
+	return err
+}
+
+func (w *ofxStatementWriter) Line(line models.StatementLine) error {
+	trnType := "CREDIT"
+	amount := line.Amount
+	if line.Direction == models.DirectionDebit {
+		trnType = "DEBIT"
+		amount = amount.Neg()
+	}
+	_, err := fmt.Fprintf(w.writer, "<STMTTRN><TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%s</TRNAMT><FITID>%s</FITID><NAME>%s</NAME><MEMO>%s</MEMO></STMTTRN>\n",
+		trnType, line.Date.UTC().Format(ofxDateFormat), amount.String(),
+		ofxEscape(line.Reference), ofxEscape(line.Type), ofxEscape(line.OperationReference))
+	return err
+}
+
+func (w *ofxStatementWriter) End(summary models.StatementSummary) error {
+	fmt.Fprintf(w.writer, "</BANKTRANLIST><LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>",
+		summary.ClosingBalance.String(), w.to.UTC().Format(ofxDateFormat))
+	w.writer.WriteString("</STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>\n")
+	return w.writer.Flush()
+}
+
+func ofxEscape(value string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(value))
+	return escaped.String()
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"github.com/wallet-api/exceptions"
+	"time"
+)
+
+type ISystemAccountService interface {
+	GetBalances() ([]models.SystemAccountBalance, error)
+}
+
+type SystemAccountService struct {
+	transactionRepository repositories.ITransactionRepository
+}
+
+const (
+	ErrorCodeSystemAccount        string = "system_account"
+	ErrorCodeSystemAccountMissing string = "system_account_missing"
+)
+
+// GetBalances lists the balance of every system account, so treasury can follow the float. System
+// account rows are never updated, their balance is the sum of their ledger from the latest snapshot
+func (service *SystemAccountService) GetBalances() ([]models.SystemAccountBalance, error) {
+	wallets, err := service.transactionRepository.GetSystemAccounts()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	balances := make([]models.SystemAccountBalance, 0, len(wallets))
+	for _, wallet := range wallets {
+		balance, err := balanceAt(service.transactionRepository, int(wallet.ID), now)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, models.SystemAccountBalance{
+			WalletID: wallet.ID,
+			Type:     wallet.Account(),
+			Currency: wallet.Currency,
+			Balance:  balance,
+			At:       now,
+		})
+	}
+	return balances, nil
+}
+
+// postCounterparty appends the opposite of a customer entry to the system account of its currency and
+// journals both sides together. The system account row is neither locked nor updated, every operation
+// in the currency would queue on it otherwise, so its entries carry no running balance
+func postCounterparty(repository repositories.ITransactionRepository, accountType string, wallet models.Wallet, entry models.Transaction) error {
+	account, err := repository.GetSystemAccount(accountType, entry.Currency)
+	if _, ok := err.(*exceptions.NotFoundException); ok {
+		// the wallet of the request exists, what is missing is the house side of the currency
+		return exceptions.NewForbiddenExceptionWithCode(ErrorCodeSystemAccountMissing, "operation not allowed: there is no %s account for %s", accountType, entry.Currency)
+	}
+	if err != nil {
+		return err
+	}
+
+	direction := models.DirectionCredit
+	if entry.Direction == models.DirectionCredit {
+		direction = models.DirectionDebit
+	}
+	counterparty := models.Transaction{
+		WalletID:           account.ID,
+		Type:               entry.Type,
+		Direction:          direction,
+		Amount:             entry.Amount,
+		Currency:           account.Currency,
+		OperationReference: entry.OperationReference,
+	}
+	counterparty.Reference, err = newReference()
+	if err != nil {
+		return err
+	}
+	if err := repository.CreateTransaction(&counterparty); err != nil {
+		return err
+	}
+
+	return newJournal(entry.Type, entry.OperationReference).
+		wallet(wallet, entry).
+		wallet(account, counterparty).
+		post(repository)
+}
+
+func NewSystemAccountService() ISystemAccountService {
+	return &SystemAccountService{
+		transactionRepository: repositories.NewTransactionRepository(),
+	}
+}
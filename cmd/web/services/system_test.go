@@ -0,0 +1,81 @@
+package services
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"testing"
+	"time"
+)
+
+// systemAccount returns a system account wallet whose id doesn't clash with the customer wallets of the tests
+func systemAccount(accountType string, currency string) models.Wallet {
+	wallet := models.Wallet{Currency: currency, Status: models.WalletStatusActive, SystemAccount: &accountType}
+	wallet.ID = 90
+	return wallet
+}
+
+// expectCounterparty expects the system account side of a customer operation, appended without locking
+// or updating the account row, and the journal entry joining both sides
+func expectCounterparty(repositoryMock *mocks.RepositoryMock, accountType string, entryType string, currency string) {
+	account := systemAccount(accountType, currency)
+	repositoryMock.On("GetSystemAccount", accountType, currency).Return(account, nil).Once()
+	repositoryMock.On("CreateTransaction", mock.MatchedBy(func(transaction *models.Transaction) bool {
+		return transaction.WalletID == account.ID && transaction.Type == entryType
+	})).Return(nil).Once()
+	repositoryMock.On("CreateJournalEntry", balancedEntry(entryType, models.AccountWallets, accountType)).Return(nil).Once()
+}
+
+func TestSystemAccountService_GetBalances(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	funding := systemAccount(models.SystemAccountFunding, "EUR")
+	repositoryMock.On("GetSystemAccounts").Return([]models.Wallet{funding}, nil).Once()
+	repositoryMock.On("GetLatestSnapshot", 90, mock.Anything).Return(nil, nil).Once()
+	repositoryMock.On("SumLedger", 90, uint(0), mock.Anything).Return(models.LedgerSum{Total: decimal.NewFromInt(-250)}, nil).Once()
+
+	service := SystemAccountService{
+		transactionRepository: repositoryMock,
+	}
+
+	balances, err := service.GetBalances()
+	repositoryMock.AssertExpectations(t)
+	assert.Nil(t, err)
+	assert.Len(t, balances, 1)
+	assert.Equal(t, models.SystemAccountFunding, balances[0].Type)
+	assert.True(t, decimal.NewFromInt(-250).Equal(balances[0].Balance))
+	assert.WithinDuration(t, time.Now(), balances[0].At, time.Minute)
+}
+
+func TestTransactionService_CreditSystemAccount(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	repositoryMock.On("GetWalletForUpdate", 90).Return(systemAccount(models.SystemAccountFeeIncome, "EUR"), nil).Once()
+
+	service := TransactionService{
+		transactionRepository: repositoryMock,
+	}
+
+	err := service.Credit(90, models.WalletRequest{Amount: decimal.NewFromInt(10)})
+	assert.Equal(t, ErrorCodeSystemAccount, err.(*exceptions.ForbiddenException).Code)
+	repositoryMock.AssertNotCalled(t, "UpdateWallet", mock.Anything)
+}
+
+func TestTransactionService_CreditSystemAccountMissing(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	wallet := models.Wallet{Balance: decimal.NewFromInt(100), Currency: "EUR", Status: models.WalletStatusActive}
+	wallet.ID = 1
+	repositoryMock.On("GetWalletForUpdate", 1).Return(wallet, nil).Once()
+	repositoryMock.On("UpdateWallet", mock.Anything).Return(nil).Once()
+	repositoryMock.On("CreateTransaction", mock.Anything).Return(nil).Once()
+	repositoryMock.On("GetSystemAccount", models.SystemAccountFunding, "EUR").
+		Return(models.Wallet{}, exceptions.NewNotFoundException("there is no %s system account for %s", models.SystemAccountFunding, "EUR")).Once()
+
+	service := TransactionService{
+		transactionRepository: repositoryMock,
+	}
+
+	err := service.Credit(1, models.WalletRequest{Amount: decimal.NewFromInt(10)})
+	assert.Equal(t, ErrorCodeSystemAccountMissing, err.(*exceptions.ForbiddenException).Code)
+}
@@ -1,68 +1,275 @@
 package services
 
 import (
-	"github.com/shopspring/decimal"
+	"github.com/wallet-api/cmd/web/models"
 	"github.com/wallet-api/cmd/web/repositories"
 	"github.com/wallet-api/exceptions"
+	"time"
 )
 
 type ITransactionService interface {
-	GetBalance(walletId int) (decimal.Decimal, error)
-	Debit(walletId int, amount decimal.Decimal) error
-	Credit(walletId int, amount decimal.Decimal) error
+	GetBalance(walletId int) (models.Balance, error)
+	GetBalanceAt(walletId int, at time.Time) (models.HistoricalBalance, error)
+	Debit(walletId int, request models.WalletRequest) error
+	Credit(walletId int, request models.WalletRequest) error
+	GetTransactions(walletId int, filter models.TransactionFilter) ([]models.Transaction, error)
+	Reverse(transactionId int, request models.ReversalRequest) (models.Transaction, error)
 }
 
 type TransactionService struct {
 	transactionRepository repositories.ITransactionRepository
+	concurrency           concurrencyPolicy
+	velocity              velocityPolicy
+	fees                  feePolicy
 }
 
 const ErrorCodeInvalidParamsPositive string = "the amount must be positive"
-const ErrorCodeInvalid string = "a wallet balance cannot go below 0."
+const (
+	ErrorCodeNotReversible            string = "transaction_not_reversible"
+	ErrorCodeAlreadyReversed          string = "transaction_already_reversed"
+	ErrorCodeReversalExceedsRemaining string = "reversal_exceeds_remaining"
+)
+
+const ErrorCodeInvalid string = "a wallet balance cannot go below its overdraft limit"
+
+func (service *TransactionService) GetBalance(walletId int) (models.Balance, error) {
+	var wallet models.Wallet
+	var err error
+	if service.concurrency.eventSourced {
+		wallet, err = projectedWallet(service.transactionRepository, walletId)
+	} else {
+		wallet, err = service.transactionRepository.GetWallet(walletId)
+	}
+	if err != nil {
+		return models.Balance{}, err
+	}
+	if wallet.IsSystem() {
+		wallet.Balance, err = balanceAt(service.transactionRepository, walletId, time.Now())
+		if err != nil {
+			return models.Balance{}, err
+		}
+	}
 
-func (service *TransactionService) GetBalance(walletId int) (decimal.Decimal, error) {
+	return models.Balance{
+		Balance:           wallet.Balance,
+		AvailableBalance:  wallet.Available(),
+		OverdraftHeadroom: wallet.OverdraftHeadroom(),
+		Currency:          wallet.Currency,
+	}, err
+}
+
+// GetBalanceAt returns the ledger balance the wallet had at the given time
+func (service *TransactionService) GetBalanceAt(walletId int, at time.Time) (models.HistoricalBalance, error) {
 	wallet, err := service.transactionRepository.GetWallet(walletId)
 	if err != nil {
-		return decimal.Decimal{}, err
+		return models.HistoricalBalance{}, err
+	}
+
+	balance, err := balanceAt(service.transactionRepository, walletId, at)
+	if err != nil {
+		return models.HistoricalBalance{}, err
 	}
 
-	return wallet.Balance, err
+	return models.HistoricalBalance{
+		Balance:  balance,
+		Currency: wallet.Currency,
+		At:       at,
+	}, nil
+}
+
+func (service *TransactionService) Debit(walletId int, request models.WalletRequest) error {
+	return service.concurrency.run(service.transactionRepository, func(repository repositories.ITransactionRepository) error {
+		_, err := debitWallet(service.concurrency, service.velocity, service.fees, repository, walletId, request)
+		return err
+	})
 }
 
-func (service *TransactionService) Debit(walletId int, amount decimal.Decimal) error {
-	//If it were a real feature, it would be necessary to have a lock or do the two queries in formal transaction
+func (service *TransactionService) Credit(walletId int, request models.WalletRequest) error {
+	return service.concurrency.run(service.transactionRepository, func(repository repositories.ITransactionRepository) error {
+		_, err := creditWallet(service.concurrency, service.fees, repository, walletId, request)
+		return err
+	})
+}
+
+// debitWallet posts a debit on the wallet within the running database transaction, the wallet must
+// afford both the amount and its fee
+func debitWallet(policy concurrencyPolicy, velocity velocityPolicy, fees feePolicy, repository repositories.ITransactionRepository, walletId int, request models.WalletRequest) (models.Transaction, error) {
+	amount := request.Amount
 	if !amount.IsPositive() {
-		return exceptions.NewForbiddenException("operation not allowed: %s", ErrorCodeInvalidParamsPositive)
+		return models.Transaction{}, exceptions.NewForbiddenException("operation not allowed: %s", ErrorCodeInvalidParamsPositive)
 	}
 
-	wallet, err := service.transactionRepository.GetWallet(walletId)
+	wallet, err := policy.loadWallet(repository, walletId)
 	if err != nil {
-		return err
+		return models.Transaction{}, err
 	}
-	if wallet.Balance.LessThanOrEqual(amount) {
-		return exceptions.NewForbiddenException("operation not allowed: %s", ErrorCodeInvalid)
+	if err := checkActive(wallet); err != nil {
+		return models.Transaction{}, err
+	}
+	if err := checkAmount(wallet, amount, request.Currency); err != nil {
+		return models.Transaction{}, err
+	}
+	fee := fees.fee(models.FeeOperationDebit, wallet, amount)
+	if err := checkDebit(wallet, amount.Add(fee)); err != nil {
+		return models.Transaction{}, err
+	}
+	if err := velocity.check(repository, wallet, amount); err != nil {
+		return models.Transaction{}, err
 	}
 
-	wallet.Balance = wallet.Balance.Sub(amount)
-	return service.transactionRepository.UpdateWallet(wallet)
+	entry, err := postEntry(repository, &wallet, models.Transaction{
+		Type:              models.TransactionTypeDebit,
+		Direction:         models.DirectionDebit,
+		Amount:            amount,
+		Description:       request.Description,
+		ExternalReference: request.ExternalReference,
+		Metadata:          request.Metadata,
+	})
+	if err != nil {
+		return entry, err
+	}
+	if err := postCounterparty(repository, models.SystemAccountFunding, wallet, entry); err != nil {
+		return entry, err
+	}
+	return entry, fees.charge(repository, &wallet, fee, entry.OperationReference)
 }
 
-func (service *TransactionService) Credit(walletId int, amount decimal.Decimal) error {
-	//If it were a real feature, it would be necessary to have a lock or do the two queries in formal transaction
+// creditWallet posts a credit on the wallet within the running database transaction, its fee is
+// taken from the credited balance
+func creditWallet(policy concurrencyPolicy, fees feePolicy, repository repositories.ITransactionRepository, walletId int, request models.WalletRequest) (models.Transaction, error) {
+	amount := request.Amount
 	if !amount.IsPositive() {
-		return exceptions.NewForbiddenException("operation not allowed: %s", ErrorCodeInvalidParamsPositive)
+		return models.Transaction{}, exceptions.NewForbiddenException("operation not allowed: %s", ErrorCodeInvalidParamsPositive)
 	}
 
-	wallet, err := service.transactionRepository.GetWallet(walletId)
+	wallet, err := policy.loadWallet(repository, walletId)
 	if err != nil {
-		return err
+		return models.Transaction{}, err
+	}
+	if err := checkActive(wallet); err != nil {
+		return models.Transaction{}, err
+	}
+	if err := checkAmount(wallet, amount, request.Currency); err != nil {
+		return models.Transaction{}, err
+	}
+
+	entry, err := postEntry(repository, &wallet, models.Transaction{
+		Type:              models.TransactionTypeCredit,
+		Direction:         models.DirectionCredit,
+		Amount:            amount,
+		Description:       request.Description,
+		ExternalReference: request.ExternalReference,
+		Metadata:          request.Metadata,
+	})
+	if err != nil {
+		return entry, err
+	}
+	if err := postCounterparty(repository, models.SystemAccountFunding, wallet, entry); err != nil {
+		return entry, err
+	}
+
+	fee := fees.fee(models.FeeOperationCredit, wallet, amount)
+	if err := checkDebit(wallet, fee); err != nil {
+		return entry, err
+	}
+	return entry, fees.charge(repository, &wallet, fee, entry.OperationReference)
+}
+
+func (service *TransactionService) GetTransactions(walletId int, filter models.TransactionFilter) ([]models.Transaction, error) {
+	if _, err := service.transactionRepository.GetWallet(walletId); err != nil {
+		return nil, err
 	}
 
-	wallet.Balance = wallet.Balance.Add(amount)
-	return service.transactionRepository.UpdateWallet(wallet)
+	return service.transactionRepository.GetTransactions(walletId, filter)
+}
+
+// Reverse posts a compensating entry for a debit, credit or capture, fully or partially. The sum
+// of the reversals of an entry can never exceed its amount.
+func (service *TransactionService) Reverse(transactionId int, request models.ReversalRequest) (models.Transaction, error) {
+	var reversal models.Transaction
+	err := service.concurrency.run(service.transactionRepository, func(repository repositories.ITransactionRepository) error {
+		original, err := repository.GetTransactionForUpdate(transactionId)
+		if err != nil {
+			return err
+		}
+		if !reversible(original) {
+			return exceptions.NewForbiddenExceptionWithCode(ErrorCodeNotReversible, "operation not allowed: %s transactions cannot be reversed", original.Type)
+		}
+
+		reversed, err := repository.GetReversedAmount(transactionId)
+		if err != nil {
+			return err
+		}
+		remaining := original.Amount.Sub(reversed)
+		if !remaining.IsPositive() {
+			return exceptions.NewForbiddenExceptionWithCode(ErrorCodeAlreadyReversed, "operation not allowed: the transaction was already reversed")
+		}
+
+		amount := remaining
+		if request.Amount != nil {
+			amount = *request.Amount
+		}
+		if !amount.IsPositive() {
+			return exceptions.NewForbiddenException("operation not allowed: %s", ErrorCodeInvalidParamsPositive)
+		}
+		if amount.GreaterThan(remaining) {
+			return exceptions.NewForbiddenExceptionWithCode(ErrorCodeReversalExceedsRemaining, "operation not allowed: only %s can still be reversed", remaining.String())
+		}
+
+		wallet, err := service.concurrency.loadWallet(repository, int(original.WalletID))
+		if err != nil {
+			return err
+		}
+		if err := checkActive(wallet); err != nil {
+			return err
+		}
+		if err := checkAmount(wallet, amount, ""); err != nil {
+			return err
+		}
+
+		direction := models.DirectionCredit
+		if original.Direction == models.DirectionCredit {
+			direction = models.DirectionDebit
+			if err := checkDebit(wallet, amount); err != nil {
+				return err
+			}
+		}
+
+		reversal, err = postEntry(repository, &wallet, models.Transaction{
+			Type:                  models.TransactionTypeReversal,
+			Direction:             direction,
+			Amount:                amount,
+			ReversedTransactionID: &original.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		// the reversed entry moved funds from or to outside the wallets, so the reversal does too
+		if err := postCounterparty(repository, models.SystemAccountFunding, wallet, reversal); err != nil {
+			return err
+		}
+		return refundFees(repository, &wallet, original, reversed, amount, reversal.OperationReference)
+	})
+
+	return reversal, err
+}
+
+// reversible reports whether the ledger entry moved funds of a single wallet, so it can be undone
+// without touching other wallets
+func reversible(transaction models.Transaction) bool {
+	switch transaction.Type {
+	case models.TransactionTypeDebit, models.TransactionTypeCredit, models.TransactionTypeCapture:
+		return true
+	}
+	return false
 }
 
 func NewTransactionService() ITransactionService {
 	return &TransactionService{
 		transactionRepository: repositories.NewTransactionRepository(),
+		concurrency:           newConcurrencyPolicy(),
+		velocity:              newVelocityPolicy(),
+		fees:                  newFeePolicy(),
 	}
 }
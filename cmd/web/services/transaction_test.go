@@ -2,12 +2,15 @@ package services
 
 import (
 	"errors"
+	"github.com/jinzhu/gorm"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
 	mocks "github.com/wallet-api/mocks/repositories"
 	"testing"
+	"time"
 )
 
 func TestTransactionService_GetBalance(t *testing.T) {
@@ -24,14 +27,16 @@ func TestTransactionService_GetBalance(t *testing.T) {
 		args        args
 		assertMocks func(*testing.T)
 		assertError func(*testing.T, error)
-		assertFunc  func(*testing.T, decimal.Decimal)
+		assertFunc  func(*testing.T, models.Balance)
 	}{
 		{
 			name: "Success - repository response ok",
 			initMocks: func() {
 				repositoryMock.On("GetWallet", 1).
 					Return(models.Wallet{
-						Balance: decimal.NewFromInt(222),
+						Balance:     decimal.NewFromInt(222),
+						HeldBalance: decimal.NewFromInt(22),
+						Currency:    "EUR",
 					}, nil).Once()
 			},
 			args: args{
@@ -43,8 +48,35 @@ func TestTransactionService_GetBalance(t *testing.T) {
 			assertError: func(t *testing.T, e error) {
 				assert.Nil(t, e)
 			},
-			assertFunc: func(t *testing.T, balance decimal.Decimal) {
-				assert.Equal(t, decimal.NewFromInt(222), balance)
+			assertFunc: func(t *testing.T, balance models.Balance) {
+				assert.Equal(t, decimal.NewFromInt(222), balance.Balance)
+				assert.True(t, balance.AvailableBalance.Equal(decimal.NewFromInt(200)))
+				assert.Equal(t, "EUR", balance.Currency)
+			},
+		},
+		{
+			name: "Success - the overdraft headroom is reported apart from the available balance",
+			initMocks: func() {
+				repositoryMock.On("GetWallet", 1).
+					Return(models.Wallet{
+						Balance:        decimal.NewFromInt(-30),
+						HeldBalance:    decimal.NewFromInt(10),
+						OverdraftLimit: decimal.NewFromInt(100),
+						Currency:       "EUR",
+					}, nil).Once()
+			},
+			args: args{
+				walletId: 1,
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, balance models.Balance) {
+				assert.True(t, balance.AvailableBalance.Equal(decimal.NewFromInt(-40)))
+				assert.True(t, balance.OverdraftHeadroom.Equal(decimal.NewFromInt(60)))
 			},
 		},
 		{
@@ -62,7 +94,7 @@ func TestTransactionService_GetBalance(t *testing.T) {
 			assertError: func(t *testing.T, e error) {
 				assert.NotNil(t, e)
 			},
-			assertFunc: func(t *testing.T, balance decimal.Decimal) {
+			assertFunc: func(t *testing.T, balance models.Balance) {
 			},
 		},
 	}
@@ -81,6 +113,109 @@ func TestTransactionService_GetBalance(t *testing.T) {
 	}
 }
 
+func TestTransactionService_GetBalanceAt(t *testing.T) {
+
+	repositoryMock := &mocks.RepositoryMock{}
+	at := time.Date(2021, 3, 31, 23, 59, 0, 0, time.UTC)
+
+	type args struct {
+		walletId int
+		at       time.Time
+	}
+
+	tests := []struct {
+		name        string
+		initMocks   func()
+		args        args
+		assertMocks func(*testing.T)
+		assertError func(*testing.T, error)
+		assertFunc  func(*testing.T, models.HistoricalBalance)
+	}{
+		{
+			name: "Success - snapshot plus later entries",
+			initMocks: func() {
+				repositoryMock.On("GetWallet", 1).
+					Return(models.Wallet{Currency: "EUR"}, nil).Once()
+				repositoryMock.On("GetLatestSnapshot", 1, at).
+					Return(&models.BalanceSnapshot{TransactionID: 40, Balance: decimal.NewFromInt(100)}, nil).Once()
+				repositoryMock.On("SumLedger", 1, uint(40), at).
+					Return(models.LedgerSum{Total: decimal.NewFromInt(-30), LastTransactionID: 42}, nil).Once()
+			},
+			args: args{
+				walletId: 1,
+				at:       at,
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, balance models.HistoricalBalance) {
+				assert.True(t, balance.Balance.Equal(decimal.NewFromInt(70)))
+				assert.Equal(t, "EUR", balance.Currency)
+				assert.Equal(t, at, balance.At)
+			},
+		},
+		{
+			name: "Success - no snapshot sums the whole ledger",
+			initMocks: func() {
+				repositoryMock.On("GetWallet", 1).
+					Return(models.Wallet{Currency: "EUR"}, nil).Once()
+				repositoryMock.On("GetLatestSnapshot", 1, at).
+					Return(nil, nil).Once()
+				repositoryMock.On("SumLedger", 1, uint(0), at).
+					Return(models.LedgerSum{Total: decimal.NewFromInt(25), LastTransactionID: 3}, nil).Once()
+			},
+			args: args{
+				walletId: 1,
+				at:       at,
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, balance models.HistoricalBalance) {
+				assert.True(t, balance.Balance.Equal(decimal.NewFromInt(25)))
+			},
+		},
+		{
+			name: "Error - wallet not found",
+			initMocks: func() {
+				repositoryMock.On("GetWallet", 1).
+					Return(models.Wallet{}, exceptions.NewNotFoundException("wallet not found")).Once()
+			},
+			args: args{
+				walletId: 1,
+				at:       at,
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.IsType(t, &exceptions.NotFoundException{}, e)
+			},
+			assertFunc: func(t *testing.T, balance models.HistoricalBalance) {
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.initMocks()
+			service := TransactionService{
+				transactionRepository: repositoryMock,
+			}
+
+			balance, err := service.GetBalanceAt(tt.args.walletId, tt.args.at)
+			tt.assertMocks(t)
+			tt.assertError(t, err)
+			tt.assertFunc(t, balance)
+		})
+	}
+}
+
 func TestTransactionService_Debit(t *testing.T) {
 
 	repositoryMock := &mocks.RepositoryMock{}
@@ -88,6 +223,7 @@ func TestTransactionService_Debit(t *testing.T) {
 	type args struct {
 		walletId int
 		amount   decimal.Decimal
+		currency string
 	}
 
 	tests := []struct {
@@ -100,12 +236,18 @@ func TestTransactionService_Debit(t *testing.T) {
 		{
 			name: "Success - debit ok",
 			initMocks: func() {
-				repositoryMock.On("GetWallet", 1).
+				repositoryMock.On("GetWalletForUpdate", 1).
 					Return(models.Wallet{
 						Balance: decimal.NewFromInt(200),
 					}, nil).Once()
 				repositoryMock.On("UpdateWallet", mock.Anything).
 					Return(nil).Once()
+				repositoryMock.On("CreateTransaction", mock.MatchedBy(func(transaction *models.Transaction) bool {
+					return transaction.Direction == models.DirectionDebit &&
+						transaction.Amount.Equal(decimal.NewFromInt(12)) &&
+						transaction.Balance.Equal(decimal.NewFromInt(188))
+				})).Return(nil).Once()
+				expectCounterparty(repositoryMock, models.SystemAccountFunding, models.TransactionTypeDebit, "")
 			},
 			args: args{
 				walletId: 1,
@@ -121,7 +263,7 @@ func TestTransactionService_Debit(t *testing.T) {
 		{
 			name: "Error - repository response err",
 			initMocks: func() {
-				repositoryMock.On("GetWallet", 1).
+				repositoryMock.On("GetWalletForUpdate", 1).
 					Return(models.Wallet{}, errors.New("some error")).Once()
 			},
 			args: args{
@@ -138,7 +280,7 @@ func TestTransactionService_Debit(t *testing.T) {
 		{
 			name: "Error - negative balance",
 			initMocks: func() {
-				repositoryMock.On("GetWallet", 1).
+				repositoryMock.On("GetWalletForUpdate", 1).
 					Return(models.Wallet{Balance: decimal.NewFromInt(200)}, nil).Once()
 			},
 			args: args{
@@ -152,6 +294,123 @@ func TestTransactionService_Debit(t *testing.T) {
 				assert.NotNil(t, e)
 			},
 		},
+		{
+			name: "Success - debit leaves exactly zero",
+			initMocks: func() {
+				repositoryMock.On("GetWalletForUpdate", 1).
+					Return(models.Wallet{Balance: decimal.NewFromInt(12)}, nil).Once()
+				repositoryMock.On("UpdateWallet", mock.Anything).
+					Return(nil).Once()
+				repositoryMock.On("CreateTransaction", mock.MatchedBy(func(transaction *models.Transaction) bool {
+					return transaction.Balance.IsZero()
+				})).Return(nil).Once()
+				expectCounterparty(repositoryMock, models.SystemAccountFunding, models.TransactionTypeDebit, "")
+			},
+			args: args{
+				walletId: 1,
+				amount:   decimal.NewFromInt(12),
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+		},
+		{
+			name: "Success - debit within the overdraft limit",
+			initMocks: func() {
+				repositoryMock.On("GetWalletForUpdate", 1).
+					Return(models.Wallet{Balance: decimal.NewFromInt(10), OverdraftLimit: decimal.NewFromInt(50)}, nil).Once()
+				repositoryMock.On("UpdateWallet", mock.Anything).
+					Return(nil).Once()
+				repositoryMock.On("CreateTransaction", mock.MatchedBy(func(transaction *models.Transaction) bool {
+					return transaction.Balance.Equal(decimal.NewFromInt(-30))
+				})).Return(nil).Once()
+				expectCounterparty(repositoryMock, models.SystemAccountFunding, models.TransactionTypeDebit, "")
+			},
+			args: args{
+				walletId: 1,
+				amount:   decimal.NewFromInt(40),
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+		},
+		{
+			name: "Error - debit beyond the overdraft limit reports the spendable amount",
+			initMocks: func() {
+				repositoryMock.On("GetWalletForUpdate", 1).
+					Return(models.Wallet{Balance: decimal.NewFromInt(10), OverdraftLimit: decimal.NewFromInt(50)}, nil).Once()
+			},
+			args: args{
+				walletId: 1,
+				amount:   decimal.NewFromInt(61),
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				forbidden := e.(*exceptions.ForbiddenException)
+				assert.Equal(t, ErrorCodeInsufficientFunds, forbidden.Code)
+				assert.True(t, forbidden.Details["spendable"].(decimal.Decimal).Equal(decimal.NewFromInt(60)))
+			},
+		},
+		{
+			name: "Error - currency mismatch",
+			initMocks: func() {
+				repositoryMock.On("GetWalletForUpdate", 1).
+					Return(models.Wallet{Balance: decimal.NewFromInt(200), Currency: "EUR"}, nil).Once()
+			},
+			args: args{
+				walletId: 1,
+				amount:   decimal.NewFromInt(12),
+				currency: "USD",
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeCurrencyMismatch, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
+		{
+			name: "Error - amount more precise than the currency",
+			initMocks: func() {
+				repositoryMock.On("GetWalletForUpdate", 1).
+					Return(models.Wallet{Balance: decimal.NewFromInt(200), Currency: "EUR"}, nil).Once()
+			},
+			args: args{
+				walletId: 1,
+				amount:   decimal.RequireFromString("1.005"),
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeInvalidAmountPrecision, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
+		{
+			name: "Error - frozen wallet",
+			initMocks: func() {
+				repositoryMock.On("GetWalletForUpdate", 1).
+					Return(models.Wallet{Balance: decimal.NewFromInt(200), Status: models.WalletStatusFrozen}, nil).Once()
+			},
+			args: args{
+				walletId: 1,
+				amount:   decimal.NewFromInt(12),
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeWalletFrozen, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
 		{
 			name: "Error - negative amount",
 			initMocks: func() {
@@ -175,7 +434,69 @@ func TestTransactionService_Debit(t *testing.T) {
 				transactionRepository: repositoryMock,
 			}
 
-			err := service.Debit(tt.args.walletId, tt.args.amount)
+			err := service.Debit(tt.args.walletId, models.WalletRequest{Amount: tt.args.amount, Currency: tt.args.currency})
+			tt.assertMocks(t)
+			tt.assertError(t, err)
+		})
+	}
+}
+
+func TestTransactionService_DebitOptimistic(t *testing.T) {
+
+	repositoryMock := &mocks.RepositoryMock{}
+
+	tests := []struct {
+		name        string
+		initMocks   func()
+		assertMocks func(*testing.T)
+		assertError func(*testing.T, error)
+	}{
+		{
+			name: "Success - retried after a version conflict",
+			initMocks: func() {
+				repositoryMock.On("GetWalletFromDatabase", 1).
+					Return(models.Wallet{Balance: decimal.NewFromInt(200)}, nil).Twice()
+				repositoryMock.On("UpdateWallet", mock.Anything).
+					Return(exceptions.NewConflictException("conflict")).Once()
+				// only the customer wallet is versioned, the funding account entry is appended
+				repositoryMock.On("UpdateWallet", mock.Anything).
+					Return(nil).Once()
+				repositoryMock.On("CreateTransaction", mock.Anything).
+					Return(nil).Once()
+				expectCounterparty(repositoryMock, models.SystemAccountFunding, models.TransactionTypeDebit, "")
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+		},
+		{
+			name: "Error - retries exhausted",
+			initMocks: func() {
+				repositoryMock.On("GetWalletFromDatabase", 1).
+					Return(models.Wallet{Balance: decimal.NewFromInt(200)}, nil).Twice()
+				repositoryMock.On("UpdateWallet", mock.Anything).
+					Return(exceptions.NewConflictException("conflict")).Twice()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.IsType(t, &exceptions.ConflictException{}, e)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.initMocks()
+			service := TransactionService{
+				transactionRepository: repositoryMock,
+				concurrency:           concurrencyPolicy{optimistic: true, attempts: 2},
+			}
+
+			err := service.Debit(1, models.WalletRequest{Amount: decimal.NewFromInt(12)})
 			tt.assertMocks(t)
 			tt.assertError(t, err)
 		})
@@ -187,8 +508,11 @@ func TestTransactionService_Credit(t *testing.T) {
 	repositoryMock := &mocks.RepositoryMock{}
 
 	type args struct {
-		walletId int
-		amount   decimal.Decimal
+		walletId          int
+		amount            decimal.Decimal
+		currency          string
+		externalReference string
+		metadata          map[string]string
 	}
 
 	tests := []struct {
@@ -201,12 +525,18 @@ func TestTransactionService_Credit(t *testing.T) {
 		{
 			name: "Success - credit ok",
 			initMocks: func() {
-				repositoryMock.On("GetWallet", 1).
+				repositoryMock.On("GetWalletForUpdate", 1).
 					Return(models.Wallet{
 						Balance: decimal.NewFromInt(200),
 					}, nil).Once()
 				repositoryMock.On("UpdateWallet", mock.Anything).
 					Return(nil).Once()
+				repositoryMock.On("CreateTransaction", mock.MatchedBy(func(transaction *models.Transaction) bool {
+					return transaction.Direction == models.DirectionCredit &&
+						transaction.Amount.Equal(decimal.NewFromInt(12)) &&
+						transaction.Balance.Equal(decimal.NewFromInt(212))
+				})).Return(nil).Once()
+				expectCounterparty(repositoryMock, models.SystemAccountFunding, models.TransactionTypeCredit, "")
 			},
 			args: args{
 				walletId: 1,
@@ -219,10 +549,36 @@ func TestTransactionService_Credit(t *testing.T) {
 				assert.Nil(t, e)
 			},
 		},
+		{
+			name: "Success - reference and metadata stored with the entry",
+			initMocks: func() {
+				repositoryMock.On("GetWalletForUpdate", 1).
+					Return(models.Wallet{Balance: decimal.NewFromInt(200)}, nil).Once()
+				repositoryMock.On("UpdateWallet", mock.Anything).
+					Return(nil).Once()
+				repositoryMock.On("CreateTransaction", mock.MatchedBy(func(transaction *models.Transaction) bool {
+					return transaction.ExternalReference == "order-1" &&
+						transaction.Metadata["channel"] == "web"
+				})).Return(nil).Once()
+				expectCounterparty(repositoryMock, models.SystemAccountFunding, models.TransactionTypeCredit, "")
+			},
+			args: args{
+				walletId:          1,
+				amount:            decimal.NewFromInt(12),
+				externalReference: "order-1",
+				metadata:          map[string]string{"channel": "web"},
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+		},
 		{
 			name: "Error - repository response err",
 			initMocks: func() {
-				repositoryMock.On("GetWallet", 1).
+				repositoryMock.On("GetWalletForUpdate", 1).
 					Return(models.Wallet{}, errors.New("some error")).Once()
 			},
 			args: args{
@@ -236,6 +592,23 @@ func TestTransactionService_Credit(t *testing.T) {
 				assert.NotNil(t, e)
 			},
 		},
+		{
+			name: "Error - closed wallet",
+			initMocks: func() {
+				repositoryMock.On("GetWalletForUpdate", 1).
+					Return(models.Wallet{Status: models.WalletStatusClosed}, nil).Once()
+			},
+			args: args{
+				walletId: 1,
+				amount:   decimal.NewFromInt(12),
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeWalletClosed, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
 		{
 			name: "Error - negative amount",
 			initMocks: func() {
@@ -259,9 +632,198 @@ func TestTransactionService_Credit(t *testing.T) {
 				transactionRepository: repositoryMock,
 			}
 
-			err := service.Credit(tt.args.walletId, tt.args.amount)
+			err := service.Credit(tt.args.walletId, models.WalletRequest{
+				Amount:            tt.args.amount,
+				Currency:          tt.args.currency,
+				ExternalReference: tt.args.externalReference,
+				Metadata:          tt.args.metadata,
+			})
 			tt.assertMocks(t)
 			tt.assertError(t, err)
 		})
 	}
 }
+
+func TestTransactionService_GetTransactions(t *testing.T) {
+
+	repositoryMock := &mocks.RepositoryMock{}
+
+	type args struct {
+		walletId int
+	}
+
+	tests := []struct {
+		name        string
+		initMocks   func()
+		args        args
+		assertMocks func(*testing.T)
+		assertError func(*testing.T, error)
+		assertFunc  func(*testing.T, []models.Transaction)
+	}{
+		{
+			name: "Success - ledger entries returned",
+			initMocks: func() {
+				repositoryMock.On("GetWallet", 1).
+					Return(models.Wallet{}, nil).Once()
+				repositoryMock.On("GetTransactions", 1, models.TransactionFilter{Limit: 10}).
+					Return([]models.Transaction{{WalletID: 1, Direction: models.DirectionCredit}}, nil).Once()
+			},
+			args: args{
+				walletId: 1,
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+			assertFunc: func(t *testing.T, transactions []models.Transaction) {
+				assert.Len(t, transactions, 1)
+			},
+		},
+		{
+			name: "Error - wallet not found",
+			initMocks: func() {
+				repositoryMock.On("GetWallet", 1).
+					Return(models.Wallet{}, errors.New("some error")).Once()
+			},
+			args: args{
+				walletId: 1,
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.NotNil(t, e)
+			},
+			assertFunc: func(t *testing.T, transactions []models.Transaction) {
+				assert.Nil(t, transactions)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.initMocks()
+			service := TransactionService{
+				transactionRepository: repositoryMock,
+			}
+
+			transactions, err := service.GetTransactions(tt.args.walletId, models.TransactionFilter{Limit: 10})
+			tt.assertMocks(t)
+			tt.assertError(t, err)
+			tt.assertFunc(t, transactions)
+		})
+	}
+}
+
+func TestTransactionService_Reverse(t *testing.T) {
+
+	debit := models.Transaction{ID: 5, WalletID: 1, Type: models.TransactionTypeDebit, Direction: models.DirectionDebit, Amount: decimal.NewFromInt(50)}
+	charged := debit
+	charged.Currency = "EUR"
+	charged.OperationReference = "op-1"
+	fee := models.Transaction{ID: 6, WalletID: 1, Type: models.TransactionTypeFee, Direction: models.DirectionDebit,
+		Amount: decimal.NewFromInt(1), Currency: "EUR", OperationReference: "op-1"}
+	partial := decimal.NewFromInt(20)
+
+	tests := []struct {
+		name        string
+		initMocks   func(*mocks.RepositoryMock)
+		request     models.ReversalRequest
+		assertError func(*testing.T, error)
+	}{
+		{
+			name: "Success - partial refund of a debit credits the wallet",
+			initMocks: func(repositoryMock *mocks.RepositoryMock) {
+				repositoryMock.On("GetTransactionForUpdate", 5).Return(debit, nil).Once()
+				repositoryMock.On("GetReversedAmount", 5).Return(decimal.NewFromInt(10), nil).Once()
+				repositoryMock.On("GetWalletForUpdate", 1).
+					Return(models.Wallet{Model: gorm.Model{ID: 1}, Balance: decimal.NewFromInt(100)}, nil).Once()
+				repositoryMock.On("UpdateWallet", mock.Anything).Return(nil).Once()
+				repositoryMock.On("CreateTransaction", mock.MatchedBy(func(transaction *models.Transaction) bool {
+					return transaction.Type == models.TransactionTypeReversal &&
+						transaction.Direction == models.DirectionCredit &&
+						transaction.ReversedTransactionID != nil && *transaction.ReversedTransactionID == 5 &&
+						transaction.Balance.Equal(decimal.NewFromInt(120))
+				})).Return(nil).Once()
+				expectCounterparty(repositoryMock, models.SystemAccountFunding, models.TransactionTypeReversal, "")
+			},
+			request: models.ReversalRequest{Amount: &partial},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+		},
+		{
+			name: "Success - the share of the fee matching the refund is credited back",
+			initMocks: func(repositoryMock *mocks.RepositoryMock) {
+				repositoryMock.On("GetTransactionForUpdate", 5).Return(charged, nil).Once()
+				repositoryMock.On("GetReversedAmount", 5).Return(decimal.NewFromInt(10), nil).Once()
+				repositoryMock.On("GetWalletForUpdate", 1).
+					Return(models.Wallet{Model: gorm.Model{ID: 1}, Balance: decimal.NewFromInt(100), Currency: "EUR"}, nil).Once()
+				repositoryMock.On("UpdateWallet", mock.Anything).Return(nil).Twice()
+				repositoryMock.On("CreateTransaction", mock.MatchedBy(func(transaction *models.Transaction) bool {
+					return transaction.WalletID == 1 && transaction.Type == models.TransactionTypeReversal &&
+						*transaction.ReversedTransactionID == 5 && transaction.Amount.Equal(decimal.NewFromInt(20))
+				})).Return(nil).Once()
+				expectCounterparty(repositoryMock, models.SystemAccountFunding, models.TransactionTypeReversal, "EUR")
+				repositoryMock.On("GetOperationFees", 1, "op-1").Return([]models.Transaction{fee}, nil).Once()
+				repositoryMock.On("CreateTransaction", mock.MatchedBy(func(transaction *models.Transaction) bool {
+					return transaction.WalletID == 1 && transaction.Type == models.TransactionTypeReversal &&
+						transaction.Direction == models.DirectionCredit &&
+						*transaction.ReversedTransactionID == 6 &&
+						transaction.Amount.Equal(decimal.RequireFromString("0.4")) &&
+						transaction.Balance.Equal(decimal.RequireFromString("120.4"))
+				})).Return(nil).Once()
+				expectCounterparty(repositoryMock, models.SystemAccountFeeIncome, models.TransactionTypeReversal, "EUR")
+			},
+			request: models.ReversalRequest{Amount: &partial},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+		},
+		{
+			name: "Error - refund above the remaining amount",
+			initMocks: func(repositoryMock *mocks.RepositoryMock) {
+				repositoryMock.On("GetTransactionForUpdate", 5).Return(debit, nil).Once()
+				repositoryMock.On("GetReversedAmount", 5).Return(decimal.NewFromInt(40), nil).Once()
+			},
+			request: models.ReversalRequest{Amount: &partial},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeReversalExceedsRemaining, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
+		{
+			name: "Error - transaction already fully reversed",
+			initMocks: func(repositoryMock *mocks.RepositoryMock) {
+				repositoryMock.On("GetTransactionForUpdate", 5).Return(debit, nil).Once()
+				repositoryMock.On("GetReversedAmount", 5).Return(decimal.NewFromInt(50), nil).Once()
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeAlreadyReversed, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
+		{
+			name: "Error - reversals cannot be reversed",
+			initMocks: func(repositoryMock *mocks.RepositoryMock) {
+				repositoryMock.On("GetTransactionForUpdate", 5).
+					Return(models.Transaction{ID: 5, Type: models.TransactionTypeReversal}, nil).Once()
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeNotReversible, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repositoryMock := &mocks.RepositoryMock{}
+			tt.initMocks(repositoryMock)
+			service := TransactionService{
+				transactionRepository: repositoryMock,
+			}
+
+			_, err := service.Reverse(5, tt.request)
+			repositoryMock.AssertExpectations(t)
+			tt.assertError(t, err)
+		})
+	}
+}
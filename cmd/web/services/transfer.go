@@ -0,0 +1,107 @@
+package services
+
+import (
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"github.com/wallet-api/exceptions"
+)
+
+type ITransferService interface {
+	Transfer(request models.TransferRequest) (string, error)
+}
+
+type TransferService struct {
+	transactionRepository repositories.ITransactionRepository
+	concurrency           concurrencyPolicy
+	velocity              velocityPolicy
+	fees                  feePolicy
+}
+
+const ErrorCodeSelfTransfer string = "a wallet cannot transfer to itself"
+
+// Transfer moves the amount between two wallets in a single database transaction and returns
+// the operation reference shared by both ledger entries
+func (service *TransferService) Transfer(request models.TransferRequest) (string, error) {
+	var operationReference string
+	err := service.concurrency.run(service.transactionRepository, func(repository repositories.ITransactionRepository) error {
+		var err error
+		operationReference, err = transferFunds(service.concurrency, service.velocity, service.fees, repository, request)
+		return err
+	})
+
+	return operationReference, err
+}
+
+// transferFunds posts both legs of a transfer within the running database transaction, the fee is
+// charged to the source wallet
+func transferFunds(policy concurrencyPolicy, velocity velocityPolicy, fees feePolicy, repository repositories.ITransactionRepository, request models.TransferRequest) (string, error) {
+	sourceWalletId, destinationWalletId, amount := request.SourceWalletID, request.DestinationWalletID, request.Amount
+	if !amount.IsPositive() {
+		return "", exceptions.NewForbiddenException("operation not allowed: %s", ErrorCodeInvalidParamsPositive)
+	}
+	if sourceWalletId == destinationWalletId {
+		return "", exceptions.NewForbiddenException("operation not allowed: %s", ErrorCodeSelfTransfer)
+	}
+
+	wallets, err := policy.lockWallets(repository, sourceWalletId, destinationWalletId)
+	if err != nil {
+		return "", err
+	}
+	source, destination := wallets[sourceWalletId], wallets[destinationWalletId]
+
+	if err := checkActive(*source); err != nil {
+		return "", err
+	}
+	if err := checkActive(*destination); err != nil {
+		return "", err
+	}
+	if source.Currency != destination.Currency {
+		return "", exceptions.NewForbiddenExceptionWithCode(ErrorCodeCurrencyMismatch, "operation not allowed: cannot transfer between %s and %s wallets", source.Currency, destination.Currency)
+	}
+	if err := checkAmount(*source, amount, request.Currency); err != nil {
+		return "", err
+	}
+	fee := fees.fee(models.FeeOperationTransfer, *source, amount)
+	if err := checkDebit(*source, amount.Add(fee)); err != nil {
+		return "", err
+	}
+	if err := velocity.check(repository, *source, amount); err != nil {
+		return "", err
+	}
+
+	debit, err := postEntry(repository, source, models.Transaction{
+		Type:      models.TransactionTypeTransfer,
+		Direction: models.DirectionDebit,
+		Amount:    amount,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	credit, err := postEntry(repository, destination, models.Transaction{
+		Type:               models.TransactionTypeTransfer,
+		Direction:          models.DirectionCredit,
+		Amount:             amount,
+		OperationReference: debit.OperationReference,
+	})
+	if err != nil {
+		return "", err
+	}
+	err = newJournal(debit.Type, debit.OperationReference).
+		wallet(*source, debit).
+		wallet(*destination, credit).
+		post(repository)
+	if err != nil {
+		return "", err
+	}
+	return debit.OperationReference, fees.charge(repository, source, fee, debit.OperationReference)
+}
+
+func NewTransferService() ITransferService {
+	return &TransferService{
+		transactionRepository: repositories.NewTransactionRepository(),
+		concurrency:           newConcurrencyPolicy(),
+		velocity:              newVelocityPolicy(),
+		fees:                  newFeePolicy(),
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"testing"
+)
+
+func TestTransferService_Transfer(t *testing.T) {
+
+	type args struct {
+		source      int
+		destination int
+		amount      decimal.Decimal
+	}
+
+	tests := []struct {
+		name        string
+		initMocks   func(*mocks.RepositoryMock)
+		args        args
+		assertMocks func(*testing.T, *mocks.RepositoryMock)
+		assertError func(*testing.T, error)
+	}{
+		{
+			name: "Success - wallets locked in ascending order",
+			initMocks: func(repositoryMock *mocks.RepositoryMock) {
+				repositoryMock.On("GetWalletForUpdate", 1).
+					Return(models.Wallet{Model: gorm.Model{ID: 1}, Balance: decimal.NewFromInt(10)}, nil).Once()
+				repositoryMock.On("GetWalletForUpdate", 2).
+					Return(models.Wallet{Model: gorm.Model{ID: 2}, Balance: decimal.NewFromInt(100)}, nil).Once()
+				repositoryMock.On("UpdateWallet", mock.Anything).
+					Return(nil).Twice()
+				repositoryMock.On("CreateTransaction", mock.MatchedBy(func(transaction *models.Transaction) bool {
+					return transaction.WalletID == 2 && transaction.Direction == models.DirectionDebit &&
+						transaction.Balance.Equal(decimal.NewFromInt(70))
+				})).Return(nil).Once()
+				repositoryMock.On("CreateTransaction", mock.MatchedBy(func(transaction *models.Transaction) bool {
+					return transaction.WalletID == 1 && transaction.Direction == models.DirectionCredit &&
+						transaction.Balance.Equal(decimal.NewFromInt(40))
+				})).Return(nil).Once()
+				repositoryMock.On("CreateJournalEntry", balancedEntry(models.TransactionTypeTransfer, models.AccountWallets, models.AccountWallets)).Return(nil).Once()
+			},
+			args: args{
+				source:      2,
+				destination: 1,
+				amount:      decimal.NewFromInt(30),
+			},
+			assertMocks: func(t *testing.T, repositoryMock *mocks.RepositoryMock) {
+				repositoryMock.AssertExpectations(t)
+				assert.Equal(t, 1, repositoryMock.Calls[0].Arguments.Get(0))
+				assert.Equal(t, 2, repositoryMock.Calls[1].Arguments.Get(0))
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+		},
+		{
+			name: "Error - insufficient balance",
+			initMocks: func(repositoryMock *mocks.RepositoryMock) {
+				repositoryMock.On("GetWalletForUpdate", 1).
+					Return(models.Wallet{Model: gorm.Model{ID: 1}, Balance: decimal.NewFromInt(10)}, nil).Once()
+				repositoryMock.On("GetWalletForUpdate", 2).
+					Return(models.Wallet{Model: gorm.Model{ID: 2}, Balance: decimal.NewFromInt(100)}, nil).Once()
+			},
+			args: args{
+				source:      1,
+				destination: 2,
+				amount:      decimal.NewFromInt(30),
+			},
+			assertMocks: func(t *testing.T, repositoryMock *mocks.RepositoryMock) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.NotNil(t, e)
+			},
+		},
+		{
+			name: "Error - wallets in different currencies",
+			initMocks: func(repositoryMock *mocks.RepositoryMock) {
+				repositoryMock.On("GetWalletForUpdate", 1).
+					Return(models.Wallet{Model: gorm.Model{ID: 1}, Balance: decimal.NewFromInt(100), Currency: "EUR"}, nil).Once()
+				repositoryMock.On("GetWalletForUpdate", 2).
+					Return(models.Wallet{Model: gorm.Model{ID: 2}, Balance: decimal.NewFromInt(100), Currency: "USD"}, nil).Once()
+			},
+			args: args{
+				source:      1,
+				destination: 2,
+				amount:      decimal.NewFromInt(30),
+			},
+			assertMocks: func(t *testing.T, repositoryMock *mocks.RepositoryMock) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeCurrencyMismatch, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
+		{
+			name:      "Error - self transfer",
+			initMocks: func(repositoryMock *mocks.RepositoryMock) {},
+			args: args{
+				source:      1,
+				destination: 1,
+				amount:      decimal.NewFromInt(30),
+			},
+			assertMocks: func(t *testing.T, repositoryMock *mocks.RepositoryMock) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.NotNil(t, e)
+			},
+		},
+		{
+			name:      "Error - negative amount",
+			initMocks: func(repositoryMock *mocks.RepositoryMock) {},
+			args: args{
+				source:      1,
+				destination: 2,
+				amount:      decimal.NewFromInt(-1),
+			},
+			assertMocks: func(t *testing.T, repositoryMock *mocks.RepositoryMock) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.NotNil(t, e)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repositoryMock := &mocks.RepositoryMock{}
+			tt.initMocks(repositoryMock)
+			service := TransferService{
+				transactionRepository: repositoryMock,
+			}
+
+			_, err := service.Transfer(models.TransferRequest{
+				SourceWalletID:      tt.args.source,
+				DestinationWalletID: tt.args.destination,
+				Amount:              tt.args.amount,
+			})
+			tt.assertMocks(t, repositoryMock)
+			tt.assertError(t, err)
+		})
+	}
+}
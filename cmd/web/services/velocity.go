@@ -0,0 +1,125 @@
+package services
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"github.com/wallet-api/exceptions"
+	"time"
+)
+
+const ErrorCodeVelocityLimitExceeded string = "velocity_limit_exceeded"
+
+// velocityPolicy caps how much and how often a wallet is debited per period. The zero value is disabled.
+type velocityPolicy struct {
+	enabled  bool
+	defaults []models.VelocityLimit
+}
+
+// limits merges the wallet overrides over the defaults, an override replaces the default of its period
+func (policy velocityPolicy) limits(repository repositories.ITransactionRepository, walletId int) ([]models.VelocityLimit, error) {
+	overrides, err := repository.GetVelocityLimits(walletId)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := append([]models.VelocityLimit{}, overrides...)
+	for _, limit := range policy.defaults {
+		overridden := false
+		for _, override := range overrides {
+			overridden = overridden || override.Period == limit.Period
+		}
+		if !overridden {
+			limits = append(limits, limit)
+		}
+	}
+	return limits, nil
+}
+
+// check verifies the debit fits every limit of the wallet and counts it in their windows, which
+// only happens when the running transaction commits. It must run in the transaction that loaded the
+// wallet, the lock or the version check of the wallet keeps concurrent debits from both passing
+func (policy velocityPolicy) check(repository repositories.ITransactionRepository, wallet models.Wallet, amount decimal.Decimal) error {
+	if !policy.enabled {
+		return nil
+	}
+
+	walletId := int(wallet.ID)
+	limits, err := policy.limits(repository, walletId)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	windows := make([]models.VelocityWindow, 0, len(limits))
+	for _, limit := range limits {
+		if !limit.MaxAmount.Valid && limit.MaxCount == nil {
+			continue
+		}
+
+		window := models.NewVelocityWindow(limit.Period, now)
+		usage, err := repository.GetVelocityUsage(walletId, window)
+		if err != nil {
+			return err
+		}
+		if limit.MaxCount != nil && usage.Count+1 > *limit.MaxCount {
+			return velocityLimitExceeded(wallet, limit, window, usage)
+		}
+		if limit.MaxAmount.Valid && usage.Amount.Add(amount).GreaterThan(limit.MaxAmount.Decimal) {
+			return velocityLimitExceeded(wallet, limit, window, usage)
+		}
+		windows = append(windows, window)
+	}
+
+	for _, window := range windows {
+		repository.AddVelocityUsage(walletId, window, amount)
+	}
+	return nil
+}
+
+func velocityLimitExceeded(wallet models.Wallet, limit models.VelocityLimit, window models.VelocityWindow, usage models.VelocityUsage) error {
+	details := map[string]interface{}{
+		"period":      limit.Period,
+		"used_amount": usage.Amount,
+		"used_count":  usage.Count,
+		"currency":    wallet.Currency,
+		"resets_at":   window.End,
+	}
+	if limit.MaxAmount.Valid {
+		details["max_amount"] = limit.MaxAmount.Decimal
+	}
+	if limit.MaxCount != nil {
+		details["max_count"] = *limit.MaxCount
+	}
+	return exceptions.NewForbiddenExceptionWithDetails(ErrorCodeVelocityLimitExceeded, details,
+		"operation not allowed: the wallet reached its %s debit limit", limit.Period)
+}
+
+// newVelocityPolicy reads the default limits, a list of period, max_amount and max_count entries
+func newVelocityPolicy() velocityPolicy {
+	var entries []struct {
+		Period    string `mapstructure:"period"`
+		MaxAmount string `mapstructure:"max_amount"`
+		MaxCount  *int   `mapstructure:"max_count"`
+	}
+	if err := viper.UnmarshalKey("velocity.limits", &entries); err != nil {
+		logrus.Errorf("couldn't read the velocity limits: %v", err)
+	}
+
+	policy := velocityPolicy{enabled: viper.GetBool("velocity.enabled")}
+	for _, entry := range entries {
+		limit := models.VelocityLimit{Period: entry.Period, MaxCount: entry.MaxCount}
+		if entry.MaxAmount != "" {
+			amount, err := decimal.NewFromString(entry.MaxAmount)
+			if err != nil {
+				logrus.Errorf("couldn't read the %s velocity limit amount: %v", entry.Period, err)
+				continue
+			}
+			limit.MaxAmount = decimal.NullDecimal{Decimal: amount, Valid: true}
+		}
+		policy.defaults = append(policy.defaults, limit)
+	}
+	return policy
+}
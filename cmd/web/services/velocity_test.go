@@ -0,0 +1,177 @@
+package services
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"testing"
+	"time"
+)
+
+func TestTransactionService_DebitVelocity(t *testing.T) {
+
+	repositoryMock := &mocks.RepositoryMock{}
+	maxCount := 2
+	velocity := velocityPolicy{
+		enabled: true,
+		defaults: []models.VelocityLimit{
+			{Period: models.VelocityPeriodHour, MaxCount: &maxCount},
+			{Period: models.VelocityPeriodDay, MaxAmount: decimal.NullDecimal{Decimal: decimal.NewFromInt(100), Valid: true}},
+		},
+	}
+	wallet := models.Wallet{Balance: decimal.NewFromInt(1000), Currency: "EUR"}
+	wallet.ID = 1
+
+	period := func(period string) interface{} {
+		return mock.MatchedBy(func(window models.VelocityWindow) bool {
+			return window.Period == period
+		})
+	}
+
+	type args struct {
+		amount decimal.Decimal
+	}
+
+	tests := []struct {
+		name        string
+		initMocks   func()
+		args        args
+		assertMocks func(*testing.T)
+		assertError func(*testing.T, error)
+	}{
+		{
+			name: "Success - within limits and counted",
+			initMocks: func() {
+				repositoryMock.On("GetWalletForUpdate", 1).Return(wallet, nil).Once()
+				repositoryMock.On("GetVelocityLimits", 1).Return([]models.VelocityLimit{}, nil).Once()
+				repositoryMock.On("GetVelocityUsage", 1, period(models.VelocityPeriodHour)).
+					Return(models.VelocityUsage{Count: 1}, nil).Once()
+				repositoryMock.On("GetVelocityUsage", 1, period(models.VelocityPeriodDay)).
+					Return(models.VelocityUsage{Amount: decimal.NewFromInt(60)}, nil).Once()
+				repositoryMock.On("AddVelocityUsage", 1, period(models.VelocityPeriodHour), decimal.NewFromInt(40)).Once()
+				repositoryMock.On("AddVelocityUsage", 1, period(models.VelocityPeriodDay), decimal.NewFromInt(40)).Once()
+				repositoryMock.On("UpdateWallet", mock.Anything).Return(nil).Once()
+				repositoryMock.On("CreateTransaction", mock.Anything).Return(nil).Once()
+				expectCounterparty(repositoryMock, models.SystemAccountFunding, models.TransactionTypeDebit, "EUR")
+			},
+			args: args{
+				amount: decimal.NewFromInt(40),
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+		},
+		{
+			name: "Error - hourly count reached",
+			initMocks: func() {
+				repositoryMock.On("GetWalletForUpdate", 1).Return(wallet, nil).Once()
+				repositoryMock.On("GetVelocityLimits", 1).Return([]models.VelocityLimit{}, nil).Once()
+				repositoryMock.On("GetVelocityUsage", 1, period(models.VelocityPeriodHour)).
+					Return(models.VelocityUsage{Count: 2}, nil).Once()
+			},
+			args: args{
+				amount: decimal.NewFromInt(1),
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				forbidden := e.(*exceptions.ForbiddenException)
+				assert.Equal(t, ErrorCodeVelocityLimitExceeded, forbidden.Code)
+				assert.Equal(t, models.VelocityPeriodHour, forbidden.Details["period"])
+				assert.Equal(t, 2, forbidden.Details["max_count"])
+			},
+		},
+		{
+			name: "Error - wallet override replaces the daily default",
+			initMocks: func() {
+				repositoryMock.On("GetWalletForUpdate", 1).Return(wallet, nil).Once()
+				repositoryMock.On("GetVelocityLimits", 1).Return([]models.VelocityLimit{
+					{WalletID: 1, Period: models.VelocityPeriodDay, MaxAmount: decimal.NullDecimal{Decimal: decimal.NewFromInt(30), Valid: true}},
+				}, nil).Once()
+				repositoryMock.On("GetVelocityUsage", 1, period(models.VelocityPeriodDay)).
+					Return(models.VelocityUsage{Amount: decimal.NewFromInt(0)}, nil).Once()
+			},
+			args: args{
+				amount: decimal.NewFromInt(40),
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, e error) {
+				forbidden := e.(*exceptions.ForbiddenException)
+				assert.Equal(t, ErrorCodeVelocityLimitExceeded, forbidden.Code)
+				assert.True(t, decimal.NewFromInt(30).Equal(forbidden.Details["max_amount"].(decimal.Decimal)))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.initMocks()
+			service := TransactionService{
+				transactionRepository: repositoryMock,
+				velocity:              velocity,
+			}
+
+			err := service.Debit(1, models.WalletRequest{Amount: tt.args.amount})
+			tt.assertMocks(t)
+			tt.assertError(t, err)
+		})
+	}
+}
+
+// the daily limit of 100 is already used up to 90 in every case
+func exhaustedVelocity(repositoryMock *mocks.RepositoryMock, walletId int) velocityPolicy {
+	repositoryMock.On("GetVelocityLimits", walletId).Return([]models.VelocityLimit{}, nil).Once()
+	repositoryMock.On("GetVelocityUsage", walletId, mock.Anything).Return(models.VelocityUsage{Amount: decimal.NewFromInt(90)}, nil).Once()
+	return velocityPolicy{
+		enabled:  true,
+		defaults: []models.VelocityLimit{{Period: models.VelocityPeriodDay, MaxAmount: decimal.NullDecimal{Decimal: decimal.NewFromInt(100), Valid: true}}},
+	}
+}
+
+func TestHoldService_CaptureVelocity(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	wallet := models.Wallet{Balance: decimal.NewFromInt(100), HeldBalance: decimal.NewFromInt(50), Currency: "EUR"}
+	wallet.ID = 1
+	hold := models.Hold{ID: 7, WalletID: 1, Amount: decimal.NewFromInt(50), Status: models.HoldStatusActive, ExpiresAt: time.Now().Add(time.Hour)}
+	repositoryMock.On("GetWalletForUpdate", 1).Return(wallet, nil).Once()
+	repositoryMock.On("GetHoldForUpdate", 7).Return(hold, nil).Once()
+	service := HoldService{
+		transactionRepository: repositoryMock,
+		velocity:              exhaustedVelocity(repositoryMock, 1),
+	}
+
+	_, err := service.Capture(1, 7, models.CaptureRequest{})
+	repositoryMock.AssertExpectations(t)
+	repositoryMock.AssertNotCalled(t, "CreateTransaction", mock.Anything)
+	assert.Equal(t, ErrorCodeVelocityLimitExceeded, err.(*exceptions.ForbiddenException).Code)
+}
+
+func TestFXService_ConvertVelocity(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	quote := models.FXQuote{Reference: "quote-1", SourceCurrency: "EUR", TargetCurrency: "USD", SourceAmount: decimal.NewFromInt(20),
+		TargetAmount: decimal.RequireFromString("21.71"), ExpiresAt: time.Now().Add(time.Minute)}
+	source := models.Wallet{Balance: decimal.NewFromInt(100), Currency: "EUR"}
+	source.ID = 1
+	destination := models.Wallet{Currency: "USD"}
+	destination.ID = 2
+	repositoryMock.On("GetQuote", "quote-1").Return(quote, nil).Once()
+	repositoryMock.On("GetWalletForUpdate", 1).Return(source, nil).Once()
+	repositoryMock.On("GetWalletForUpdate", 2).Return(destination, nil).Once()
+	service := FXService{
+		transactionRepository: repositoryMock,
+		velocity:              exhaustedVelocity(repositoryMock, 1),
+	}
+
+	_, err := service.Convert(models.FXConversionRequest{QuoteID: "quote-1", SourceWalletID: 1, DestinationWalletID: 2})
+	repositoryMock.AssertExpectations(t)
+	repositoryMock.AssertNotCalled(t, "RedeemQuote", mock.Anything, mock.Anything)
+	assert.Equal(t, ErrorCodeVelocityLimitExceeded, err.(*exceptions.ForbiddenException).Code)
+}
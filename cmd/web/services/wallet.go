@@ -0,0 +1,259 @@
+package services
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"github.com/wallet-api/exceptions"
+	"strconv"
+	"time"
+)
+
+type IWalletService interface {
+	Create(request models.CreateWalletRequest) (models.Wallet, error)
+	Get(walletId int) (models.Wallet, error)
+	Freeze(walletId int) (models.Wallet, error)
+	Unfreeze(walletId int) (models.Wallet, error)
+	Close(walletId int) (models.Wallet, error)
+	SetOverdraftLimit(walletId int, request models.OverdraftLimitRequest) (models.Wallet, error)
+	GetOverdraftLimitChanges(walletId int) ([]models.OverdraftLimitChange, error)
+	SetVelocityLimits(walletId int, request models.VelocityLimitsRequest) ([]models.VelocityLimit, error)
+	GetVelocityLimits(walletId int) ([]models.VelocityLimit, error)
+}
+
+type WalletService struct {
+	transactionRepository repositories.ITransactionRepository
+	concurrency           concurrencyPolicy
+	velocity              velocityPolicy
+}
+
+const (
+	ErrorCodeInvalidStatusTransition string = "invalid_status_transition"
+	ErrorCodeBalanceNotZero          string = "balance_not_zero"
+	ErrorCodeActiveHolds             string = "active_holds"
+	ErrorCodeUnsupportedCurrency     string = "unsupported_currency"
+)
+
+const ErrorCodeOverdraftLimitNegative string = "the overdraft limit cannot be negative"
+const ErrorCodeInvalidVelocityLimit string = "invalid_velocity_limit"
+
+func (service *WalletService) Create(request models.CreateWalletRequest) (models.Wallet, error) {
+	if _, ok := models.MinorUnits(request.Currency); !ok {
+		return models.Wallet{}, exceptions.NewForbiddenExceptionWithCode(ErrorCodeUnsupportedCurrency, "operation not allowed: currency %s is not supported", request.Currency)
+	}
+
+	product := request.Product
+	if product == "" {
+		product = models.WalletProductStandard
+	}
+
+	if request.OwnerID != nil {
+		owner, err := service.transactionRepository.GetOwner(int(*request.OwnerID))
+		if err != nil {
+			return models.Wallet{}, err
+		}
+		if owner.Status != models.OwnerStatusActive {
+			return models.Wallet{}, exceptions.NewForbiddenExceptionWithCode(ErrorCodeOwnerNotActive, "operation not allowed: the owner is %s", owner.Status)
+		}
+	}
+
+	wallet := models.Wallet{
+		Balance:  decimal.Zero,
+		Currency: request.Currency,
+		Status:   models.WalletStatusActive,
+		Product:  product,
+		OwnerID:  request.OwnerID,
+	}
+	err := service.concurrency.run(service.transactionRepository, func(repository repositories.ITransactionRepository) error {
+		return repository.CreateWallet(&wallet)
+	})
+
+	return wallet, err
+}
+
+func (service *WalletService) Get(walletId int) (models.Wallet, error) {
+	var wallet models.Wallet
+	var err error
+	if service.concurrency.eventSourced {
+		wallet, err = projectedWallet(service.transactionRepository, walletId)
+	} else {
+		wallet, err = service.transactionRepository.GetWalletFromDatabase(walletId)
+	}
+	if err != nil || !wallet.IsSystem() {
+		return wallet, err
+	}
+
+	// the balance of system accounts only exists as the sum of their ledger
+	wallet.Balance, err = balanceAt(service.transactionRepository, walletId, time.Now())
+	if err != nil {
+		return models.Wallet{}, err
+	}
+	return wallet, nil
+}
+
+func (service *WalletService) Freeze(walletId int) (models.Wallet, error) {
+	return service.changeStatus(walletId, models.WalletStatusFrozen, func(wallet models.Wallet) error {
+		return checkTransition(wallet, models.WalletStatusActive)
+	})
+}
+
+func (service *WalletService) Unfreeze(walletId int) (models.Wallet, error) {
+	return service.changeStatus(walletId, models.WalletStatusActive, func(wallet models.Wallet) error {
+		return checkTransition(wallet, models.WalletStatusFrozen)
+	})
+}
+
+// Close is only allowed once the wallet has no funds and no holds left, the holds have to be
+// captured or voided first since a closed wallet can do neither
+func (service *WalletService) Close(walletId int) (models.Wallet, error) {
+	return service.changeStatus(walletId, models.WalletStatusClosed, func(wallet models.Wallet) error {
+		if err := checkTransition(wallet, models.WalletStatusActive, models.WalletStatusFrozen); err != nil {
+			return err
+		}
+		if wallet.HeldBalance.IsPositive() {
+			return exceptions.NewForbiddenExceptionWithCode(ErrorCodeActiveHolds, "operation not allowed: %s of the wallet is held by active holds", wallet.HeldBalance.String())
+		}
+		if !wallet.Balance.IsZero() {
+			return exceptions.NewForbiddenExceptionWithCode(ErrorCodeBalanceNotZero, "operation not allowed: only wallets with a zero balance can be closed")
+		}
+		return nil
+	})
+}
+
+// SetOverdraftLimit changes how far below zero the wallet balance may go, recording who changed it
+// in the audit trail
+func (service *WalletService) SetOverdraftLimit(walletId int, request models.OverdraftLimitRequest) (models.Wallet, error) {
+	if request.Limit.IsNegative() {
+		return models.Wallet{}, exceptions.NewForbiddenException("operation not allowed: %s", ErrorCodeOverdraftLimitNegative)
+	}
+
+	var wallet models.Wallet
+	err := service.concurrency.run(service.transactionRepository, func(repository repositories.ITransactionRepository) error {
+		var err error
+		wallet, err = service.concurrency.loadWallet(repository, walletId)
+		if err != nil {
+			return err
+		}
+		if wallet.IsSystem() {
+			return exceptions.NewForbiddenExceptionWithCode(ErrorCodeSystemAccount, "operation not allowed: wallet %s is a system account", strconv.Itoa(walletId))
+		}
+		if err := checkAmount(wallet, request.Limit, ""); err != nil {
+			return err
+		}
+
+		change := models.OverdraftLimitChange{
+			WalletID:      wallet.ID,
+			PreviousLimit: wallet.OverdraftLimit,
+			NewLimit:      request.Limit,
+			ChangedBy:     request.ChangedBy,
+			Reason:        request.Reason,
+		}
+		wallet.OverdraftLimit = request.Limit
+		if err := repository.UpdateWallet(&wallet); err != nil {
+			return err
+		}
+		return repository.CreateOverdraftLimitChange(&change)
+	})
+
+	return wallet, err
+}
+
+func (service *WalletService) GetOverdraftLimitChanges(walletId int) ([]models.OverdraftLimitChange, error) {
+	if _, err := service.transactionRepository.GetWallet(walletId); err != nil {
+		return nil, err
+	}
+
+	return service.transactionRepository.GetOverdraftLimitChanges(walletId)
+}
+
+// SetVelocityLimits replaces the velocity limit overrides of the wallet and returns its effective limits
+func (service *WalletService) SetVelocityLimits(walletId int, request models.VelocityLimitsRequest) ([]models.VelocityLimit, error) {
+	var limits []models.VelocityLimit
+	err := service.concurrency.run(service.transactionRepository, func(repository repositories.ITransactionRepository) error {
+		wallet, err := service.concurrency.loadWallet(repository, walletId)
+		if err != nil {
+			return err
+		}
+
+		overrides := make([]models.VelocityLimit, 0, len(request.Limits))
+		periods := map[string]bool{}
+		for _, limitRequest := range request.Limits {
+			if periods[limitRequest.Period] {
+				return exceptions.NewForbiddenExceptionWithCode(ErrorCodeInvalidVelocityLimit, "operation not allowed: the %s limit is repeated", limitRequest.Period)
+			}
+			periods[limitRequest.Period] = true
+
+			limit := models.VelocityLimit{WalletID: wallet.ID, Period: limitRequest.Period, MaxCount: limitRequest.MaxCount}
+			if limitRequest.MaxCount != nil && *limitRequest.MaxCount < 0 {
+				return exceptions.NewForbiddenExceptionWithCode(ErrorCodeInvalidVelocityLimit, "operation not allowed: the %s count limit cannot be negative", limitRequest.Period)
+			}
+			if limitRequest.MaxAmount != nil {
+				if limitRequest.MaxAmount.IsNegative() {
+					return exceptions.NewForbiddenExceptionWithCode(ErrorCodeInvalidVelocityLimit, "operation not allowed: the %s amount limit cannot be negative", limitRequest.Period)
+				}
+				if err := checkAmount(wallet, *limitRequest.MaxAmount, ""); err != nil {
+					return err
+				}
+				limit.MaxAmount = decimal.NullDecimal{Decimal: *limitRequest.MaxAmount, Valid: true}
+			}
+			overrides = append(overrides, limit)
+		}
+
+		if err := repository.ReplaceVelocityLimits(walletId, overrides); err != nil {
+			return err
+		}
+		limits, err = service.velocity.limits(repository, walletId)
+		return err
+	})
+
+	return limits, err
+}
+
+// GetVelocityLimits returns the limits applied to the wallet, its overrides and the defaults they don't replace
+func (service *WalletService) GetVelocityLimits(walletId int) ([]models.VelocityLimit, error) {
+	if _, err := service.transactionRepository.GetWallet(walletId); err != nil {
+		return nil, err
+	}
+
+	return service.velocity.limits(service.transactionRepository, walletId)
+}
+
+func (service *WalletService) changeStatus(walletId int, status string, check func(wallet models.Wallet) error) (models.Wallet, error) {
+	var wallet models.Wallet
+	err := service.concurrency.run(service.transactionRepository, func(repository repositories.ITransactionRepository) error {
+		var err error
+		wallet, err = service.concurrency.loadWallet(repository, walletId)
+		if err != nil {
+			return err
+		}
+		if wallet.IsSystem() {
+			return exceptions.NewForbiddenExceptionWithCode(ErrorCodeSystemAccount, "operation not allowed: wallet %s is a system account", strconv.Itoa(walletId))
+		}
+		if err := check(wallet); err != nil {
+			return err
+		}
+
+		wallet.Status = status
+		return repository.UpdateWallet(&wallet)
+	})
+
+	return wallet, err
+}
+
+// checkTransition verifies the wallet is currently in one of the given statuses
+func checkTransition(wallet models.Wallet, from ...string) error {
+	for _, status := range from {
+		if wallet.Status == status {
+			return nil
+		}
+	}
+	return exceptions.NewForbiddenExceptionWithCode(ErrorCodeInvalidStatusTransition, "operation not allowed: the wallet is %s", wallet.Status)
+}
+
+func NewWalletService() IWalletService {
+	return &WalletService{
+		transactionRepository: repositories.NewTransactionRepository(),
+		concurrency:           newConcurrencyPolicy(),
+		velocity:              newVelocityPolicy(),
+	}
+}
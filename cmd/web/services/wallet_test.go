@@ -0,0 +1,202 @@
+package services
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"testing"
+)
+
+func TestWalletService_Create(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	repositoryMock.On("CreateWallet", mock.MatchedBy(func(wallet *models.Wallet) bool {
+		return wallet.Status == models.WalletStatusActive && wallet.Balance.IsZero()
+	})).Return(nil).Once()
+
+	service := WalletService{
+		transactionRepository: repositoryMock,
+	}
+
+	wallet, err := service.Create(models.CreateWalletRequest{Currency: "ARS"})
+	repositoryMock.AssertExpectations(t)
+	assert.Nil(t, err)
+	assert.Equal(t, models.WalletStatusActive, wallet.Status)
+	assert.Equal(t, "ARS", wallet.Currency)
+
+	_, err = service.Create(models.CreateWalletRequest{Currency: "XXX"})
+	assert.Equal(t, ErrorCodeUnsupportedCurrency, err.(*exceptions.ForbiddenException).Code)
+}
+
+func TestWalletService_CreateWithOwner(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	active := uint(1)
+	suspended := uint(2)
+	repositoryMock.On("GetOwner", 1).Return(models.Owner{ID: 1, Status: models.OwnerStatusActive}, nil).Once()
+	repositoryMock.On("GetOwner", 2).Return(models.Owner{ID: 2, Status: models.OwnerStatusSuspended}, nil).Once()
+	repositoryMock.On("CreateWallet", mock.MatchedBy(func(wallet *models.Wallet) bool {
+		return wallet.OwnerID != nil && *wallet.OwnerID == 1
+	})).Return(nil).Once()
+
+	service := WalletService{
+		transactionRepository: repositoryMock,
+	}
+
+	wallet, err := service.Create(models.CreateWalletRequest{Currency: "EUR", OwnerID: &active})
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), *wallet.OwnerID)
+
+	_, err = service.Create(models.CreateWalletRequest{Currency: "EUR", OwnerID: &suspended})
+	assert.Equal(t, ErrorCodeOwnerNotActive, err.(*exceptions.ForbiddenException).Code)
+	repositoryMock.AssertExpectations(t)
+}
+
+func TestWalletService_ChangeStatus(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		wallet      models.Wallet
+		transition  func(*WalletService) (models.Wallet, error)
+		updated     bool
+		assertError func(*testing.T, error)
+	}{
+		{
+			name:   "Success - freeze active wallet",
+			wallet: models.Wallet{Status: models.WalletStatusActive},
+			transition: func(service *WalletService) (models.Wallet, error) {
+				return service.Freeze(1)
+			},
+			updated: true,
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+		},
+		{
+			name:   "Success - unfreeze frozen wallet",
+			wallet: models.Wallet{Status: models.WalletStatusFrozen},
+			transition: func(service *WalletService) (models.Wallet, error) {
+				return service.Unfreeze(1)
+			},
+			updated: true,
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+		},
+		{
+			name:   "Success - close empty wallet",
+			wallet: models.Wallet{Status: models.WalletStatusFrozen, Balance: decimal.Zero},
+			transition: func(service *WalletService) (models.Wallet, error) {
+				return service.Close(1)
+			},
+			updated: true,
+			assertError: func(t *testing.T, e error) {
+				assert.Nil(t, e)
+			},
+		},
+		{
+			name:   "Error - close wallet with funds",
+			wallet: models.Wallet{Status: models.WalletStatusActive, Balance: decimal.NewFromInt(1)},
+			transition: func(service *WalletService) (models.Wallet, error) {
+				return service.Close(1)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeBalanceNotZero, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
+		{
+			name:   "Error - close wallet with active holds",
+			wallet: models.Wallet{Status: models.WalletStatusActive, Balance: decimal.Zero, HeldBalance: decimal.NewFromInt(5)},
+			transition: func(service *WalletService) (models.Wallet, error) {
+				return service.Close(1)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeActiveHolds, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
+		{
+			name:   "Error - freeze closed wallet",
+			wallet: models.Wallet{Status: models.WalletStatusClosed},
+			transition: func(service *WalletService) (models.Wallet, error) {
+				return service.Freeze(1)
+			},
+			assertError: func(t *testing.T, e error) {
+				assert.Equal(t, ErrorCodeInvalidStatusTransition, e.(*exceptions.ForbiddenException).Code)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repositoryMock := &mocks.RepositoryMock{}
+			repositoryMock.On("GetWalletForUpdate", 1).Return(tt.wallet, nil).Once()
+			if tt.updated {
+				repositoryMock.On("UpdateWallet", mock.Anything).Return(nil).Once()
+			}
+			service := &WalletService{
+				transactionRepository: repositoryMock,
+			}
+
+			_, err := tt.transition(service)
+			repositoryMock.AssertExpectations(t)
+			tt.assertError(t, err)
+		})
+	}
+}
+
+func TestWalletService_SetOverdraftLimit(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	repositoryMock.On("GetWalletForUpdate", 1).
+		Return(models.Wallet{Model: gorm.Model{ID: 1}, Currency: "EUR", OverdraftLimit: decimal.NewFromInt(10)}, nil).Once()
+	repositoryMock.On("UpdateWallet", mock.MatchedBy(func(wallet *models.Wallet) bool {
+		return wallet.OverdraftLimit.Equal(decimal.NewFromInt(100))
+	})).Return(nil).Once()
+	repositoryMock.On("CreateOverdraftLimitChange", mock.MatchedBy(func(change *models.OverdraftLimitChange) bool {
+		return change.PreviousLimit.Equal(decimal.NewFromInt(10)) &&
+			change.NewLimit.Equal(decimal.NewFromInt(100)) &&
+			change.ChangedBy == "risk-team"
+	})).Return(nil).Once()
+
+	service := WalletService{
+		transactionRepository: repositoryMock,
+	}
+
+	wallet, err := service.SetOverdraftLimit(1, models.OverdraftLimitRequest{Limit: decimal.NewFromInt(100), ChangedBy: "risk-team"})
+	repositoryMock.AssertExpectations(t)
+	assert.Nil(t, err)
+	assert.True(t, wallet.OverdraftLimit.Equal(decimal.NewFromInt(100)))
+
+	_, err = service.SetOverdraftLimit(1, models.OverdraftLimitRequest{Limit: decimal.NewFromInt(-1), ChangedBy: "risk-team"})
+	assert.NotNil(t, err)
+}
+
+func TestWalletService_SetOverdraftLimitSystemAccount(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	repositoryMock.On("GetWalletForUpdate", 90).Return(systemAccount(models.SystemAccountFunding, "EUR"), nil).Once()
+
+	service := WalletService{
+		transactionRepository: repositoryMock,
+	}
+
+	_, err := service.SetOverdraftLimit(90, models.OverdraftLimitRequest{Limit: decimal.NewFromInt(100), ChangedBy: "risk-team"})
+	repositoryMock.AssertExpectations(t)
+	repositoryMock.AssertNotCalled(t, "UpdateWallet", mock.Anything)
+	assert.Equal(t, ErrorCodeSystemAccount, err.(*exceptions.ForbiddenException).Code)
+}
+
+func TestWalletService_GetSystemAccount(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	repositoryMock.On("GetWalletFromDatabase", 90).Return(systemAccount(models.SystemAccountFunding, "EUR"), nil).Once()
+	repositoryMock.On("GetLatestSnapshot", 90, mock.Anything).Return(nil, nil).Once()
+	repositoryMock.On("SumLedger", 90, uint(0), mock.Anything).Return(models.LedgerSum{Total: decimal.NewFromInt(-250)}, nil).Once()
+
+	service := WalletService{
+		transactionRepository: repositoryMock,
+	}
+
+	account, err := service.Get(90)
+	repositoryMock.AssertExpectations(t)
+	assert.Nil(t, err)
+	assert.True(t, account.Balance.Equal(decimal.NewFromInt(-250)))
+}
@@ -0,0 +1,445 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/viper"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"github.com/wallet-api/exceptions"
+	"github.com/wallet-api/infrastructure"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type IWebhookService interface {
+	Subscribe(walletId int, request models.CreateWebhookRequest) (models.WebhookSubscription, error)
+	Deliver(now time.Time) (models.WebhookRun, error)
+	GetDeadLetters() ([]models.WebhookDelivery, error)
+	GetAttempts(deliveryId int) ([]models.WebhookAttempt, error)
+	Replay(deliveryId int) (models.WebhookDelivery, error)
+}
+
+type WebhookService struct {
+	transactionRepository repositories.ITransactionRepository
+	client                *http.Client
+	// allowPrivate lets subscriptions target private and loopback addresses, for local development only
+	allowPrivate bool
+	batchSize    int
+	// workers is how many deliveries are attempted at the same time
+	workers int
+	// lease is how long a claimed delivery is kept from the other instances, longer than an attempt
+	lease       time.Duration
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+}
+
+const (
+	ErrorCodeInvalidWebhookURL      string = "invalid_webhook_url"
+	ErrorCodeWebhookDeliveryNotDead string = "webhook_delivery_not_dead"
+)
+
+// headers of the webhook requests, the signature is the hex HMAC-SHA256 of the timestamp, a dot and
+// the body, keyed with the subscription secret
+const (
+	webhookHeaderEvent     string = "X-Webhook-Event"
+	webhookHeaderID        string = "X-Webhook-Id"
+	webhookHeaderTimestamp string = "X-Webhook-Timestamp"
+	webhookHeaderSignature string = "X-Webhook-Signature"
+)
+
+const defaultWebhookBatchSize int = 100
+
+// defaults used when webhooks.workers, webhooks.lease and webhooks.backoff are not configured, a
+// backoff that is not positive would claim the failed delivery again in the same run
+const (
+	defaultWebhookWorkers int           = 8
+	defaultWebhookLease   time.Duration = time.Minute
+	defaultWebhookBackoff time.Duration = 30 * time.Second
+)
+
+// webhookResponseLimit is how much of a response body is read so the connection can be reused
+const webhookResponseLimit int64 = 4096
+
+// Subscribe registers the endpoint for the balance changes of the wallet, it receives no other wallet's
+func (service *WebhookService) Subscribe(walletId int, request models.CreateWebhookRequest) (models.WebhookSubscription, error) {
+	if err := service.checkEndpoint(request.URL); err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	wallet, err := service.transactionRepository.GetWallet(walletId)
+	if err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	if wallet.IsSystem() {
+		return models.WebhookSubscription{}, exceptions.NewForbiddenExceptionWithCode(ErrorCodeSystemAccount, "operation not allowed: wallet %s is a system account", strconv.Itoa(walletId))
+	}
+
+	subscription := models.WebhookSubscription{
+		WalletID:   wallet.ID,
+		URL:        request.URL,
+		EventTypes: request.EventTypes,
+		Secret:     request.Secret,
+	}
+	err = service.transactionRepository.CreateWebhookSubscription(&subscription)
+
+	return subscription, err
+}
+
+// checkEndpoint verifies the url is http or https and that its host only resolves to public addresses,
+// the client checks the address again on every connection since the host can resolve elsewhere later
+func (service *WebhookService) checkEndpoint(rawURL string) error {
+	endpoint, err := url.Parse(rawURL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Hostname() == "" {
+		return exceptions.NewForbiddenExceptionWithCode(ErrorCodeInvalidWebhookURL, "operation not allowed: webhooks must be http or https urls")
+	}
+	if service.allowPrivate {
+		return nil
+	}
+
+	ips, err := net.LookupIP(endpoint.Hostname())
+	if err != nil || len(ips) == 0 {
+		return exceptions.NewForbiddenExceptionWithCode(ErrorCodeInvalidWebhookURL, "operation not allowed: the webhook host %s doesn't resolve", endpoint.Hostname())
+	}
+	for _, ip := range ips {
+		if !publicIP(ip) {
+			return exceptions.NewForbiddenExceptionWithCode(ErrorCodeInvalidWebhookURL, "operation not allowed: webhooks cannot target private or loopback addresses")
+		}
+	}
+	return nil
+}
+
+// Deliver POSTs the pending deliveries that are due, several at a time. Each batch is claimed first so
+// instances running at the same time send different deliveries. Failed ones are retried with exponential
+// backoff until they run out of attempts and move to the dead-letter list
+func (service *WebhookService) Deliver(now time.Time) (models.WebhookRun, error) {
+	batchSize := service.batchSize
+	if batchSize < 1 {
+		batchSize = defaultWebhookBatchSize
+	}
+	workers := service.workers
+	if workers < 1 {
+		workers = defaultWebhookWorkers
+	}
+	lease := service.lease
+	if lease <= 0 {
+		lease = defaultWebhookLease
+	}
+
+	var run models.WebhookRun
+	subscriptions := map[uint]models.WebhookSubscription{}
+	for {
+		deliveries, err := service.transactionRepository.ClaimDueWebhookDeliveries(now, lease, batchSize)
+		if err != nil {
+			return run, err
+		}
+
+		for _, delivery := range deliveries {
+			if _, ok := subscriptions[delivery.SubscriptionID]; ok {
+				continue
+			}
+			subscription, err := service.transactionRepository.GetWebhookSubscription(int(delivery.SubscriptionID))
+			if err != nil {
+				return run, err
+			}
+			subscriptions[delivery.SubscriptionID] = subscription
+		}
+
+		if err := service.attemptAll(subscriptions, deliveries, workers, now, &run); err != nil {
+			return run, err
+		}
+
+		if len(deliveries) < batchSize {
+			return run, nil
+		}
+	}
+}
+
+// attemptAll attempts the deliveries with the given number of workers, adding the outcomes to the run.
+// It returns the first error recording an attempt, the other deliveries are still attempted
+func (service *WebhookService) attemptAll(subscriptions map[uint]models.WebhookSubscription, deliveries []models.WebhookDelivery, workers int, now time.Time, run *models.WebhookRun) error {
+	queue := make(chan models.WebhookDelivery)
+	var mutex sync.Mutex
+	var firstErr error
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(deliveries); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivery := range queue {
+				delivery := delivery
+				err := service.attempt(subscriptions[delivery.SubscriptionID], &delivery, now)
+
+				mutex.Lock()
+				switch {
+				case err != nil:
+					if firstErr == nil {
+						firstErr = err
+					}
+				case delivery.Status == models.WebhookDeliveryDelivered:
+					run.Delivered++
+				case delivery.Status == models.WebhookDeliveryDead:
+					run.Dead++
+				default:
+					run.Retried++
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for _, delivery := range deliveries {
+		queue <- delivery
+	}
+	close(queue)
+	wg.Wait()
+
+	return firstErr
+}
+
+// attempt POSTs the delivery once, records the attempt and schedules what comes next
+func (service *WebhookService) attempt(subscription models.WebhookSubscription, delivery *models.WebhookDelivery, now time.Time) error {
+	body, err := json.Marshal(models.WebhookEvent{
+		ID:        delivery.EventID,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.CreatedAt,
+		Data:      json.RawMessage(delivery.Payload),
+	})
+	if err != nil {
+		return err
+	}
+
+	started := time.Now()
+	statusCode, err := service.post(subscription, delivery, body)
+	attempt := models.WebhookAttempt{
+		DeliveryID: delivery.ID,
+		StatusCode: statusCode,
+		DurationMs: time.Since(started).Milliseconds(),
+	}
+	if err == nil && (statusCode < http.StatusOK || statusCode >= http.StatusMultipleChoices) {
+		err = &webhookStatusError{statusCode: statusCode}
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	if err := service.transactionRepository.CreateWebhookAttempt(&attempt); err != nil {
+		return err
+	}
+
+	delivery.Attempts++
+	delivery.LastError = attempt.Error
+	switch {
+	case err == nil:
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= service.maxAttempts:
+		delivery.Status = models.WebhookDeliveryDead
+	default:
+		delivery.NextAttemptAt = now.Add(service.delay(delivery.Attempts))
+	}
+	return service.transactionRepository.UpdateWebhookDelivery(delivery)
+}
+
+// post sends the delivery signed with the time it is sent at, so receivers can reject stale requests
+func (service *WebhookService) post(subscription models.WebhookSubscription, delivery *models.WebhookDelivery, body []byte) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(webhookHeaderEvent, delivery.EventType)
+	request.Header.Set(webhookHeaderID, delivery.EventID)
+	request.Header.Set(webhookHeaderTimestamp, timestamp)
+	request.Header.Set(webhookHeaderSignature, "sha256="+signWebhook(subscription.Secret, timestamp, body))
+
+	response, err := service.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(response.Body, webhookResponseLimit))
+
+	return response.StatusCode, nil
+}
+
+// delay is how long the delivery waits after its failed attempt number attempts, doubling from the
+// configured backoff up to the max backoff
+func (service *WebhookService) delay(attempts int) time.Duration {
+	delay := service.backoff
+	if delay <= 0 {
+		delay = defaultWebhookBackoff
+	}
+	for i := 1; i < attempts && (service.maxBackoff <= 0 || delay < service.maxBackoff); i++ {
+		delay *= 2
+	}
+	if service.maxBackoff > 0 && delay > service.maxBackoff {
+		return service.maxBackoff
+	}
+	return delay
+}
+
+func signWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type webhookStatusError struct {
+	statusCode int
+}
+
+func (err *webhookStatusError) Error() string {
+	return "the endpoint answered " + strconv.Itoa(err.statusCode)
+}
+
+// blockedNetworks are the private ranges webhooks cannot reach, so a subscription cannot make the service
+// call the internal network. Loopback, link-local and multicast addresses are rejected by publicIP
+var blockedNetworks = parseNetworks("0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7")
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// publicIP reports whether webhooks can be sent to the ip
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// newWebhookClient returns the client of the deliveries. Unless private addresses are allowed, every
+// connection is checked once its host is resolved, which also covers redirects and hosts whose records
+// changed after the subscription was checked
+func newWebhookClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = func(network string, address string, conn syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("webhooks cannot target %s", host)
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConnsPerHost: 10,
+		},
+	}
+}
+
+func (service *WebhookService) GetDeadLetters() ([]models.WebhookDelivery, error) {
+	return service.transactionRepository.GetDeadWebhookDeliveries()
+}
+
+func (service *WebhookService) GetAttempts(deliveryId int) ([]models.WebhookAttempt, error) {
+	if _, err := service.transactionRepository.GetWebhookDelivery(deliveryId); err != nil {
+		return nil, err
+	}
+
+	return service.transactionRepository.GetWebhookAttempts(deliveryId)
+}
+
+// Replay takes the delivery out of the dead-letter list, giving it a new round of attempts starting now
+func (service *WebhookService) Replay(deliveryId int) (models.WebhookDelivery, error) {
+	delivery, err := service.transactionRepository.GetWebhookDelivery(deliveryId)
+	if err != nil {
+		return delivery, err
+	}
+	if delivery.Status != models.WebhookDeliveryDead {
+		return delivery, exceptions.NewForbiddenExceptionWithCode(ErrorCodeWebhookDeliveryNotDead, "operation not allowed: the delivery is %s", delivery.Status)
+	}
+
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	err = service.transactionRepository.UpdateWebhookDelivery(&delivery)
+
+	return delivery, err
+}
+
+// webhookPublisher creates a delivery of each outbox message for every subscription of its wallet to
+// its event type. Deliveries already created for a message are skipped, so publishing it again is harmless
+type webhookPublisher struct {
+	transactionRepository repositories.ITransactionRepository
+}
+
+func (publisher *webhookPublisher) Publish(message infrastructure.Message) error {
+	walletId, err := strconv.Atoi(message.Key)
+	if err != nil {
+		return fmt.Errorf("message %s has no wallet id: %v", message.ID, err)
+	}
+	subscriptions, err := publisher.transactionRepository.GetWebhookSubscriptions(walletId)
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		if !subscription.Subscribes(message.Type) {
+			continue
+		}
+		_, err := publisher.transactionRepository.CreateWebhookDelivery(&models.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        message.ID,
+			EventType:      message.Type,
+			Payload:        string(message.Payload),
+			Status:         models.WebhookDeliveryPending,
+			NextAttemptAt:  time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func NewWebhookService() IWebhookService {
+	allowPrivate := viper.GetBool("webhooks.allow_private_networks")
+	return &WebhookService{
+		transactionRepository: repositories.NewTransactionRepository(),
+		client:                newWebhookClient(viper.GetDuration("webhooks.timeout"), allowPrivate),
+		allowPrivate:          allowPrivate,
+		batchSize:             viper.GetInt("webhooks.batch_size"),
+		workers:               viper.GetInt("webhooks.workers"),
+		lease:                 viper.GetDuration("webhooks.lease"),
+		maxAttempts:           viper.GetInt("webhooks.max_attempts"),
+		backoff:               viper.GetDuration("webhooks.backoff"),
+		maxBackoff:            viper.GetDuration("webhooks.max_backoff"),
+	}
+}
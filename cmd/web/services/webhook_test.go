@@ -0,0 +1,330 @@
+package services
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/exceptions"
+	"github.com/wallet-api/infrastructure"
+	mocks "github.com/wallet-api/mocks/repositories"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhookService_Deliver(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	secret := "0123456789abcdef"
+
+	// the receiver verifies the signature and answers with the status the test sets
+	status := http.StatusOK
+	var received []models.WebhookEvent
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		timestamp := r.Header.Get(webhookHeaderTimestamp)
+		// the signature covers the time of the send, not the time the run started
+		sentAt, _ := strconv.ParseInt(timestamp, 10, 64)
+		if time.Since(time.Unix(sentAt, 0)) > time.Minute || r.Header.Get(webhookHeaderSignature) != "sha256="+signWebhook(secret, timestamp, body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var event models.WebhookEvent
+		json.Unmarshal(body, &event)
+		received = append(received, event)
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+
+	subscription := models.WebhookSubscription{ID: 5, URL: receiver.URL, EventTypes: []string{models.OutboxEventWalletDebited}, Secret: secret}
+	delivery := models.WebhookDelivery{ID: 9, SubscriptionID: 5, EventID: "42", EventType: models.OutboxEventWalletDebited,
+		Payload: `{"id":10,"amount":"5"}`, Status: models.WebhookDeliveryPending}
+	retrying := delivery
+	retrying.Attempts = 1
+	lastAttempt := delivery
+	lastAttempt.Attempts = 3
+
+	service := WebhookService{
+		transactionRepository: repositoryMock,
+		client:                receiver.Client(),
+		maxAttempts:           4,
+		backoff:               time.Minute,
+		maxBackoff:            time.Hour,
+	}
+
+	tests := []struct {
+		name        string
+		status      int
+		initMocks   func()
+		assertMocks func(*testing.T)
+		assertError func(*testing.T, error)
+		assertFunc  func(*testing.T, models.WebhookRun)
+	}{
+		{
+			name:   "Success - signed event delivered and its attempt recorded",
+			status: http.StatusOK,
+			initMocks: func() {
+				repositoryMock.On("ClaimDueWebhookDeliveries", now, defaultWebhookLease, defaultWebhookBatchSize).Return([]models.WebhookDelivery{delivery}, nil).Once()
+				repositoryMock.On("GetWebhookSubscription", 5).Return(subscription, nil).Once()
+				repositoryMock.On("CreateWebhookAttempt", mock.MatchedBy(func(attempt *models.WebhookAttempt) bool {
+					return attempt.DeliveryID == 9 && attempt.StatusCode == http.StatusOK && attempt.Error == ""
+				})).Return(nil).Once()
+				repositoryMock.On("UpdateWebhookDelivery", mock.MatchedBy(func(updated *models.WebhookDelivery) bool {
+					return updated.Status == models.WebhookDeliveryDelivered && updated.Attempts == 1 && updated.DeliveredAt.Equal(now)
+				})).Return(nil).Once()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+			assertFunc: func(t *testing.T, run models.WebhookRun) {
+				assert.Equal(t, models.WebhookRun{Delivered: 1}, run)
+				assert.Len(t, received, 1)
+				assert.Equal(t, "42", received[0].ID)
+				assert.JSONEq(t, delivery.Payload, string(received[0].Data))
+			},
+		},
+		{
+			name:   "Success - failed attempt retried with exponential backoff",
+			status: http.StatusInternalServerError,
+			initMocks: func() {
+				repositoryMock.On("ClaimDueWebhookDeliveries", now, defaultWebhookLease, defaultWebhookBatchSize).Return([]models.WebhookDelivery{retrying}, nil).Once()
+				repositoryMock.On("GetWebhookSubscription", 5).Return(subscription, nil).Once()
+				repositoryMock.On("CreateWebhookAttempt", mock.MatchedBy(func(attempt *models.WebhookAttempt) bool {
+					return attempt.StatusCode == http.StatusInternalServerError && attempt.Error == "the endpoint answered 500"
+				})).Return(nil).Once()
+				repositoryMock.On("UpdateWebhookDelivery", mock.MatchedBy(func(updated *models.WebhookDelivery) bool {
+					return updated.Status == models.WebhookDeliveryPending && updated.Attempts == 2 &&
+						updated.NextAttemptAt.Equal(now.Add(2*time.Minute))
+				})).Return(nil).Once()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+			assertFunc: func(t *testing.T, run models.WebhookRun) {
+				assert.Equal(t, models.WebhookRun{Retried: 1}, run)
+			},
+		},
+		{
+			name:   "Success - last attempt failed, delivery dead-lettered",
+			status: http.StatusBadGateway,
+			initMocks: func() {
+				repositoryMock.On("ClaimDueWebhookDeliveries", now, defaultWebhookLease, defaultWebhookBatchSize).Return([]models.WebhookDelivery{lastAttempt}, nil).Once()
+				repositoryMock.On("GetWebhookSubscription", 5).Return(subscription, nil).Once()
+				repositoryMock.On("CreateWebhookAttempt", mock.Anything).Return(nil).Once()
+				repositoryMock.On("UpdateWebhookDelivery", mock.MatchedBy(func(updated *models.WebhookDelivery) bool {
+					return updated.Status == models.WebhookDeliveryDead && updated.Attempts == 4
+				})).Return(nil).Once()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+			assertFunc: func(t *testing.T, run models.WebhookRun) {
+				assert.Equal(t, models.WebhookRun{Dead: 1}, run)
+			},
+		},
+		{
+			name:   "Success - unreachable endpoint recorded without status code",
+			status: http.StatusOK,
+			initMocks: func() {
+				unreachable := subscription
+				unreachable.URL = "http://127.0.0.1:1"
+				repositoryMock.On("ClaimDueWebhookDeliveries", now, defaultWebhookLease, defaultWebhookBatchSize).Return([]models.WebhookDelivery{delivery}, nil).Once()
+				repositoryMock.On("GetWebhookSubscription", 5).Return(unreachable, nil).Once()
+				repositoryMock.On("CreateWebhookAttempt", mock.MatchedBy(func(attempt *models.WebhookAttempt) bool {
+					return attempt.StatusCode == 0 && attempt.Error != ""
+				})).Return(nil).Once()
+				repositoryMock.On("UpdateWebhookDelivery", mock.MatchedBy(func(updated *models.WebhookDelivery) bool {
+					return updated.Status == models.WebhookDeliveryPending && updated.NextAttemptAt.Equal(now.Add(time.Minute))
+				})).Return(nil).Once()
+			},
+			assertMocks: func(t *testing.T) {
+				repositoryMock.AssertExpectations(t)
+			},
+			assertError: func(t *testing.T, err error) {
+				assert.Nil(t, err)
+			},
+			assertFunc: func(t *testing.T, run models.WebhookRun) {
+				assert.Equal(t, models.WebhookRun{Retried: 1}, run)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repositoryMock.ExpectedCalls = nil
+			repositoryMock.Calls = nil
+			received = nil
+			status = tt.status
+			tt.initMocks()
+
+			run, err := service.Deliver(now)
+			tt.assertMocks(t)
+			tt.assertError(t, err)
+			tt.assertFunc(t, run)
+		})
+	}
+}
+
+func TestWebhookService_DeliverConcurrently(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	now := time.Now()
+
+	// the receiver only answers once both deliveries are in flight, serial sends would time out
+	var inFlight sync.WaitGroup
+	inFlight.Add(2)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight.Done()
+		both := make(chan struct{})
+		go func() {
+			inFlight.Wait()
+			close(both)
+		}()
+		select {
+		case <-both:
+			w.WriteHeader(http.StatusOK)
+		case <-time.After(2 * time.Second):
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	subscription := models.WebhookSubscription{ID: 5, URL: receiver.URL, Secret: "0123456789abcdef"}
+	deliveries := []models.WebhookDelivery{
+		{ID: 9, SubscriptionID: 5, EventID: "42", Payload: `{}`, Status: models.WebhookDeliveryPending},
+		{ID: 10, SubscriptionID: 5, EventID: "43", Payload: `{}`, Status: models.WebhookDeliveryPending},
+	}
+	repositoryMock.On("ClaimDueWebhookDeliveries", now, defaultWebhookLease, defaultWebhookBatchSize).Return(deliveries, nil).Once()
+	repositoryMock.On("GetWebhookSubscription", 5).Return(subscription, nil).Once()
+	repositoryMock.On("CreateWebhookAttempt", mock.Anything).Return(nil).Twice()
+	repositoryMock.On("UpdateWebhookDelivery", mock.Anything).Return(nil).Twice()
+
+	service := WebhookService{
+		transactionRepository: repositoryMock,
+		client:                receiver.Client(),
+		maxAttempts:           4,
+	}
+
+	run, err := service.Deliver(now)
+	assert.Nil(t, err)
+	assert.Equal(t, models.WebhookRun{Delivered: 2}, run)
+	repositoryMock.AssertExpectations(t)
+}
+
+func TestWebhookService_Delay(t *testing.T) {
+	service := WebhookService{backoff: time.Minute, maxBackoff: 10 * time.Minute}
+
+	assert.Equal(t, time.Minute, service.delay(1))
+	assert.Equal(t, 4*time.Minute, service.delay(3))
+	assert.Equal(t, 10*time.Minute, service.delay(5))
+	assert.Equal(t, 10*time.Minute, service.delay(60))
+}
+
+func TestWebhookService_Subscribe(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	wallet := models.Wallet{Currency: "EUR", Status: models.WalletStatusActive}
+	wallet.ID = 1
+	repositoryMock.On("GetWallet", 1).Return(wallet, nil).Once()
+	repositoryMock.On("CreateWebhookSubscription", mock.MatchedBy(func(subscription *models.WebhookSubscription) bool {
+		return subscription.WalletID == 1
+	})).Return(nil).Once()
+	repositoryMock.On("GetWallet", 90).Return(systemAccount(models.SystemAccountFunding, "EUR"), nil).Once()
+
+	service := WebhookService{
+		transactionRepository: repositoryMock,
+	}
+
+	request := models.CreateWebhookRequest{URL: "https://93.184.216.34/hooks", EventTypes: []string{models.OutboxEventWalletCredited}, Secret: "0123456789abcdef"}
+	subscription, err := service.Subscribe(1, request)
+	assert.Nil(t, err)
+	assert.Equal(t, request.EventTypes, subscription.EventTypes)
+
+	_, err = service.Subscribe(90, request)
+	assert.Equal(t, ErrorCodeSystemAccount, err.(*exceptions.ForbiddenException).Code)
+
+	request.URL = "ftp://93.184.216.34/hooks"
+	_, err = service.Subscribe(1, request)
+	assert.EqualError(t, err, "operation not allowed: webhooks must be http or https urls")
+
+	// the service must not be usable to reach its own network
+	for _, internal := range []string{"http://127.0.0.1:8080/hooks", "http://10.0.0.5/hooks", "http://169.254.169.254/latest/meta-data", "http://[::1]/hooks", "http://0.0.0.0/hooks"} {
+		request.URL = internal
+		_, err = service.Subscribe(1, request)
+		assert.Equal(t, ErrorCodeInvalidWebhookURL, err.(*exceptions.ForbiddenException).Code, internal)
+	}
+	repositoryMock.AssertExpectations(t)
+}
+
+func TestWebhookService_ClientRejectsPrivateAddresses(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	_, err := newWebhookClient(time.Second, false).Post(receiver.URL, "application/json", nil)
+	assert.NotNil(t, err)
+
+	response, err := newWebhookClient(time.Second, true).Post(receiver.URL, "application/json", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
+
+func TestWebhookService_Replay(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	dead := models.WebhookDelivery{ID: 9, Status: models.WebhookDeliveryDead, Attempts: 8}
+	delivered := models.WebhookDelivery{ID: 10, Status: models.WebhookDeliveryDelivered, Attempts: 1}
+	repositoryMock.On("GetWebhookDelivery", 9).Return(dead, nil).Once()
+	repositoryMock.On("UpdateWebhookDelivery", mock.MatchedBy(func(updated *models.WebhookDelivery) bool {
+		return updated.ID == 9 && updated.Status == models.WebhookDeliveryPending && updated.Attempts == 0
+	})).Return(nil).Once()
+	repositoryMock.On("GetWebhookDelivery", 10).Return(delivered, nil).Once()
+
+	service := WebhookService{
+		transactionRepository: repositoryMock,
+	}
+
+	replayed, err := service.Replay(9)
+	assert.Nil(t, err)
+	assert.Equal(t, models.WebhookDeliveryPending, replayed.Status)
+
+	_, err = service.Replay(10)
+	assert.Equal(t, ErrorCodeWebhookDeliveryNotDead, err.(*exceptions.ForbiddenException).Code)
+	repositoryMock.AssertExpectations(t)
+}
+
+func TestWebhookPublisher_Publish(t *testing.T) {
+	repositoryMock := &mocks.RepositoryMock{}
+	// only the subscriptions of the wallet of the message are read
+	repositoryMock.On("GetWebhookSubscriptions", 7).Return([]models.WebhookSubscription{
+		{ID: 1, WalletID: 7, EventTypes: []string{models.OutboxEventWalletCredited}},
+		{ID: 2, WalletID: 7, EventTypes: []string{models.OutboxEventWalletCredited, models.OutboxEventWalletDebited}},
+	}, nil).Once()
+	repositoryMock.On("CreateWebhookDelivery", mock.MatchedBy(func(delivery *models.WebhookDelivery) bool {
+		return delivery.SubscriptionID == 2 && delivery.EventID == "42" && delivery.Status == models.WebhookDeliveryPending
+	})).Return(true, nil).Once()
+
+	publisher := webhookPublisher{transactionRepository: repositoryMock}
+	err := publisher.Publish(infrastructure.Message{ID: "42", Type: models.OutboxEventWalletDebited, Key: "7", Payload: []byte(`{}`)})
+	assert.Nil(t, err)
+	repositoryMock.AssertExpectations(t)
+}
+
+func TestWebhookService_DelayWithoutBackoff(t *testing.T) {
+	service := WebhookService{backoff: -time.Second, maxBackoff: time.Hour}
+
+	assert.Equal(t, defaultWebhookBackoff, service.delay(1))
+	assert.Equal(t, 2*defaultWebhookBackoff, service.delay(2))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/services"
+	"time"
+)
+
+// startWorkers launches the background jobs that run alongside the web server
+func startWorkers() {
+	holdService := services.NewHoldService()
+	go every(viper.GetDuration("holds.expiry_interval"), func() {
+		expired, err := holdService.ExpireHolds()
+		if err != nil {
+			logrus.Errorf("couldn't expire holds: %v", err)
+			return
+		}
+		if expired > 0 {
+			logrus.Infof("expired %d holds", expired)
+		}
+	})
+
+	snapshotService := services.NewSnapshotService()
+	go every(viper.GetDuration("snapshots.interval"), func() {
+		taken, err := snapshotService.TakeSnapshots()
+		if err != nil {
+			logrus.Errorf("couldn't take balance snapshots: %v", err)
+			return
+		}
+		if taken > 0 {
+			logrus.Infof("took %d balance snapshots", taken)
+		}
+	})
+
+	batchService := services.NewBatchService()
+	go every(viper.GetDuration("batches.poll_interval"), func() {
+		if _, err := batchService.ProcessPending(); err != nil {
+			logrus.Errorf("couldn't process pending batches: %v", err)
+		}
+	})
+
+	reconciliationService := services.NewReconciliationService()
+	go every(viper.GetDuration("reconciliation.interval"), func() {
+		report, err := reconciliationService.Reconcile(models.ReconciliationOptions{})
+		if err != nil {
+			logrus.Errorf("couldn't reconcile wallets: %v", err)
+			return
+		}
+		if len(report.Discrepancies) > 0 {
+			logrus.Warnf("reconciliation found %d discrepancies, see %s", len(report.Discrepancies), report.File)
+		}
+	})
+
+	interestService := services.NewInterestService()
+	go every(viper.GetDuration("interest.interval"), func() {
+		run, err := interestService.Run(time.Now())
+		if err != nil {
+			logrus.Errorf("couldn't run the interest job: %v", err)
+			return
+		}
+		if run.Accruals > 0 || run.Payouts > 0 {
+			logrus.Infof("accrued %d days of interest and paid out %d wallets", run.Accruals, run.Payouts)
+		}
+	})
+
+	outboxService := services.NewOutboxService()
+	go every(viper.GetDuration("outbox.relay_interval"), func() {
+		delivered, err := outboxService.Relay()
+		if err != nil {
+			logrus.Errorf("couldn't relay the outbox: %v", err)
+		}
+		if delivered > 0 {
+			logrus.Infof("published %d outbox messages", delivered)
+		}
+	})
+
+	webhookService := services.NewWebhookService()
+	go every(viper.GetDuration("webhooks.delivery_interval"), func() {
+		run, err := webhookService.Deliver(time.Now())
+		if err != nil {
+			logrus.Errorf("couldn't deliver webhooks: %v", err)
+		}
+		if run.Dead > 0 {
+			logrus.Warnf("moved %d webhook deliveries to the dead-letter list", run.Dead)
+		}
+	})
+}
+
+// every runs job periodically, a non positive interval disables it
+func every(interval time.Duration, job func()) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		job()
+	}
+}
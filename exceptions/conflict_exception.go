@@ -0,0 +1,15 @@
+package exceptions
+
+import "fmt"
+
+type ConflictException struct {
+	Message error
+}
+
+func (e *ConflictException) Error() string {
+	return e.Message.Error()
+}
+
+func NewConflictException(message string, args ...string) error {
+	return &ConflictException{Message: fmt.Errorf(message, toInterfaces(args)...)}
+}
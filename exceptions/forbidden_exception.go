@@ -4,6 +4,10 @@ import "fmt"
 
 type ForbiddenException struct {
 	Message error
+	// Code identifies the broken rule so clients can react to it without parsing the message
+	Code string
+	// Details carries values that help the client recover, e.g. the amount it can still use
+	Details map[string]interface{}
 }
 
 func (e *ForbiddenException) Error() string {
@@ -11,5 +15,13 @@ func (e *ForbiddenException) Error() string {
 }
 
 func NewForbiddenException(message string, args ...string) error {
-	return &ForbiddenException{Message: fmt.Errorf(message, args)}
+	return &ForbiddenException{Message: fmt.Errorf(message, toInterfaces(args)...)}
+}
+
+func NewForbiddenExceptionWithCode(code string, message string, args ...string) error {
+	return &ForbiddenException{Message: fmt.Errorf(message, toInterfaces(args)...), Code: code}
+}
+
+func NewForbiddenExceptionWithDetails(code string, details map[string]interface{}, message string, args ...string) error {
+	return &ForbiddenException{Message: fmt.Errorf(message, toInterfaces(args)...), Code: code, Details: details}
 }
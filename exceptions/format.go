@@ -0,0 +1,9 @@
+package exceptions
+
+func toInterfaces(args []string) []interface{} {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg
+	}
+	return values
+}
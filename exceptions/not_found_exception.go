@@ -11,5 +11,5 @@ func (e *NotFoundException) Error() string {
 }
 
 func NewNotFoundException(message string, args ...string) error {
-	return &NotFoundException{Message: fmt.Errorf(message, args)}
+	return &NotFoundException{Message: fmt.Errorf(message, toInterfaces(args)...)}
 }
@@ -16,6 +16,14 @@ type ICacheProvider interface {
 	Get(key string) (string, error)
 
 	Set(key string, val interface{}, ttl time.Duration) (string, error)
+
+	// SetNX sets the key only if it does not exist yet, reporting whether it was set
+	SetNX(key string, val interface{}, ttl time.Duration) (bool, error)
+
+	Del(key string) error
+
+	// IncrBy adds value to the integer stored at key, creating it when missing, and returns the result
+	IncrBy(key string, value int64) (int64, error)
 }
 
 type RedisProvider struct {
@@ -64,6 +72,18 @@ func (provider *RedisProvider) Set(key string, val interface{}, ttl time.Duratio
 	return provider.client.Set(key, val, ttl).Result()
 }
 
+func (provider *RedisProvider) SetNX(key string, val interface{}, ttl time.Duration) (bool, error) {
+	return provider.client.SetNX(key, val, ttl).Result()
+}
+
+func (provider *RedisProvider) Del(key string) error {
+	return provider.client.Del(key).Err()
+}
+
+func (provider *RedisProvider) IncrBy(key string, value int64) (int64, error) {
+	return provider.client.IncrBy(key, value).Result()
+}
+
 func NewCacheClient() ICacheProvider {
 	provider := &RedisProvider{}
 	c, err := provider.ConnectCache()
@@ -46,44 +46,150 @@ func migrateUp(db *gorm.DB) error {
 	if viper.GetString("env") == "test" {
 		return migrateUpTest(db)
 	}
-	return nil
+	return migrateUpLive(db)
 }
 
-func migrateUpDevelop(db *gorm.DB) error {
-	state := db.CreateTable(&models.Wallet{})
-	if state.Error == nil {
+// migrateUpLive leaves the schema to the database migrations and only seeds the rows every operation
+// depends on, so a new currency or account type is usable as soon as it is deployed
+func migrateUpLive(db *gorm.DB) error {
+	return migrateSystemAccounts(db)
+}
 
-		b1, _ := decimal.NewFromString("136.02")
-		b2, _ := decimal.NewFromString("136.02")
-		b3, _ := decimal.NewFromString("136.02")
+// tables lists every model managed by the migrations
+var tables = []interface{}{
+	&models.Owner{},
+	&models.Wallet{},
+	&models.Transaction{},
+	&models.TransactionMetadata{},
+	&models.IdempotencyRecord{},
+	&models.FXQuote{},
+	&models.Hold{},
+	&models.OverdraftLimitChange{},
+	&models.BalanceSnapshot{},
+	&models.Batch{},
+	&models.BatchItem{},
+	&models.VelocityLimit{},
+	&models.InterestAccrual{},
+	&models.JournalEntry{},
+	&models.Posting{},
+	&models.WalletEvent{},
+	&models.OutboxMessage{},
+	&models.WebhookSubscription{},
+	&models.WebhookDelivery{},
+	&models.WebhookAttempt{},
+}
 
-		wallet1 := models.Wallet{Balance: b1}
-		wallet2 := models.Wallet{Balance: b2}
-		wallet3 := models.Wallet{Balance: b3}
+func migrateUpDevelop(db *gorm.DB) error {
+	seed := !db.HasTable(&models.Wallet{})
+	if err := db.AutoMigrate(tables...).Error; err != nil {
+		return err
+	}
 
-		db.Create(&wallet1)
-		db.Create(&wallet2)
-		db.Create(&wallet3)
+	// customer wallets are created first so they keep the ids 1 to 3
+	var wallets []models.Wallet
+	if seed {
+		wallets = []models.Wallet{seedWallet(db, "EUR"), seedWallet(db, "USD"), seedWallet(db, "ARS")}
+	}
+	if err := migrateSystemAccounts(db); err != nil {
+		return err
+	}
+
+	b1, _ := decimal.NewFromString("136.02")
+	for _, wallet := range wallets {
+		seedOpeningBalance(db, wallet, b1)
 	}
 	return nil
 }
 
 func migrateUpTest(db *gorm.DB) error {
-	db.DropTable(&models.Wallet{})
-	state := db.CreateTable(&models.Wallet{})
-	if state.Error == nil {
+	db.DropTable(tables...)
+	if err := db.AutoMigrate(tables...).Error; err != nil {
+		return err
+	}
 
-		b2, _ := decimal.NewFromString("136.02")
-		b3, _ := decimal.NewFromString("136.02")
+	wallets := []models.Wallet{seedWallet(db, "EUR"), seedWallet(db, "EUR"), seedWallet(db, "EUR")}
+	if err := migrateSystemAccounts(db); err != nil {
+		return err
+	}
 
-		wallet1 := models.Wallet{Balance: decimal.NewFromInt(20)}
-		wallet2 := models.Wallet{Balance: b2}
-		wallet3 := models.Wallet{Balance: b3}
+	b2, _ := decimal.NewFromString("136.02")
+	b3, _ := decimal.NewFromString("136.02")
 
-		db.Create(&wallet1)
-		db.Create(&wallet2)
-		db.Create(&wallet3)
-	}
+	seedOpeningBalance(db, wallets[0], decimal.NewFromInt(20))
+	seedOpeningBalance(db, wallets[1], b2)
+	seedOpeningBalance(db, wallets[2], b3)
 
 	return nil
 }
+
+// migrateSystemAccounts creates every system account missing for the supported currencies
+func migrateSystemAccounts(db *gorm.DB) error {
+	for _, accountType := range models.SystemAccountTypes {
+		for _, currency := range models.Currencies() {
+			account := accountType
+			wallet := models.Wallet{
+				Balance:       decimal.Zero,
+				Currency:      currency,
+				Status:        models.WalletStatusActive,
+				Product:       models.WalletProductStandard,
+				SystemAccount: &account,
+			}
+			status := db.Where("system_account = ? AND currency = ?", accountType, currency).FirstOrCreate(&wallet)
+			if status.Error != nil {
+				return status.Error
+			}
+		}
+	}
+	return nil
+}
+
+func seedWallet(db *gorm.DB, currency string) models.Wallet {
+	wallet := models.Wallet{Balance: decimal.Zero, Currency: currency, Status: models.WalletStatusActive, Product: models.WalletProductStandard}
+	db.Create(&wallet)
+	return wallet
+}
+
+// seedOpeningBalance credits the wallet from the funding account of its currency, with the ledger and
+// journal entries a credit would post
+func seedOpeningBalance(db *gorm.DB, wallet models.Wallet, balance decimal.Decimal) {
+	var funding models.Wallet
+	db.Where("system_account = ? AND currency = ?", models.SystemAccountFunding, wallet.Currency).First(&funding)
+
+	reference := fmt.Sprintf("opening-%d", wallet.ID)
+	journal := models.JournalEntry{OperationReference: reference, Type: models.TransactionTypeCredit}
+	for i, side := range []struct {
+		wallet    models.Wallet
+		direction string
+		amount    decimal.Decimal
+	}{
+		{wallet, models.DirectionCredit, balance},
+		{funding, models.DirectionDebit, balance.Neg()},
+	} {
+		// system account rows keep no balance, it is the sum of their ledger
+		if !side.wallet.IsSystem() {
+			side.wallet.Balance = side.wallet.Balance.Add(side.amount)
+			db.Model(&side.wallet).Update("balance", side.wallet.Balance)
+		}
+
+		transaction := models.Transaction{
+			WalletID:           side.wallet.ID,
+			Type:               models.TransactionTypeCredit,
+			Direction:          side.direction,
+			Amount:             balance,
+			Balance:            side.wallet.Balance,
+			Currency:           wallet.Currency,
+			Reference:          fmt.Sprintf("%s-%d", reference, i),
+			OperationReference: reference,
+		}
+		db.Create(&transaction)
+		walletId := side.wallet.ID
+		journal.Postings = append(journal.Postings, models.Posting{
+			Account:       side.wallet.Account(),
+			Currency:      wallet.Currency,
+			WalletID:      &walletId,
+			TransactionID: &transaction.ID,
+			Amount:        side.amount,
+		})
+	}
+	db.Create(&journal)
+}
@@ -0,0 +1,64 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/wallet-api/cmd/web/models"
+	"io/ioutil"
+)
+
+// FXRateProvider is an interface to obtain currency exchange rates
+type FXRateProvider interface {
+	// GetRate returns how many units of the target currency one unit of the source currency buys
+	GetRate(source string, target string) (decimal.Decimal, error)
+}
+
+// ErrFXPairNotSupported is returned by the providers when they have no rate for the currency pair
+var ErrFXPairNotSupported = errors.New("no exchange rate for the currency pair")
+
+// StaticFXRateProvider serves fixed rates loaded from a JSON file shaped as {"EUR/USD": "1.08"},
+// meant for tests and offline use. Inverse pairs are derived from the listed ones.
+type StaticFXRateProvider struct {
+	rates map[string]decimal.Decimal
+}
+
+const fxPairKey string = "%s/%s"
+
+func (provider *StaticFXRateProvider) GetRate(source string, target string) (decimal.Decimal, error) {
+	if source == target {
+		return decimal.NewFromInt(1), nil
+	}
+	if rate, ok := provider.rates[fmt.Sprintf(fxPairKey, source, target)]; ok {
+		return rate, nil
+	}
+	if rate, ok := provider.rates[fmt.Sprintf(fxPairKey, target, source)]; ok && !rate.IsZero() {
+		return decimal.NewFromInt(1).DivRound(rate, models.FXRateScale), nil
+	}
+	return decimal.Decimal{}, ErrFXPairNotSupported
+}
+
+func NewStaticFXRateProvider(path string) (*StaticFXRateProvider, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates map[string]decimal.Decimal
+	if err := json.Unmarshal(content, &rates); err != nil {
+		return nil, fmt.Errorf("couldn't parse exchange rates file %s: %v", path, err)
+	}
+	return &StaticFXRateProvider{rates: rates}, nil
+}
+
+func NewFXRateProvider() FXRateProvider {
+	provider, err := NewStaticFXRateProvider(viper.GetString("fx.rates_file"))
+	if err != nil {
+		logrus.Errorf("couldn't load exchange rates: %v", err)
+		panic(err)
+	}
+	return provider
+}
@@ -0,0 +1,30 @@
+package infrastructure
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestStaticFXRateProvider_GetRate(t *testing.T) {
+	file, _ := ioutil.TempFile("", "fx_rates*.json")
+	defer os.Remove(file.Name())
+	file.WriteString(`{"EUR/USD": "1.25"}`)
+	file.Close()
+
+	provider, err := NewStaticFXRateProvider(file.Name())
+	assert.Nil(t, err)
+
+	rate, err := provider.GetRate("EUR", "USD")
+	assert.Nil(t, err)
+	assert.True(t, rate.Equal(decimal.RequireFromString("1.25")))
+
+	rate, err = provider.GetRate("USD", "EUR")
+	assert.Nil(t, err)
+	assert.True(t, rate.Equal(decimal.RequireFromString("0.8")))
+
+	_, err = provider.GetRate("EUR", "ARS")
+	assert.Equal(t, ErrFXPairNotSupported, err)
+}
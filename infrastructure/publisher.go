@@ -0,0 +1,84 @@
+package infrastructure
+
+import (
+	"github.com/go-redis/redis"
+	"github.com/spf13/viper"
+	"sync"
+)
+
+// Publisher is an interface to deliver messages to downstream services. Delivery is at least once,
+// consumers must skip the message ids they already handled
+type Publisher interface {
+	// Publish returns once the message was accepted by the broker
+	Publish(message Message) error
+}
+
+type Message struct {
+	// ID identifies the message across redeliveries
+	ID   string
+	Type string
+	// Key is the entity the message is about, e.g. the wallet id, consumers use it to route the message
+	Key     string
+	Payload []byte
+}
+
+// RedisStreamPublisher appends the messages to a Redis stream through the cache connection, the
+// stream is trimmed to about maxLen entries
+type RedisStreamPublisher struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+func (publisher *RedisStreamPublisher) Publish(message Message) error {
+	return publisher.client.XAdd(&redis.XAddArgs{
+		Stream:       publisher.stream,
+		MaxLenApprox: publisher.maxLen,
+		Values: map[string]interface{}{
+			"id":      message.ID,
+			"type":    message.Type,
+			"key":     message.Key,
+			"payload": string(message.Payload),
+		},
+	}).Err()
+}
+
+func NewPublisher() Publisher {
+	provider := &RedisProvider{}
+	c, err := provider.ConnectCache()
+	if err != nil {
+		panic(err)
+	}
+	return &RedisStreamPublisher{
+		client: c.(*redis.Client),
+		stream: viper.GetString("outbox.stream"),
+		maxLen: viper.GetInt64("outbox.max_len"),
+	}
+}
+
+// MemoryPublisher keeps the published messages in memory, meant for tests. Publish fails with Err
+// while it is set
+type MemoryPublisher struct {
+	Err      error
+	mutex    sync.Mutex
+	messages []Message
+}
+
+func (publisher *MemoryPublisher) Publish(message Message) error {
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	if publisher.Err != nil {
+		return publisher.Err
+	}
+	publisher.messages = append(publisher.messages, message)
+	return nil
+}
+
+// Messages returns the messages published so far, in order
+func (publisher *MemoryPublisher) Messages() []Message {
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	return append([]Message{}, publisher.messages...)
+}
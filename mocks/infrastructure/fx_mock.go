@@ -0,0 +1,15 @@
+package mocks
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/mock"
+)
+
+type FXRateProviderMock struct {
+	mock.Mock
+}
+
+func (m *FXRateProviderMock) GetRate(source string, target string) (decimal.Decimal, error) {
+	args := m.Called(source, target)
+	return args.Get(0).(decimal.Decimal), args.Error(1)
+}
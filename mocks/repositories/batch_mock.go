@@ -0,0 +1,40 @@
+package mocks
+
+import (
+	"github.com/wallet-api/cmd/web/models"
+	"time"
+)
+
+func (m *RepositoryMock) CreateBatch(batch *models.Batch) error {
+	args := m.Called(batch)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) GetBatch(batchId int) (models.Batch, error) {
+	args := m.Called(batchId)
+	return args.Get(0).(models.Batch), args.Error(1)
+}
+
+func (m *RepositoryMock) UpdateBatch(batch *models.Batch) error {
+	args := m.Called(batch)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) ClaimBatch(batchId uint, staleBefore time.Time) (bool, error) {
+	args := m.Called(batchId, staleBefore)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *RepositoryMock) GetClaimableBatchIDs(staleBefore time.Time, limit int) ([]uint, error) {
+	args := m.Called(staleBefore, limit)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]uint), err
+}
+
+func (m *RepositoryMock) CompleteBatchItem(item *models.BatchItem) (bool, error) {
+	args := m.Called(item)
+	return args.Bool(0), args.Error(1)
+}
@@ -0,0 +1,33 @@
+package mocks
+
+import (
+	"github.com/wallet-api/cmd/web/models"
+)
+
+func (m *RepositoryMock) GetWalletEventSequence(walletId int) (uint, error) {
+	args := m.Called(walletId)
+	return args.Get(0).(uint), args.Error(1)
+}
+
+func (m *RepositoryMock) AppendWalletEvents(walletId int, expectedSequence uint, events []models.WalletEvent) error {
+	args := m.Called(walletId, expectedSequence, events)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) GetWalletEvents(walletId int, afterSequence uint, limit int) ([]models.WalletEvent, error) {
+	args := m.Called(walletId, afterSequence, limit)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]models.WalletEvent), err
+}
+
+func (m *RepositoryMock) GetEventSourcedWalletIDs(afterId uint, limit int) ([]uint, error) {
+	args := m.Called(afterId, limit)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]uint), err
+}
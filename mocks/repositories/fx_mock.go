@@ -0,0 +1,25 @@
+package mocks
+
+import (
+	"github.com/wallet-api/cmd/web/models"
+	"time"
+)
+
+func (m *RepositoryMock) CreateQuote(quote *models.FXQuote) error {
+	args := m.Called(quote)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) GetQuote(reference string) (models.FXQuote, error) {
+	args := m.Called(reference)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return models.FXQuote{}, err
+	}
+	return args.Get(0).(models.FXQuote), err
+}
+
+func (m *RepositoryMock) RedeemQuote(reference string, now time.Time) (bool, error) {
+	args := m.Called(reference, now)
+	return args.Bool(0), args.Error(1)
+}
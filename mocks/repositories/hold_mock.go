@@ -0,0 +1,43 @@
+package mocks
+
+import (
+	"github.com/wallet-api/cmd/web/models"
+	"time"
+)
+
+func (m *RepositoryMock) CreateHold(hold *models.Hold) error {
+	args := m.Called(hold)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) GetHold(holdId int) (models.Hold, error) {
+	args := m.Called(holdId)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return models.Hold{}, err
+	}
+	return args.Get(0).(models.Hold), err
+}
+
+func (m *RepositoryMock) GetHoldForUpdate(holdId int) (models.Hold, error) {
+	args := m.Called(holdId)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return models.Hold{}, err
+	}
+	return args.Get(0).(models.Hold), err
+}
+
+func (m *RepositoryMock) UpdateHold(hold *models.Hold) error {
+	args := m.Called(hold)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) GetExpiredHolds(now time.Time, limit int) ([]models.Hold, error) {
+	args := m.Called(now, limit)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]models.Hold), err
+}
@@ -0,0 +1,34 @@
+package mocks
+
+import (
+	"github.com/stretchr/testify/mock"
+	"github.com/wallet-api/cmd/web/models"
+)
+
+type IdempotencyRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *IdempotencyRepositoryMock) Get(key string) (*models.IdempotencyRecord, error) {
+	args := m.Called(key)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).(*models.IdempotencyRecord), err
+}
+
+func (m *IdempotencyRepositoryMock) Reserve(record models.IdempotencyRecord) (bool, error) {
+	args := m.Called(record)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *IdempotencyRepositoryMock) Save(record models.IdempotencyRecord) error {
+	args := m.Called(record)
+	return args.Error(0)
+}
+
+func (m *IdempotencyRepositoryMock) Delete(key string) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
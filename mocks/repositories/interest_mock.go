@@ -0,0 +1,43 @@
+package mocks
+
+import (
+	"github.com/wallet-api/cmd/web/models"
+	"time"
+)
+
+func (m *RepositoryMock) GetWalletIDsByProduct(product string, afterId uint, limit int) ([]uint, error) {
+	args := m.Called(product, afterId, limit)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]uint), err
+}
+
+func (m *RepositoryMock) GetLastInterestAccrual(walletId int) (*models.InterestAccrual, error) {
+	args := m.Called(walletId)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).(*models.InterestAccrual), err
+}
+
+func (m *RepositoryMock) CreateInterestAccrual(accrual *models.InterestAccrual) (bool, error) {
+	args := m.Called(accrual)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *RepositoryMock) GetUnpaidInterestAccruals(walletId int, before time.Time) ([]models.InterestAccrual, error) {
+	args := m.Called(walletId, before)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]models.InterestAccrual), err
+}
+
+func (m *RepositoryMock) MarkInterestAccrualsPaid(accrualIds []uint, transactionId uint) error {
+	args := m.Called(accrualIds, transactionId)
+	return args.Error(0)
+}
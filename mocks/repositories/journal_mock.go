@@ -0,0 +1,47 @@
+package mocks
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/wallet-api/cmd/web/models"
+)
+
+func (m *RepositoryMock) CreateJournalEntry(entry *models.JournalEntry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) GetTrialBalanceLines() ([]models.TrialBalanceLine, error) {
+	args := m.Called()
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]models.TrialBalanceLine), err
+}
+
+func (m *RepositoryMock) GetWalletBalancesByCurrency() (map[string]decimal.Decimal, error) {
+	args := m.Called()
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).(map[string]decimal.Decimal), err
+}
+
+func (m *RepositoryMock) GetUnjournaledTransactions(afterId uint, limit int) ([]models.Transaction, error) {
+	args := m.Called(afterId, limit)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]models.Transaction), err
+}
+
+func (m *RepositoryMock) LockUnjournaledOperation(transaction models.Transaction) ([]models.Transaction, error) {
+	args := m.Called(transaction)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]models.Transaction), err
+}
@@ -0,0 +1,35 @@
+package mocks
+
+import (
+	"github.com/wallet-api/cmd/web/models"
+	"time"
+)
+
+func (m *RepositoryMock) GetPendingOutboxMessages(afterId uint, limit int) ([]models.OutboxMessage, error) {
+	args := m.Called(afterId, limit)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]models.OutboxMessage), err
+}
+
+func (m *RepositoryMock) MarkOutboxWebhooksQueued(messageId uint, at time.Time) error {
+	args := m.Called(messageId, at)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) MarkOutboxMessageDelivered(messageId uint, at time.Time) error {
+	args := m.Called(messageId, at)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) MarkOutboxMessageFailed(messageId uint, reason string, retryAt time.Time) error {
+	args := m.Called(messageId, reason, retryAt)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) ParkOutboxMessage(messageId uint, reason string, at time.Time) error {
+	args := m.Called(messageId, reason, at)
+	return args.Error(0)
+}
@@ -0,0 +1,17 @@
+package mocks
+
+import "github.com/wallet-api/cmd/web/models"
+
+func (m *RepositoryMock) CreateOverdraftLimitChange(change *models.OverdraftLimitChange) error {
+	args := m.Called(change)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) GetOverdraftLimitChanges(walletId int) ([]models.OverdraftLimitChange, error) {
+	args := m.Called(walletId)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]models.OverdraftLimitChange), err
+}
@@ -0,0 +1,24 @@
+package mocks
+
+import (
+	"github.com/wallet-api/cmd/web/models"
+)
+
+func (m *RepositoryMock) CreateOwner(owner *models.Owner) error {
+	args := m.Called(owner)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) GetOwner(ownerId int) (models.Owner, error) {
+	args := m.Called(ownerId)
+	return args.Get(0).(models.Owner), args.Error(1)
+}
+
+func (m *RepositoryMock) GetOwnerWallets(ownerId int) ([]models.Wallet, error) {
+	args := m.Called(ownerId)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]models.Wallet), err
+}
@@ -0,0 +1,18 @@
+package mocks
+
+import (
+	"github.com/wallet-api/cmd/web/models"
+)
+
+func (m *RepositoryMock) GetCachedWallet(walletId int) (*models.Wallet, error) {
+	args := m.Called(walletId)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).(*models.Wallet), err
+}
+
+func (m *RepositoryMock) EvictCachedWallet(walletId uint) {
+	m.Called(walletId)
+}
@@ -0,0 +1,39 @@
+package mocks
+
+import (
+	"github.com/wallet-api/cmd/web/models"
+	"time"
+)
+
+func (m *RepositoryMock) GetLatestSnapshot(walletId int, at time.Time) (*models.BalanceSnapshot, error) {
+	args := m.Called(walletId, at)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).(*models.BalanceSnapshot), err
+}
+
+func (m *RepositoryMock) CreateSnapshot(snapshot *models.BalanceSnapshot) error {
+	args := m.Called(snapshot)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) SumLedger(walletId int, afterTransactionId uint, at time.Time) (models.LedgerSum, error) {
+	args := m.Called(walletId, afterTransactionId, at)
+	return args.Get(0).(models.LedgerSum), args.Error(1)
+}
+
+func (m *RepositoryMock) SumLedgerRange(walletId int, afterTransactionId uint, lastTransactionId uint) (models.LedgerSum, error) {
+	args := m.Called(walletId, afterTransactionId, lastTransactionId)
+	return args.Get(0).(models.LedgerSum), args.Error(1)
+}
+
+func (m *RepositoryMock) GetWalletIDs(afterId uint, limit int) ([]uint, error) {
+	args := m.Called(afterId, limit)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]uint), err
+}
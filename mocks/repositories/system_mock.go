@@ -0,0 +1,19 @@
+package mocks
+
+import (
+	"github.com/wallet-api/cmd/web/models"
+)
+
+func (m *RepositoryMock) GetSystemAccount(accountType string, currency string) (models.Wallet, error) {
+	args := m.Called(accountType, currency)
+	return args.Get(0).(models.Wallet), args.Error(1)
+}
+
+func (m *RepositoryMock) GetSystemAccounts() ([]models.Wallet, error) {
+	args := m.Called()
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]models.Wallet), err
+}
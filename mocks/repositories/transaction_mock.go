@@ -1,8 +1,11 @@
 package mocks
 
 import (
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/mock"
 	"github.com/wallet-api/cmd/web/models"
+	"github.com/wallet-api/cmd/web/repositories"
+	"time"
 )
 
 type RepositoryMock struct {
@@ -18,7 +21,90 @@ func (m *RepositoryMock) GetWallet(walletId int) (models.Wallet, error) {
 	return args.Get(0).(models.Wallet), err
 }
 
-func (m *RepositoryMock) UpdateWallet(wallet models.Wallet) error {
+func (m *RepositoryMock) GetWalletForUpdate(walletId int) (models.Wallet, error) {
+	args := m.Called(walletId)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return models.Wallet{}, err
+	}
+	return args.Get(0).(models.Wallet), err
+}
+
+func (m *RepositoryMock) GetWalletFromDatabase(walletId int) (models.Wallet, error) {
+	args := m.Called(walletId)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return models.Wallet{}, err
+	}
+	return args.Get(0).(models.Wallet), err
+}
+
+func (m *RepositoryMock) CreateWallet(wallet *models.Wallet) error {
+	args := m.Called(wallet)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) UpdateWallet(wallet *models.Wallet) error {
 	args := m.Called(wallet)
 	return args.Error(0)
 }
+
+func (m *RepositoryMock) CreateTransaction(transaction *models.Transaction) error {
+	args := m.Called(transaction)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) GetTransactionForUpdate(transactionId int) (models.Transaction, error) {
+	args := m.Called(transactionId)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return models.Transaction{}, err
+	}
+	return args.Get(0).(models.Transaction), err
+}
+
+func (m *RepositoryMock) GetReversedAmount(transactionId int) (decimal.Decimal, error) {
+	args := m.Called(transactionId)
+	return args.Get(0).(decimal.Decimal), args.Error(1)
+}
+
+func (m *RepositoryMock) GetOperationFees(walletId int, operationReference string) ([]models.Transaction, error) {
+	args := m.Called(walletId, operationReference)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]models.Transaction), err
+}
+
+func (m *RepositoryMock) GetTransactions(walletId int, filter models.TransactionFilter) ([]models.Transaction, error) {
+	args := m.Called(walletId, filter)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]models.Transaction), err
+}
+
+// StreamTransactions feeds fn with the transactions set as the first return value
+func (m *RepositoryMock) StreamTransactions(walletId int, from time.Time, to time.Time, fn func(transaction models.Transaction) error) error {
+	args := m.Called(walletId, from, to)
+	if transactions, ok := args.Get(0).([]models.Transaction); ok {
+		for _, transaction := range transactions {
+			if err := fn(transaction); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+// WithinTransaction runs fn against the mock itself so expectations set on it apply inside the transaction
+func (m *RepositoryMock) WithinTransaction(fn func(repository repositories.ITransactionRepository) error) error {
+	return fn(m)
+}
+
+// WithinSnapshot runs fn against the mock itself like WithinTransaction
+func (m *RepositoryMock) WithinSnapshot(fn func(repository repositories.ITransactionRepository) error) error {
+	return fn(m)
+}
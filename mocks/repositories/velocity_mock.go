@@ -0,0 +1,29 @@
+package mocks
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/wallet-api/cmd/web/models"
+)
+
+func (m *RepositoryMock) GetVelocityLimits(walletId int) ([]models.VelocityLimit, error) {
+	args := m.Called(walletId)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]models.VelocityLimit), err
+}
+
+func (m *RepositoryMock) ReplaceVelocityLimits(walletId int, limits []models.VelocityLimit) error {
+	args := m.Called(walletId, limits)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) GetVelocityUsage(walletId int, window models.VelocityWindow) (models.VelocityUsage, error) {
+	args := m.Called(walletId, window)
+	return args.Get(0).(models.VelocityUsage), args.Error(1)
+}
+
+func (m *RepositoryMock) AddVelocityUsage(walletId int, window models.VelocityWindow, amount decimal.Decimal) {
+	m.Called(walletId, window, amount)
+}
@@ -0,0 +1,72 @@
+package mocks
+
+import (
+	"github.com/wallet-api/cmd/web/models"
+	"time"
+)
+
+func (m *RepositoryMock) CreateWebhookSubscription(subscription *models.WebhookSubscription) error {
+	args := m.Called(subscription)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) GetWebhookSubscription(subscriptionId int) (models.WebhookSubscription, error) {
+	args := m.Called(subscriptionId)
+	return args.Get(0).(models.WebhookSubscription), args.Error(1)
+}
+
+func (m *RepositoryMock) GetWebhookSubscriptions(walletId int) ([]models.WebhookSubscription, error) {
+	args := m.Called(walletId)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]models.WebhookSubscription), err
+}
+
+func (m *RepositoryMock) CreateWebhookDelivery(delivery *models.WebhookDelivery) (bool, error) {
+	args := m.Called(delivery)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *RepositoryMock) GetWebhookDelivery(deliveryId int) (models.WebhookDelivery, error) {
+	args := m.Called(deliveryId)
+	return args.Get(0).(models.WebhookDelivery), args.Error(1)
+}
+
+func (m *RepositoryMock) ClaimDueWebhookDeliveries(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	args := m.Called(now, lease, limit)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]models.WebhookDelivery), err
+}
+
+func (m *RepositoryMock) GetDeadWebhookDeliveries() ([]models.WebhookDelivery, error) {
+	args := m.Called()
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]models.WebhookDelivery), err
+}
+
+func (m *RepositoryMock) UpdateWebhookDelivery(delivery *models.WebhookDelivery) error {
+	args := m.Called(delivery)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) CreateWebhookAttempt(attempt *models.WebhookAttempt) error {
+	args := m.Called(attempt)
+	return args.Error(0)
+}
+
+func (m *RepositoryMock) GetWebhookAttempts(deliveryId int) ([]models.WebhookAttempt, error) {
+	args := m.Called(deliveryId)
+	err := args.Error(1)
+	if args.Get(0) == nil {
+		return nil, err
+	}
+	return args.Get(0).([]models.WebhookAttempt), err
+}